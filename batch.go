@@ -0,0 +1,267 @@
+package cryptoconditions
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+
+	"filippo.io/edwards25519"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// BatchValidator validates many fulfillments at once.
+//
+// Batching only works in the Ed25519ZIP215 mode, which must be selected in
+// the options: the Ed25519 signatures of all jobs, including those below
+// PREFIX-SHA-256 and THRESHOLD-SHA-256 fulfillments, are then verified in a
+// single randomized batch. Batch verification uses the cofactored
+// verification equation, with which batch and single verification only agree
+// under the ZIP-215 rules. In every other mode, including Ed25519Default,
+// which nil options select, a BatchValidator gives no speedup: the
+// signatures are verified one by one.
+//
+// Either way, the result for every job is the error that ValidateWithOptions
+// returns for it with the same options.
+//
+// A BatchValidator is not safe for concurrent use.
+type BatchValidator struct {
+	opts ValidationOptions
+	jobs []batchJob
+}
+
+// batchJob is a fulfillment that is to be validated by a BatchValidator.
+type batchJob struct {
+	ff        Fulfillment
+	condition *Condition
+	message   []byte
+}
+
+// NewBatchValidator creates a new BatchValidator that validates with the
+// given options, which may be nil. Workers is ignored. Signatures are only
+// batched if the Ed25519Mode of the options is Ed25519ZIP215.
+func NewBatchValidator(opts *ValidationOptions) *BatchValidator {
+	b := &BatchValidator{}
+	if opts != nil {
+		b.opts.Ed25519Mode = opts.Ed25519Mode
+		b.opts.Cache = opts.Cache
+	}
+	return b
+}
+
+// Add adds a job that validates the fulfillment against the condition with
+// the given message.
+func (b *BatchValidator) Add(ff Fulfillment, condition *Condition, message []byte) {
+	b.jobs = append(b.jobs, batchJob{ff: Seal(ff), condition: condition, message: message})
+}
+
+// Len returns the number of jobs that were added.
+func (b *BatchValidator) Len() int {
+	return len(b.jobs)
+}
+
+// Reset removes all jobs, so that the validator can be reused.
+func (b *BatchValidator) Reset() {
+	b.jobs = nil
+}
+
+// Validate validates all jobs and returns their results in the order in
+// which they were added; the result of a valid job is nil.
+// When the batch of signatures is invalid, the signatures are verified one
+// by one to find the invalid ones, so that only the jobs containing them
+// fail. Jobs found in the cache of the options are not validated.
+func (b *BatchValidator) Validate() []error {
+	results := make([]error, len(b.jobs))
+	var jobs []int
+	keys := make([]validationCacheKey, len(b.jobs))
+	cacheable := make([]bool, len(b.jobs))
+	for i, job := range b.jobs {
+		if b.opts.Cache != nil {
+			key, ok := newValidationCacheKey(b.opts.Ed25519Mode, job.ff, job.condition, job.message)
+			if ok && b.opts.Cache.contains(key) {
+				continue
+			}
+			keys[i], cacheable[i] = key, ok
+		}
+		jobs = append(jobs, i)
+	}
+
+	opts := b.opts
+	if opts.Ed25519Mode == Ed25519ZIP215 {
+		// If the batch can not be verified, the signatures are verified
+		// one by one.
+		entries := b.ed25519Entries(jobs)
+		if len(entries) > 1 {
+			if ok, err := verifyEd25519Batch(entries); ok && err == nil {
+				opts.verifiedEd25519 = make(map[ed25519Key]struct{}, len(entries))
+				for _, e := range entries {
+					opts.verifiedEd25519[e.key] = struct{}{}
+				}
+			}
+		}
+	}
+
+	for _, i := range jobs {
+		job := b.jobs[i]
+		results[i] = job.ff.validate(job.condition, job.message, &opts)
+		if results[i] == nil && cacheable[i] {
+			b.opts.Cache.add(keys[i])
+		}
+	}
+	return results
+}
+
+// ed25519Entries returns the distinct Ed25519 signatures of the jobs, with
+// the messages that they are verified with. Signatures that are invalid
+// without verification, because of their size or encoding, are left out,
+// as are those below PREFIX-SHA-256 fulfillments whose message is too long.
+func (b *BatchValidator) ed25519Entries(jobs []int) []*ed25519Entry {
+	var entries []*ed25519Entry
+	seen := map[ed25519Key]bool{}
+	for _, i := range jobs {
+		job := b.jobs[i]
+		// The messages that each node of the tree receives.
+		messages := map[string][]byte{"/": job.message}
+		Walk(job.ff, func(path NodePath, node Fulfillment) error {
+			msg := messages[path.String()]
+
+			switch f := derefFulfillment(node).(type) {
+			case FfPrefixSha256:
+				if len(msg) > int(f.MaxMessageLength) {
+					return SkipChildren
+				}
+				prefixed := make([]byte, 0, len(f.Prefix)+len(msg))
+				prefixed = append(prefixed, f.Prefix...)
+				prefixed = append(prefixed, msg...)
+				messages[path.child(0).String()] = prefixed
+
+			case FfThresholdSha256:
+				for i := range f.SubFulfillments {
+					messages[path.child(i).String()] = msg
+				}
+
+			case FfEd25519Sha256:
+				e := newEd25519Entry(f.PublicKey, msg, f.Signature)
+				if e != nil && !seen[e.key] {
+					seen[e.key] = true
+					entries = append(entries, e)
+				}
+			}
+			return nil
+		})
+	}
+	return entries
+}
+
+// ed25519Key identifies an Ed25519 signature of a message.
+type ed25519Key struct {
+	pubkey      [ed25519.PublicKeySize]byte
+	signature   [ed25519.SignatureSize]byte
+	messageHash [sha256.Size]byte
+}
+
+// newEd25519Key returns the key of the signature, or false if the public key
+// or the signature have the wrong size.
+func newEd25519Key(pubkey, message, signature []byte) (ed25519Key, bool) {
+	var key ed25519Key
+	if len(pubkey) != ed25519.PublicKeySize || len(signature) != ed25519.SignatureSize {
+		return key, false
+	}
+	copy(key.pubkey[:], pubkey)
+	copy(key.signature[:], signature)
+	key.messageHash = sha256.Sum256(message)
+	return key, true
+}
+
+// ed25519Entry is a decoded Ed25519 signature to be verified in a batch.
+type ed25519Entry struct {
+	key     ed25519Key
+	message []byte
+	A, R    *edwards25519.Point
+	S       *edwards25519.Scalar
+}
+
+// newEd25519Entry decodes the signature with the ZIP-215 rules. It returns
+// nil if the signature can not be decoded.
+func newEd25519Entry(pubkey, message, signature []byte) *ed25519Entry {
+	key, ok := newEd25519Key(pubkey, message, signature)
+	if !ok {
+		return nil
+	}
+	A, err := new(edwards25519.Point).SetBytes(pubkey)
+	if err != nil {
+		return nil
+	}
+	R, err := new(edwards25519.Point).SetBytes(signature[:32])
+	if err != nil {
+		return nil
+	}
+	S, err := edwards25519.NewScalar().SetCanonicalBytes(signature[32:])
+	if err != nil {
+		return nil
+	}
+	return &ed25519Entry{key: key, message: message, A: A, R: R, S: S}
+}
+
+// verifiedEd25519Signature returns whether the signature was verified by a
+// BatchValidator beforehand.
+func (o *ValidationOptions) verifiedEd25519Signature(pubkey, message, signature []byte) bool {
+	if o == nil || o.verifiedEd25519 == nil {
+		return false
+	}
+	key, ok := newEd25519Key(pubkey, message, signature)
+	if !ok {
+		return false
+	}
+	_, ok = o.verifiedEd25519[key]
+	return ok
+}
+
+// verifyEd25519Batch verifies all signatures with the ZIP-215 rules at once.
+// It checks that
+//
+//	[8]([sum z_i*S_i]B - sum [z_i]R_i - sum [z_i*k_i]A_i)
+//
+// is the identity for random 128-bit scalars z_i, which holds for valid
+// signatures and, except with negligible probability, fails if any of them
+// is invalid.
+func verifyEd25519Batch(entries []*ed25519Entry) (bool, error) {
+	scalars := make([]*edwards25519.Scalar, 0, 1+2*len(entries))
+	points := make([]*edwards25519.Point, 0, 1+2*len(entries))
+	sumS := edwards25519.NewScalar()
+	scalars = append(scalars, sumS)
+	points = append(points, edwards25519.NewGeneratorPoint())
+
+	for _, e := range entries {
+		z, err := randomBatchScalar()
+		if err != nil {
+			return false, err
+		}
+
+		h := sha512.New()
+		h.Write(e.key.signature[:32])
+		h.Write(e.key.pubkey[:])
+		h.Write(e.message)
+		k, _ := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+
+		// Negating the scalars changes the torsion components of the
+		// terms, which the multiplication by the cofactor removes.
+		sumS.MultiplyAdd(z, e.S, sumS)
+		scalars = append(scalars,
+			edwards25519.NewScalar().Negate(z),
+			edwards25519.NewScalar().Negate(k.Multiply(k, z)))
+		points = append(points, e.R, e.A)
+	}
+
+	check := new(edwards25519.Point).VarTimeMultiScalarMult(scalars, points)
+	return isSmallOrder(check), nil
+}
+
+// randomBatchScalar returns a random scalar of 128 bits.
+func randomBatchScalar() (*edwards25519.Scalar, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:16]); err != nil {
+		return nil, errors.Wrap(err, "failed to generate batch scalar")
+	}
+	return edwards25519.NewScalar().SetCanonicalBytes(b[:])
+}
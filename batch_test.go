@@ -0,0 +1,130 @@
+package cryptoconditions
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestBatchValidator(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, bob, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tmpl, err := AtLeast(2,
+		Ed25519Key(alice.Public().(ed25519.PublicKey)),
+		WithPrefix([]byte("bob:"), 64, Ed25519Key(bob.Public().(ed25519.PublicKey))),
+		Hashlock(make([]byte, 32), 32),
+	).Template()
+	require.NoError(t, err)
+	cond := tmpl.Condition()
+	keyring, err := NewMemoryKeyring(alice, bob)
+	require.NoError(t, err)
+
+	var ffs []Fulfillment
+	var messages [][]byte
+	for i := 0; i < 8; i++ {
+		message := []byte(fmt.Sprintf("payment %d", i))
+		ff, _, err := Sign(tmpl, message, keyring, nil)
+		require.NoError(t, err)
+		ffs = append(ffs, ff)
+		messages = append(messages, message)
+	}
+	single, err := NewEd25519Sha256(alice.Public().(ed25519.PublicKey),
+		ed25519.Sign(alice, []byte("single")))
+	require.NoError(t, err)
+
+	for _, mode := range []Ed25519VerificationMode{Ed25519ZIP215, Ed25519Default} {
+		opts := &ValidationOptions{Ed25519Mode: mode}
+		b := NewBatchValidator(opts)
+		for i, ff := range ffs {
+			b.Add(ff, cond, messages[i])
+		}
+		b.Add(single, single.Condition(), []byte("single"))
+		assert.Equal(t, len(ffs)+1, b.Len())
+		for i, err := range b.Validate() {
+			assert.NoError(t, err, "%s: job %d", mode, i)
+		}
+
+		// Only the jobs with an invalid signature fail, with the error of
+		// single validation.
+		b.Reset()
+		for i, ff := range ffs {
+			message := messages[i]
+			if i%3 == 1 {
+				message = []byte("other")
+			}
+			b.Add(ff, cond, message)
+		}
+		results := b.Validate()
+		require.Len(t, results, len(ffs))
+		for i, err := range results {
+			expected := ValidateWithOptions(ffs[i], cond, messages[i], opts)
+			if i%3 == 1 {
+				expected = ValidateWithOptions(ffs[i], cond, []byte("other"), opts)
+				assert.Error(t, err)
+			}
+			assert.Equal(t, expected, err, "%s: job %d", mode, i)
+		}
+	}
+}
+
+func TestVerifyEd25519Batch(t *testing.T) {
+	var entries []*ed25519Entry
+	for i := 0; i < 4; i++ {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		message := []byte{byte(i)}
+		entry := newEd25519Entry(pub, message, ed25519.Sign(priv, message))
+		require.NotNil(t, entry)
+		entries = append(entries, entry)
+	}
+	ok, err := verifyEd25519Batch(entries)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// A signature for another message spoils the batch.
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	invalid := newEd25519Entry(pub, []byte("a"), ed25519.Sign(priv, []byte("b")))
+	ok, err = verifyEd25519Batch(append(entries, invalid))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Signatures that can not be decoded are not batched.
+	signature := ed25519.Sign(priv, []byte("a"))
+	for i := 32; i < 64; i++ {
+		signature[i] = 0xff
+	}
+	assert.Nil(t, newEd25519Entry(pub, []byte("a"), signature))
+	assert.Nil(t, newEd25519Entry(pub[1:], []byte("a"), signature))
+}
+
+func ExampleBatchValidator() {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+
+	// Signatures are only verified in a batch in the ZIP-215 mode. With nil
+	// options or any other mode, they are verified one by one.
+	b := NewBatchValidator(&ValidationOptions{Ed25519Mode: Ed25519ZIP215})
+	for i := 0; i < 3; i++ {
+		message := []byte(fmt.Sprintf("payment %d", i))
+		ff, _ := SignEd25519(key, message)
+		b.Add(ff, ff.Condition(), message)
+	}
+	ff, _ := SignEd25519(key, []byte("payment 3"))
+	b.Add(ff, ff.Condition(), []byte("payment 4"))
+
+	for i, err := range b.Validate() {
+		fmt.Println(i, err == nil)
+	}
+	// Output:
+	// 0 true
+	// 1 true
+	// 2 true
+	// 3 false
+}
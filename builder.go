@@ -0,0 +1,232 @@
+package cryptoconditions
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"golang.org/x/crypto/ed25519"
+)
+
+// ConditionBuilder describes a node of a condition tree that is being built
+// from public material only. Builders are created with the functions
+// Ed25519Key, RSAKey, Secp256k1Key, EcdsaP256Key, WebAuthnKey, WotsKey,
+// Hashlock, WithPrefix, AtLeast, AllOf and AnyOf and can be nested freely:
+//
+//	cond, err := AtLeast(2,
+//		WithPrefix(notaryPrefix, 0, Ed25519Key(notaryKey)),
+//		Hashlock(hash, 32),
+//		RSAKey(escrowKey),
+//	).Build()
+//
+// Errors in any of the nodes are collected and returned at once by Build and
+// Template.
+type ConditionBuilder struct {
+	build func(path NodePath, errs *builderErrors) Template
+}
+
+// Build builds the condition described by the builder.
+// If any node of the tree is invalid, an error describing all the problems
+// is returned.
+func (b ConditionBuilder) Build() (*Condition, error) {
+	t, err := b.Template()
+	if err != nil {
+		return nil, err
+	}
+	return t.Condition(), nil
+}
+
+// Template builds the template described by the builder, which can be used
+// to fulfill the condition later.
+// If any node of the tree is invalid, an error describing all the problems
+// is returned.
+func (b ConditionBuilder) Template() (Template, error) {
+	errs := new(builderErrors)
+	t := b.buildAt(nil, errs)
+	if len(errs.messages) > 0 {
+		return nil, errs
+	}
+	return t, nil
+}
+
+// buildAt builds the node at the given path.
+// It returns nil if the node or one of its children is invalid.
+func (b ConditionBuilder) buildAt(path NodePath, errs *builderErrors) Template {
+	if b.build == nil {
+		errs.add(path, "missing condition")
+		return nil
+	}
+	return b.build(path, errs)
+}
+
+// Ed25519Key describes an ED25519-SHA-256 condition for the given public key.
+func Ed25519Key(pubkey ed25519.PublicKey) ConditionBuilder {
+	return ConditionBuilder{func(path NodePath, errs *builderErrors) Template {
+		if len(pubkey) != ed25519.PublicKeySize {
+			errs.add(path, "wrong Ed25519 public key size (%d)", len(pubkey))
+			return nil
+		}
+		return &Ed25519Sha256Template{PublicKey: pubkey}
+	}}
+}
+
+// RSAKey describes an RSA-SHA-256 condition for the given public key.
+// The key must use the public exponent 65537 and have a modulus of 1024 to
+// 4096 bits.
+func RSAKey(pubkey *rsa.PublicKey) ConditionBuilder {
+	return ConditionBuilder{func(path NodePath, errs *builderErrors) Template {
+		if err := checkRsaPublicKey(pubkey); err != nil {
+			errs.add(path, "%s", err)
+			return nil
+		}
+		modulus := pubkey.N.Bytes()
+		return &RsaSha256Template{Modulus: modulus}
+	}}
+}
+
+// Secp256k1Key describes an experimental SECP256K1-SHA-256 condition for the
+// given public key.
+func Secp256k1Key(pubkey *secp256k1.PublicKey) ConditionBuilder {
+	return ConditionBuilder{func(path NodePath, errs *builderErrors) Template {
+		if pubkey == nil {
+			errs.add(path, "missing secp256k1 public key")
+			return nil
+		}
+		return &Secp256k1Sha256Template{PublicKey: pubkey.SerializeCompressed()}
+	}}
+}
+
+// EcdsaP256Key describes an experimental ECDSA-P256-SHA-256 condition for
+// the given public key, which must be on the P-256 curve.
+func EcdsaP256Key(pubkey *ecdsa.PublicKey) ConditionBuilder {
+	return ConditionBuilder{func(path NodePath, errs *builderErrors) Template {
+		encoded, err := marshalEcdsaP256PublicKey(pubkey)
+		if err != nil {
+			errs.add(path, "%s", err)
+			return nil
+		}
+		return &EcdsaP256Sha256Template{PublicKey: encoded}
+	}}
+}
+
+// WebAuthnKey describes an experimental WEBAUTHN-SHA-256 condition for the
+// P-256 public key of a WebAuthn credential of the given relying party.
+func WebAuthnKey(pubkey *ecdsa.PublicKey, rpID string) ConditionBuilder {
+	return ConditionBuilder{func(path NodePath, errs *builderErrors) Template {
+		encoded, err := marshalEcdsaP256PublicKey(pubkey)
+		if err != nil {
+			errs.add(path, "%s", err)
+			return nil
+		}
+		return &WebAuthnSha256Template{
+			PublicKey: encoded,
+			RpIdHash:  WebAuthnRpIdHash(rpID),
+		}
+	}}
+}
+
+// WotsKey describes an experimental WOTS-SHA-256 condition for the given
+// one-time public key.
+func WotsKey(pubkey WotsPublicKey) ConditionBuilder {
+	return ConditionBuilder{func(path NodePath, errs *builderErrors) Template {
+		tmpl, err := NewWotsSha256Template(pubkey)
+		if err != nil {
+			errs.add(path, "%s", err)
+			return nil
+		}
+		return tmpl
+	}}
+}
+
+// Hashlock describes a PREIMAGE-SHA-256 condition for a preimage with the
+// given SHA-256 hash and size in bytes.
+func Hashlock(hash []byte, size int) ConditionBuilder {
+	return ConditionBuilder{func(path NodePath, errs *builderErrors) Template {
+		if len(hash) != sha256.Size {
+			errs.add(path, "wrong preimage hash size (%d)", len(hash))
+			return nil
+		}
+		if size < 0 {
+			errs.add(path, "negative preimage size (%d)", size)
+			return nil
+		}
+		return &PreimageSha256Template{Hash: hash, Size: size}
+	}}
+}
+
+// WithPrefix describes a PREFIX-SHA-256 condition that prepends prefix to
+// messages of at most maxMessageLength bytes before passing them to sub.
+func WithPrefix(prefix []byte, maxMessageLength uint32, sub ConditionBuilder) ConditionBuilder {
+	return ConditionBuilder{func(path NodePath, errs *builderErrors) Template {
+		subTemplate := sub.buildAt(path.child(0), errs)
+		if subTemplate == nil {
+			return nil
+		}
+		return &PrefixSha256Template{
+			Prefix:           prefix,
+			MaxMessageLength: maxMessageLength,
+			SubTemplate:      subTemplate,
+		}
+	}}
+}
+
+// AtLeast describes a THRESHOLD-SHA-256 condition that requires at least n of
+// the sub-conditions to be fulfilled.
+func AtLeast(n int, subs ...ConditionBuilder) ConditionBuilder {
+	return ConditionBuilder{func(path NodePath, errs *builderErrors) Template {
+		subTemplates := make([]Template, len(subs))
+		complete := true
+		for i, sub := range subs {
+			subTemplates[i] = sub.buildAt(path.child(i), errs)
+			complete = complete && subTemplates[i] != nil
+		}
+		switch {
+		case n < 1:
+			errs.add(path, "threshold must be at least 1, not %d", n)
+			return nil
+		case n > len(subs):
+			errs.add(path, "threshold of %d exceeds the number of "+
+				"sub-conditions (%d)", n, len(subs))
+			return nil
+		case n > math.MaxUint16:
+			errs.add(path, "threshold of %d is too large", n)
+			return nil
+		case !complete:
+			return nil
+		}
+		return &ThresholdSha256Template{
+			Threshold:    uint16(n),
+			SubTemplates: subTemplates,
+		}
+	}}
+}
+
+// AllOf describes a THRESHOLD-SHA-256 condition that requires all of the
+// sub-conditions to be fulfilled.
+func AllOf(subs ...ConditionBuilder) ConditionBuilder {
+	return AtLeast(len(subs), subs...)
+}
+
+// AnyOf describes a THRESHOLD-SHA-256 condition that requires one of the
+// sub-conditions to be fulfilled.
+func AnyOf(subs ...ConditionBuilder) ConditionBuilder {
+	return AtLeast(1, subs...)
+}
+
+// builderErrors collects the errors of all the nodes of a builder tree.
+type builderErrors struct {
+	messages []string
+}
+
+func (e *builderErrors) add(path NodePath, format string, args ...interface{}) {
+	e.messages = append(e.messages,
+		fmt.Sprintf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (e *builderErrors) Error() string {
+	return "failed to build condition: " + strings.Join(e.messages, "; ")
+}
@@ -0,0 +1,72 @@
+package cryptoconditions
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionBuilder(t *testing.T) {
+	ff := testNotarizedReceipt(t)
+	prefixFf := ff.SubFulfillments[0].(*FfPrefixSha256)
+	edFf := prefixFf.SubFulfillment.(*FfEd25519Sha256)
+	preimage := ff.SubFulfillments[1].(*FfPreimageSha256).Preimage
+	hash := sha256.Sum256(preimage)
+
+	cond, err := AllOf(
+		WithPrefix(prefixFf.Prefix, prefixFf.MaxMessageLength,
+			Ed25519Key(edFf.Ed25519PublicKey())),
+		Hashlock(hash[:], len(preimage)),
+	).Build()
+	require.NoError(t, err)
+	assert.True(t, ff.Condition().Equals(cond), "%s != %s", ff.Condition(), cond)
+	assert.Equal(t, 134304, cond.Cost())
+
+	// Leaves produce the same conditions as their fulfillments.
+	edCond, err := Ed25519Key(edFf.Ed25519PublicKey()).Build()
+	require.NoError(t, err)
+	assert.True(t, edFf.Condition().Equals(edCond))
+
+	preimageCond, err := Hashlock(hash[:], len(preimage)).Build()
+	require.NoError(t, err)
+	assert.True(t, NewPreimageSha256(preimage).Condition().Equals(preimageCond))
+}
+
+func TestConditionBuilder_RSAKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	cond, err := AnyOf(RSAKey(&key.PublicKey)).Build()
+	require.NoError(t, err)
+	assert.Equal(t, CTThresholdSha256, cond.Type())
+	assert.True(t, cond.SubTypes().Has(CTRsaSha256))
+	assert.Equal(t, 128*128+1024, cond.Cost())
+
+	_, err = RSAKey(&rsa.PublicKey{N: key.N, E: 3}).Build()
+	assert.Error(t, err)
+	_, err = RSAKey(&rsa.PublicKey{N: big.NewInt(1234567), E: 65537}).Build()
+	assert.Error(t, err)
+}
+
+func TestConditionBuilder_errors(t *testing.T) {
+	_, err := AtLeast(3,
+		Ed25519Key([]byte("too short")),
+		WithPrefix(nil, 0, Hashlock([]byte("not a hash"), 4)),
+	).Build()
+	require.Error(t, err)
+	// All the problems are reported at once.
+	assert.Contains(t, err.Error(), "/0: wrong Ed25519 public key size (9)")
+	assert.Contains(t, err.Error(), "/1/0: wrong preimage hash size (10)")
+	assert.Contains(t, err.Error(), "/: threshold of 3 exceeds the number of sub-conditions (2)")
+
+	_, err = AnyOf().Build()
+	assert.Error(t, err)
+
+	_, err = WithPrefix(nil, 0, ConditionBuilder{}).Build()
+	assert.EqualError(t, err, "failed to build condition: /0: missing condition")
+}
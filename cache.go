@@ -0,0 +1,255 @@
+package cryptoconditions
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ValidationCache remembers which fulfillments were found valid for which
+// conditions and messages, so that validating them again skips the
+// verification of their signatures. It is meant for services that receive
+// the same fulfillment many times, for example because of retries.
+//
+// Only successful validations are cached. Entries are identified by the
+// condition, the SHA-256 hash of the message, a SHA-256 digest of the
+// fulfillment tree and the Ed25519 verification mode. The digest covers the
+// encoding of every leaf fulfillment and the parameters and sub-conditions of
+// every compound one, so that THRESHOLD-SHA-256 fulfillments, which can not be
+// encoded, are cached too. The cache
+// holds a bounded number of entries, evicting the least recently used one
+// when full, and entries expire after a fixed time.
+//
+// A ValidationCache is safe for concurrent use.
+type ValidationCache struct {
+	capacity int
+	ttl      time.Duration
+	// now returns the current time; it is replaced in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[validationCacheKey]*list.Element
+	// lru holds the keys of the entries, the most recently used first.
+	lru   *list.List
+	stats ValidationCacheStats
+}
+
+// ValidationCacheStats are the metrics of a ValidationCache.
+type ValidationCacheStats struct {
+	// Hits is the number of validations that were skipped.
+	Hits uint64
+	// Misses is the number of validations that were not found in the cache.
+	Misses uint64
+	// Evictions is the number of entries that were removed because the
+	// cache was full or because they expired.
+	Evictions uint64
+	// Len is the current number of entries.
+	Len int
+}
+
+// validationCacheKey identifies a successful validation.
+type validationCacheKey struct {
+	mode Ed25519VerificationMode
+	// condition is the hash of the URI of the condition, which contains its
+	// fingerprint, cost and subtypes.
+	condition   [sha256.Size]byte
+	message     [sha256.Size]byte
+	fulfillment [sha256.Size]byte
+}
+
+// validationCacheEntry is an element of the lru list.
+type validationCacheEntry struct {
+	key     validationCacheKey
+	expires time.Time
+}
+
+// NewValidationCache creates a cache that holds at most capacity entries,
+// each for at most ttl. A ttl of zero keeps entries until they are evicted
+// because the cache is full.
+func NewValidationCache(capacity int, ttl time.Duration) *ValidationCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &ValidationCache{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[validationCacheKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Stats returns the metrics of the cache.
+func (c *ValidationCache) Stats() ValidationCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Len = c.lru.Len()
+	return stats
+}
+
+// Purge removes all entries.
+func (c *ValidationCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[validationCacheKey]*list.Element)
+	c.lru.Init()
+}
+
+// contains returns whether the validation is cached and counts the hit or
+// miss.
+func (c *ValidationCache) contains(key validationCacheKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if ok && c.ttl > 0 && c.now().After(elem.Value.(*validationCacheEntry).expires) {
+		c.remove(elem)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(elem)
+	return true
+}
+
+// add adds a successful validation, evicting the least recently used entry
+// if the cache is full.
+func (c *ValidationCache) add(key validationCacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*validationCacheEntry).expires = expires
+		c.lru.MoveToFront(elem)
+		return
+	}
+	for c.lru.Len() >= c.capacity {
+		c.remove(c.lru.Back())
+	}
+	entry := &validationCacheEntry{key: key, expires: expires}
+	c.entries[key] = c.lru.PushFront(entry)
+}
+
+// remove evicts the entry of the element.
+func (c *ValidationCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*validationCacheEntry).key)
+	c.stats.Evictions++
+}
+
+// newValidationCacheKey returns the key of the validation, or false if the
+// validation can not be cached because there is no condition or the
+// fulfillment tree can not be digested.
+func newValidationCacheKey(mode Ed25519VerificationMode, ff Fulfillment, condition *Condition, message []byte) (validationCacheKey, bool) {
+	var key validationCacheKey
+	if condition == nil {
+		return key, false
+	}
+	digest, err := fulfillmentDigest(ff)
+	if err != nil {
+		return key, false
+	}
+	key.mode = mode
+	key.condition = sha256.Sum256([]byte(condition.URI()))
+	key.message = sha256.Sum256(message)
+	copy(key.fulfillment[:], digest)
+	return key, true
+}
+
+// fulfillmentDigest returns the SHA-256 digest that identifies the
+// fulfillment tree in the cache. Leaves are digested by their encoding and
+// compound fulfillments by their type, parameters, the digests of their
+// sub-fulfillments and the encodings of their sub-conditions.
+func fulfillmentDigest(ff Fulfillment) ([]byte, error) {
+	h := sha256.New()
+	switch f := derefFulfillment(ff).(type) {
+	case FfPrefixSha256:
+		if f.SubFulfillment == nil {
+			return nil, errors.New("unfulfilled prefix fulfillment")
+		}
+		sub, err := fulfillmentDigest(f.SubFulfillment)
+		if err != nil {
+			return nil, err
+		}
+		writeDigestUint(h, uint32(CTPrefixSha256))
+		writeDigestField(h, f.Prefix)
+		writeDigestUint(h, f.MaxMessageLength)
+		writeDigestField(h, sub)
+
+	case FfThresholdSha256:
+		writeDigestUint(h, uint32(CTThresholdSha256))
+		writeDigestUint(h, uint32(f.Threshold))
+		writeDigestUint(h, uint32(len(f.SubFulfillments)))
+		for _, sff := range f.SubFulfillments {
+			sub, err := fulfillmentDigest(sff)
+			if err != nil {
+				return nil, err
+			}
+			writeDigestField(h, sub)
+		}
+		writeDigestUint(h, uint32(len(f.SubConditions)))
+		for _, sc := range f.SubConditions {
+			if sc == nil {
+				return nil, errors.New("missing sub-condition")
+			}
+			encoded, err := sc.Encode()
+			if err != nil {
+				return nil, err
+			}
+			writeDigestField(h, encoded)
+		}
+
+	default:
+		if ff == nil {
+			return nil, errors.New("missing fulfillment")
+		}
+		encoded, err := ff.Encode()
+		if err != nil {
+			return nil, err
+		}
+		writeDigestField(h, encoded)
+	}
+	return h.Sum(nil), nil
+}
+
+// writeDigestUint writes v to the digest in big-endian order.
+func writeDigestUint(h hash.Hash, v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	h.Write(buf[:])
+}
+
+// writeDigestField writes b to the digest, preceded by its length so that
+// consecutive fields can not be confused.
+func writeDigestField(h hash.Hash, b []byte) {
+	writeDigestUint(h, uint32(len(b)))
+	h.Write(b)
+}
+
+// validateCached calls validate, unless the cache of the options holds the
+// validation. Successful validations are added to the cache.
+func validateCached(ff Fulfillment, condition *Condition, message []byte, opts *ValidationOptions, validate func() error) error {
+	if opts == nil || opts.Cache == nil {
+		return validate()
+	}
+	key, ok := newValidationCacheKey(opts.ed25519Mode(), ff, condition, message)
+	if !ok {
+		return validate()
+	}
+	if opts.Cache.contains(key) {
+		return nil
+	}
+	if err := validate(); err != nil {
+		return err
+	}
+	opts.Cache.add(key)
+	return nil
+}
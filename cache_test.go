@@ -0,0 +1,124 @@
+package cryptoconditions
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestValidationCache(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	message := []byte("payment 42")
+	ff, err := NewEd25519Sha256(priv.Public().(ed25519.PublicKey), ed25519.Sign(priv, message))
+	require.NoError(t, err)
+	cond := ff.Condition()
+
+	cache := NewValidationCache(2, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	opts := &ValidationOptions{Cache: cache}
+
+	assert.NoError(t, ValidateWithOptions(ff, cond, message, opts))
+	assert.NoError(t, ValidateWithOptions(ff, cond, message, opts))
+	assert.NoError(t, ValidateContext(context.Background(), ff, cond, message, opts))
+	assert.Equal(t, ValidationCacheStats{Hits: 2, Misses: 1, Len: 1}, cache.Stats())
+
+	// Failed validations are not cached.
+	assert.Error(t, ValidateWithOptions(ff, cond, []byte("other"), opts))
+	assert.Error(t, ValidateWithOptions(ff, cond, []byte("other"), opts))
+	assert.Equal(t, ValidationCacheStats{Hits: 2, Misses: 3, Len: 1}, cache.Stats())
+
+	// The verification mode is part of the key.
+	zip215 := &ValidationOptions{Ed25519Mode: Ed25519ZIP215, Cache: cache}
+	assert.NoError(t, ValidateWithOptions(ff, cond, message, zip215))
+	assert.Equal(t, ValidationCacheStats{Hits: 2, Misses: 4, Len: 2}, cache.Stats())
+
+	// Entries expire.
+	now = now.Add(2 * time.Minute)
+	assert.NoError(t, ValidateWithOptions(ff, cond, message, opts))
+	assert.Equal(t, ValidationCacheStats{Hits: 2, Misses: 5, Evictions: 1, Len: 2}, cache.Stats())
+
+	// The least recently used entry, the one of the ZIP-215 mode, is evicted
+	// when the cache is full.
+	assert.NoError(t, ValidateWithOptions(ff, cond, message, opts))
+	other, err := NewEd25519Sha256(priv.Public().(ed25519.PublicKey), ed25519.Sign(priv, []byte("other")))
+	require.NoError(t, err)
+	assert.NoError(t, ValidateWithOptions(other, cond, []byte("other"), opts))
+	assert.Equal(t, ValidationCacheStats{Hits: 3, Misses: 6, Evictions: 2, Len: 2}, cache.Stats())
+	assert.NoError(t, ValidateWithOptions(ff, cond, message, opts))
+	assert.Equal(t, uint64(4), cache.Stats().Hits)
+
+	// Validations without a condition are not cached.
+	stats := cache.Stats()
+	assert.NoError(t, ValidateWithOptions(ff, nil, message, opts))
+	assert.Equal(t, stats, cache.Stats())
+
+	cache.Purge()
+	assert.Equal(t, 0, cache.Stats().Len)
+}
+
+func TestBatchValidator_cache(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ff, err := NewEd25519Sha256(priv.Public().(ed25519.PublicKey), ed25519.Sign(priv, []byte("pa")))
+	require.NoError(t, err)
+	prefixed := NewPrefixSha256([]byte("p"), 16, ff)
+
+	cache := NewValidationCache(16, 0)
+	b := NewBatchValidator(&ValidationOptions{Ed25519Mode: Ed25519ZIP215, Cache: cache})
+	b.Add(prefixed, prefixed.Condition(), []byte("a"))
+	b.Add(prefixed, prefixed.Condition(), []byte("b"))
+
+	results := b.Validate()
+	assert.NoError(t, results[0])
+	assert.Error(t, results[1])
+	assert.Equal(t, ValidationCacheStats{Misses: 2, Len: 1}, cache.Stats())
+
+	// The valid job is found in the cache, the invalid one is validated
+	// again.
+	again := b.Validate()
+	assert.NoError(t, again[0])
+	assert.EqualError(t, again[1], results[1].Error())
+	assert.Equal(t, ValidationCacheStats{Hits: 1, Misses: 3, Len: 1}, cache.Stats())
+}
+
+func TestValidationCache_compound(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	message := []byte("payment 42")
+	edFf, err := NewEd25519Sha256(priv.Public().(ed25519.PublicKey), ed25519.Sign(priv, message))
+	require.NoError(t, err)
+	preimageCond := NewPreimageSha256([]byte("secret")).Condition()
+
+	// Thresholds are cached although they can not be encoded.
+	ff := NewThresholdSha256(1, []Fulfillment{edFf}, []*Condition{preimageCond})
+	cond := ff.Condition()
+	cache := NewValidationCache(4, 0)
+	opts := &ValidationOptions{Cache: cache}
+	assert.NoError(t, ValidateWithOptions(ff, cond, message, opts))
+	assert.NoError(t, ValidateWithOptions(ff, cond, message, opts))
+	assert.Equal(t, ValidationCacheStats{Hits: 1, Misses: 1, Len: 1}, cache.Stats())
+
+	// The digest covers the parameters and the sub-conditions.
+	digest, err := fulfillmentDigest(ff)
+	require.NoError(t, err)
+	for _, other := range []Fulfillment{
+		NewThresholdSha256(2, []Fulfillment{edFf}, []*Condition{preimageCond}),
+		NewThresholdSha256(1, []Fulfillment{edFf},
+			[]*Condition{NewPreimageSha256([]byte("other")).Condition()}),
+		NewThresholdSha256(1, []Fulfillment{edFf}, nil),
+		NewPrefixSha256(nil, 0, edFf),
+	} {
+		otherDigest, err := fulfillmentDigest(other)
+		require.NoError(t, err)
+		assert.NotEqual(t, digest, otherDigest)
+	}
+	_, err = fulfillmentDigest(NewPrefixSha256Unfulfilled(nil, 0, preimageCond))
+	assert.Error(t, err)
+}
@@ -1,6 +1,9 @@
 package cryptoconditions
 
-import "bytes"
+import (
+	"bytes"
+	"reflect"
+)
 
 // max returns the highest of both integers.
 func max(a, b int) int {
@@ -18,6 +21,33 @@ func min(a, b int) int {
 	return b
 }
 
+// derefFulfillment returns the value form of a fulfillment that is passed as
+// a pointer, so that callers only have to type switch on the Ff* structs.
+// Fulfillments returned by DecodeFulfillment and the constructors are
+// pointers, while literals are often values.
+func derefFulfillment(ff Fulfillment) Fulfillment {
+	v := reflect.ValueOf(ff)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		if deref, ok := v.Elem().Interface().(Fulfillment); ok {
+			return deref
+		}
+	}
+	return ff
+}
+
+// conditionOf returns the condition of the fulfillment, or an error if it
+// can not be computed because the fulfillment tree contains a sub-condition
+// of an unknown type.
+func conditionOf(ff Fulfillment) (*Condition, error) {
+	switch f := derefFulfillment(ff).(type) {
+	case FfPrefixSha256:
+		return f.condition()
+	case FfThresholdSha256:
+		return f.condition()
+	}
+	return ff.Condition(), nil
+}
+
 // fulfills determines if the fulfillment is able to fulfill the condition.
 // The trivial way of doing this is to compare the ff.Condition() with the
 // condition. However, this requires the generation of the condition while we
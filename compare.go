@@ -0,0 +1,247 @@
+package cryptoconditions
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// FulfillmentsEqual returns true if both fulfillments are structurally equal,
+// including their signatures, preimages and the order of the children of
+// threshold fulfillments. Pointer and value forms of the same fulfillment are
+// considered equal.
+func FulfillmentsEqual(a, b Fulfillment) bool {
+	return compareFulfillments(a, b, false)
+}
+
+// FulfillmentsEquivalent returns true if both fulfillments are equal except
+// for the order of the sub-fulfillments and sub-conditions of threshold
+// fulfillments. Equivalent fulfillments fulfill the same condition.
+func FulfillmentsEquivalent(a, b Fulfillment) bool {
+	return compareFulfillments(a, b, true)
+}
+
+// compareFulfillments compares both fulfillments. If unordered is true, the
+// children of threshold fulfillments may appear in any order.
+func compareFulfillments(a, b Fulfillment, unordered bool) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch fa := derefFulfillment(a).(type) {
+	case FfPreimageSha256:
+		fb, ok := derefFulfillment(b).(FfPreimageSha256)
+		return ok && bytes.Equal(fa.Preimage, fb.Preimage)
+
+	case FfPrefixSha256:
+		fb, ok := derefFulfillment(b).(FfPrefixSha256)
+		return ok &&
+			bytes.Equal(fa.Prefix, fb.Prefix) &&
+			fa.MaxMessageLength == fb.MaxMessageLength &&
+			compareFulfillments(fa.SubFulfillment, fb.SubFulfillment, unordered) &&
+			conditionsEqual(fa.subCondition, fb.subCondition)
+
+	case FfThresholdSha256:
+		fb, ok := derefFulfillment(b).(FfThresholdSha256)
+		if !ok || fa.Threshold != fb.Threshold ||
+			len(fa.SubFulfillments) != len(fb.SubFulfillments) ||
+			len(fa.SubConditions) != len(fb.SubConditions) {
+			return false
+		}
+		if unordered {
+			return matchUnordered(len(fa.SubFulfillments), func(i, j int) bool {
+				return compareFulfillments(fa.SubFulfillments[i], fb.SubFulfillments[j], true)
+			}) && matchUnordered(len(fa.SubConditions), func(i, j int) bool {
+				return conditionsEqual(fa.SubConditions[i], fb.SubConditions[j])
+			})
+		}
+		for i := range fa.SubFulfillments {
+			if !compareFulfillments(fa.SubFulfillments[i], fb.SubFulfillments[i], false) {
+				return false
+			}
+		}
+		for i := range fa.SubConditions {
+			if !conditionsEqual(fa.SubConditions[i], fb.SubConditions[i]) {
+				return false
+			}
+		}
+		return true
+
+	case FfRsaSha256:
+		fb, ok := derefFulfillment(b).(FfRsaSha256)
+		return ok &&
+			bytes.Equal(fa.Modulus, fb.Modulus) &&
+			bytes.Equal(fa.Signature, fb.Signature)
+
+	case FfEd25519Sha256:
+		fb, ok := derefFulfillment(b).(FfEd25519Sha256)
+		return ok &&
+			bytes.Equal(fa.PublicKey, fb.PublicKey) &&
+			bytes.Equal(fa.Signature, fb.Signature)
+
+	case FfSecp256k1Sha256:
+		fb, ok := derefFulfillment(b).(FfSecp256k1Sha256)
+		return ok &&
+			bytes.Equal(fa.PublicKey, fb.PublicKey) &&
+			bytes.Equal(fa.Signature, fb.Signature)
+
+	case FfEcdsaP256Sha256:
+		fb, ok := derefFulfillment(b).(FfEcdsaP256Sha256)
+		return ok &&
+			bytes.Equal(fa.PublicKey, fb.PublicKey) &&
+			bytes.Equal(fa.Signature, fb.Signature)
+
+	case FfWotsSha256:
+		fb, ok := derefFulfillment(b).(FfWotsSha256)
+		return ok &&
+			bytes.Equal(fa.PublicSeed, fb.PublicSeed) &&
+			bytes.Equal(fa.PublicKeyHash, fb.PublicKeyHash) &&
+			bytes.Equal(fa.Signature, fb.Signature)
+
+	case FfWebAuthnSha256:
+		fb, ok := derefFulfillment(b).(FfWebAuthnSha256)
+		return ok &&
+			bytes.Equal(fa.PublicKey, fb.PublicKey) &&
+			bytes.Equal(fa.RpIdHash, fb.RpIdHash) &&
+			bytes.Equal(fa.AuthenticatorData, fb.AuthenticatorData) &&
+			bytes.Equal(fa.ClientDataJSON, fb.ClientDataJSON) &&
+			bytes.Equal(fa.Signature, fb.Signature)
+	}
+	return false
+}
+
+// matchUnordered returns true if every element i of a list of n elements
+// can be paired with a distinct element j of another list of n elements for
+// which equal(i, j) holds. Since equal is an equivalence relation, pairing
+// greedily is sufficient.
+func matchUnordered(n int, equal func(i, j int) bool) bool {
+	used := make([]bool, n)
+	for i := 0; i < n; i++ {
+		found := false
+		for j := 0; j < n; j++ {
+			if !used[j] && equal(i, j) {
+				used[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// conditionsEqual is like Condition.Equals, but also accepts nil conditions.
+func conditionsEqual(a, b *Condition) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equals(b)
+}
+
+// Clone returns a deep copy of the fulfillment tree that does not share any
+// memory with the original. The copy of every node is returned in its pointer
+// form, like the fulfillments returned by DecodeFulfillment.
+func Clone(ff Fulfillment) Fulfillment {
+	if ff == nil {
+		return nil
+	}
+
+	switch f := derefFulfillment(ff).(type) {
+	case FfPreimageSha256:
+		return &FfPreimageSha256{
+			Preimage: cloneBytes(f.Preimage),
+		}
+
+	case FfPrefixSha256:
+		return &FfPrefixSha256{
+			Prefix:           cloneBytes(f.Prefix),
+			MaxMessageLength: f.MaxMessageLength,
+			SubFulfillment:   Clone(f.SubFulfillment),
+			subCondition:     cloneCondition(f.subCondition),
+		}
+
+	case FfThresholdSha256:
+		clone := &FfThresholdSha256{
+			Threshold: f.Threshold,
+		}
+		if f.SubFulfillments != nil {
+			clone.SubFulfillments = make([]Fulfillment, len(f.SubFulfillments))
+			for i, sff := range f.SubFulfillments {
+				clone.SubFulfillments[i] = Clone(sff)
+			}
+		}
+		if f.SubConditions != nil {
+			clone.SubConditions = make([]*Condition, len(f.SubConditions))
+			for i, sc := range f.SubConditions {
+				clone.SubConditions[i] = cloneCondition(sc)
+			}
+		}
+		return clone
+
+	case FfRsaSha256:
+		return &FfRsaSha256{
+			Modulus:   cloneBytes(f.Modulus),
+			Signature: cloneBytes(f.Signature),
+		}
+
+	case FfEd25519Sha256:
+		return &FfEd25519Sha256{
+			PublicKey: cloneBytes(f.PublicKey),
+			Signature: cloneBytes(f.Signature),
+		}
+
+	case FfSecp256k1Sha256:
+		return &FfSecp256k1Sha256{
+			PublicKey: cloneBytes(f.PublicKey),
+			Signature: cloneBytes(f.Signature),
+		}
+
+	case FfEcdsaP256Sha256:
+		return &FfEcdsaP256Sha256{
+			PublicKey: cloneBytes(f.PublicKey),
+			Signature: cloneBytes(f.Signature),
+		}
+
+	case FfWebAuthnSha256:
+		return &FfWebAuthnSha256{
+			PublicKey:         cloneBytes(f.PublicKey),
+			RpIdHash:          cloneBytes(f.RpIdHash),
+			AuthenticatorData: cloneBytes(f.AuthenticatorData),
+			ClientDataJSON:    cloneBytes(f.ClientDataJSON),
+			Signature:         cloneBytes(f.Signature),
+		}
+
+	case FfWotsSha256:
+		return &FfWotsSha256{
+			PublicSeed:    cloneBytes(f.PublicSeed),
+			PublicKeyHash: cloneBytes(f.PublicKeyHash),
+			Signature:     cloneBytes(f.Signature),
+		}
+	}
+	panic(fmt.Sprintf("cannot clone fulfillment of type %T", ff))
+}
+
+// cloneCondition returns a deep copy of the condition.
+func cloneCondition(c *Condition) *Condition {
+	if c == nil {
+		return nil
+	}
+	return &Condition{
+		conditionType: c.conditionType,
+		fingerprint:   cloneBytes(c.fingerprint),
+		cost:          c.cost,
+		subTypes: ConditionTypeSet{
+			Bytes:     cloneBytes(c.subTypes.Bytes),
+			BitLength: c.subTypes.BitLength,
+		},
+	}
+}
+
+// cloneBytes returns a copy of b, preserving nil.
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
@@ -0,0 +1,82 @@
+package cryptoconditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFulfillmentsEqual(t *testing.T) {
+	a := testNotarizedReceipt(t)
+	b := testNotarizedReceipt(t)
+
+	assert.True(t, FulfillmentsEqual(a, b))
+	assert.True(t, FulfillmentsEqual(a, *b))
+	assert.False(t, FulfillmentsEqual(a, nil))
+	assert.True(t, FulfillmentsEqual(nil, nil))
+
+	// Signatures are compared as well.
+	b.SubFulfillments[0].(*FfPrefixSha256).SubFulfillment.(*FfEd25519Sha256).Signature[0] ^= 0xff
+	assert.False(t, FulfillmentsEqual(a, b))
+
+	// Different types never match.
+	assert.False(t, FulfillmentsEqual(NewPreimageSha256(nil), NewPrefixSha256(nil, 0, NewPreimageSha256(nil))))
+}
+
+func TestFulfillmentsEquivalent(t *testing.T) {
+	a := testNotarizedReceipt(t)
+	b := testNotarizedReceipt(t)
+	b.SubFulfillments[0], b.SubFulfillments[1] = b.SubFulfillments[1], b.SubFulfillments[0]
+
+	assert.False(t, FulfillmentsEqual(a, b))
+	assert.True(t, FulfillmentsEquivalent(a, b))
+
+	// The number of times a child appears matters.
+	c := testNotarizedReceipt(t)
+	c.SubFulfillments[0] = c.SubFulfillments[1]
+	assert.False(t, FulfillmentsEquivalent(a, c))
+	assert.False(t, FulfillmentsEquivalent(c, a))
+
+	// Sub-conditions are compared without order as well.
+	cond1 := NewPreimageSha256([]byte("one")).Condition()
+	cond2 := NewPreimageSha256([]byte("two")).Condition()
+	a.SubConditions = []*Condition{cond1, cond2}
+	b.SubConditions = []*Condition{cond2, cond1}
+	assert.True(t, FulfillmentsEquivalent(a, b))
+	b.SubConditions = []*Condition{cond2, cond2}
+	assert.False(t, FulfillmentsEquivalent(a, b))
+}
+
+func TestClone(t *testing.T) {
+	ff := testNotarizedReceipt(t)
+	ff.SubConditions = []*Condition{NewPreimageSha256([]byte("hidden")).Condition()}
+
+	clone := Clone(ff)
+	require.True(t, FulfillmentsEqual(ff, clone))
+
+	// Modifying the clone leaves the original untouched.
+	cloned := clone.(*FfThresholdSha256)
+	cloned.SubFulfillments[0].(*FfPrefixSha256).Prefix[0] = 'X'
+	cloned.SubFulfillments[0].(*FfPrefixSha256).SubFulfillment.(*FfEd25519Sha256).Signature[0] ^= 0xff
+	cloned.SubFulfillments[1].(*FfPreimageSha256).Preimage[0] = 'X'
+	cloned.SubConditions[0].Fingerprint()[0] ^= 0xff
+	assert.False(t, FulfillmentsEqual(ff, clone))
+	assert.True(t, FulfillmentsEqual(ff, testNotarizedReceiptWithHidden(t)))
+
+	// Values are cloned into pointers.
+	value := FfPreimageSha256{Preimage: []byte("value")}
+	valueClone, ok := Clone(value).(*FfPreimageSha256)
+	require.True(t, ok)
+	assert.Equal(t, value.Preimage, valueClone.Preimage)
+
+	// Unfulfilled prefix fulfillments keep their sub-condition.
+	unfulfilled := NewPrefixSha256Unfulfilled([]byte("p"), 0, NewPreimageSha256([]byte("x")).Condition())
+	assert.True(t, FulfillmentsEqual(unfulfilled, Clone(unfulfilled)))
+}
+
+func testNotarizedReceiptWithHidden(t *testing.T) *FfThresholdSha256 {
+	ff := testNotarizedReceipt(t)
+	ff.SubConditions = []*Condition{NewPreimageSha256([]byte("hidden")).Condition()}
+	return ff
+}
@@ -23,23 +23,44 @@ const (
 	CTRsaSha256
 	// ED25519
 	CTEd25519Sha256
+)
 
-	// nbKnownConditionTypes is the number of known condition types.
-	// Assuming all code up till this number are known, comparing a code with
-	// nbKnownConditionTypes determines whether the code is known or not.
-	// This number should always be equal to
-	// `len(conditionTypeMap)` and `len(fulfillmentTypeMap)`.
-	nbKnownConditionTypes
+// The experimental condition types take their type codes from a range that
+// is reserved for them, away from the codes of the specification. The range
+// ends below 31 so that every type code fits in a single-byte ASN.1 tag.
+const (
+	// experimentalConditionTypeBase is the first type code of the range.
+	experimentalConditionTypeBase ConditionType = 16
+	// nbExperimentalConditionTypeCodes is the size of the range.
+	nbExperimentalConditionTypeCodes = 15
+)
+
+// All the experimental condition types and their corresponding type codes.
+// These types are not part of the specification. Their type codes and
+// encodings may change and other implementations will not accept them.
+const (
+	// SECP256K1-SHA-256
+	CTSecp256k1Sha256 ConditionType = experimentalConditionTypeBase + iota
+	// ECDSA-P256-SHA-256
+	CTEcdsaP256Sha256
+	// WEBAUTHN-SHA-256
+	CTWebAuthnSha256
+	// WOTS-SHA-256
+	CTWotsSha256
 )
 
 // conditionTypeDictionary maps condition type names to the corresponding
 // condition types.
 var conditionTypeDictionary = map[string]ConditionType{
-	"PREIMAGE-SHA-256":  CTPreimageSha256,
-	"PREFIX-SHA-256":    CTPrefixSha256,
-	"THRESHOLD-SHA-256": CTThresholdSha256,
-	"RSA-SHA-256":       CTRsaSha256,
-	"ED25519-SHA-256":   CTEd25519Sha256,
+	"PREIMAGE-SHA-256":   CTPreimageSha256,
+	"PREFIX-SHA-256":     CTPrefixSha256,
+	"THRESHOLD-SHA-256":  CTThresholdSha256,
+	"RSA-SHA-256":        CTRsaSha256,
+	"ED25519-SHA-256":    CTEd25519Sha256,
+	"SECP256K1-SHA-256":  CTSecp256k1Sha256,
+	"ECDSA-P256-SHA-256": CTEcdsaP256Sha256,
+	"WEBAUTHN-SHA-256":   CTWebAuthnSha256,
+	"WOTS-SHA-256":       CTWotsSha256,
 }
 
 // IsCompound returns true for compound condition types that have subtypes.
@@ -55,6 +76,14 @@ func (t ConditionType) IsCompound() bool {
 		return false
 	case CTEd25519Sha256:
 		return false
+	case CTSecp256k1Sha256:
+		return false
+	case CTEcdsaP256Sha256:
+		return false
+	case CTWebAuthnSha256:
+		return false
+	case CTWotsSha256:
+		return false
 	}
 	panic(fmt.Sprintf("ConditionType %d does not exist", t))
 }
@@ -71,10 +100,27 @@ func (t ConditionType) String() string {
 		return "RSA-SHA-256"
 	case CTEd25519Sha256:
 		return "ED25519-SHA-256"
+	case CTSecp256k1Sha256:
+		return "SECP256K1-SHA-256"
+	case CTEcdsaP256Sha256:
+		return "ECDSA-P256-SHA-256"
+	case CTWebAuthnSha256:
+		return "WEBAUTHN-SHA-256"
+	case CTWotsSha256:
+		return "WOTS-SHA-256"
 	}
 	panic(fmt.Sprintf("ConditionType %d does not exist", t))
 }
 
+// IsExperimental returns true for condition types that are not part of the
+// specification, which are those with a type code in the range reserved for
+// experimental types. Conditions of these types are only understood by this
+// implementation and their encoding may change.
+func (t ConditionType) IsExperimental() bool {
+	return t >= experimentalConditionTypeBase &&
+		t < experimentalConditionTypeBase+nbExperimentalConditionTypeCodes
+}
+
 // ConditionTypeSet represents a set of ConditionTypes.
 // It is represented as an ASN.1 BIT STRING like defined in the specification.
 type ConditionTypeSet asn1.BitString
@@ -85,7 +131,7 @@ func (c ConditionTypeSet) Has(conditionType ConditionType) bool {
 }
 
 func (c ConditionTypeSet) AllTypes() []ConditionType {
-	all := make([]ConditionType, 0, nbKnownConditionTypes)
+	all := make([]ConditionType, 0, len(conditionTypeDictionary))
 	for i := 0; i < c.BitLength; i++ {
 		ct := ConditionType(i)
 		if c.Has(ct) {
@@ -169,6 +215,10 @@ func (c *ConditionTypeSet) addRelevant(element interface{}) {
 		cond := element.(Condition)
 		c.add(cond.Type())
 		c.addAll(cond.SubTypes())
+	case *Condition:
+		cond := element.(*Condition)
+		c.add(cond.Type())
+		c.addAll(cond.SubTypes())
 	}
 }
 
@@ -156,6 +156,32 @@ func TestConditionTypeSet_addRelevant(t *testing.T) {
 	}
 }
 
+func TestConditionType_IsExperimental(t *testing.T) {
+	for _, ct := range conditionTypeDictionary {
+		inRange := ct >= experimentalConditionTypeBase &&
+			ct < experimentalConditionTypeBase+nbExperimentalConditionTypeCodes
+		assert.Equal(t, inRange, ct.IsExperimental(), ct.String())
+		if !inRange {
+			assert.True(t, ct <= CTEd25519Sha256, ct.String())
+		}
+	}
+	assert.True(t, CTWotsSha256.IsExperimental())
+	assert.False(t, ConditionType(5).IsExperimental())
+	assert.False(t, (experimentalConditionTypeBase + nbExperimentalConditionTypeCodes).IsExperimental())
+	// The type codes must fit in single-byte ASN.1 tags.
+	assert.True(t, experimentalConditionTypeBase+nbExperimentalConditionTypeCodes <= 31)
+
+	// Experimental subtypes survive a URI round trip.
+	var subTypes ConditionTypeSet
+	subTypes.add(CTEd25519Sha256)
+	subTypes.add(CTWotsSha256)
+	cond := NewCompoundCondition(CTPrefixSha256, make([]byte, 32), 1024, subTypes)
+	assert.Equal(t, []ConditionType{CTEd25519Sha256, CTWotsSha256}, cond.SubTypes().AllTypes())
+	parsed, err := ParseURI(cond.URI())
+	require.NoError(t, err)
+	assert.True(t, parsed.Equals(cond))
+}
+
 func TestDecodeCondition_Preimage(t *testing.T) {
 	encoding := unhex("A0258020E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855810100")
 	uri := "ni:///sha-256;47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU?fpt=preimage-sha-256&cost=0"
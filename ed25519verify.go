@@ -0,0 +1,128 @@
+package cryptoconditions
+
+import (
+	"bytes"
+	"crypto/sha512"
+
+	"filippo.io/edwards25519"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Ed25519VerificationMode selects the rules used to verify Ed25519
+// signatures. Implementations of Ed25519 disagree on signatures with
+// non-canonical encodings and on keys and signatures involving points of
+// small order, so validators that must agree on the validity of fulfillments
+// should select the same mode explicitly.
+type Ed25519VerificationMode int
+
+const (
+	// Ed25519Default verifies signatures with ed25519.Verify. It rejects
+	// non-canonical signature scalars and R encodings, accepts
+	// non-canonical public key encodings and uses the cofactorless
+	// verification equation.
+	Ed25519Default Ed25519VerificationMode = iota
+	// Ed25519Strict follows RFC 8032 strictly: the public key, R and S must
+	// be canonically encoded and the cofactorless verification equation is
+	// used.
+	Ed25519Strict
+	// Ed25519ZIP215 follows the ZIP-215 rules: non-canonical encodings of the
+	// public key and R are accepted, S must be canonical and the cofactored
+	// verification equation is used. This makes batch and single
+	// verification agree.
+	Ed25519ZIP215
+	// Ed25519RejectSmallOrder applies the strict RFC 8032 rules and
+	// additionally rejects public keys of small order, for which signatures
+	// can be valid for many messages.
+	Ed25519RejectSmallOrder
+)
+
+func (m Ed25519VerificationMode) String() string {
+	switch m {
+	case Ed25519Default:
+		return "default"
+	case Ed25519Strict:
+		return "strict"
+	case Ed25519ZIP215:
+		return "ZIP-215"
+	case Ed25519RejectSmallOrder:
+		return "reject-small-order"
+	}
+	return "unknown"
+}
+
+// verifyEd25519 verifies an Ed25519 signature with the rules of the mode.
+func verifyEd25519(mode Ed25519VerificationMode, pubkey, message, signature []byte) error {
+	if len(pubkey) != ed25519.PublicKeySize {
+		return errors.Errorf("wrong pubkey size (%d)", len(pubkey))
+	}
+	if len(signature) != ed25519.SignatureSize {
+		return errors.Errorf("wrong signature size (%d)", len(signature))
+	}
+	if mode == Ed25519Default {
+		if !ed25519.Verify(pubkey, message, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	encodedR, encodedS := signature[:32], signature[32:]
+	A, err := new(edwards25519.Point).SetBytes(pubkey)
+	if err != nil {
+		return errors.New("invalid public key encoding")
+	}
+	R, err := new(edwards25519.Point).SetBytes(encodedR)
+	if err != nil {
+		return errors.New("invalid R encoding")
+	}
+	S, err := edwards25519.NewScalar().SetCanonicalBytes(encodedS)
+	if err != nil {
+		return errors.New("non-canonical S")
+	}
+
+	switch mode {
+	case Ed25519Strict, Ed25519RejectSmallOrder:
+		if !bytes.Equal(A.Bytes(), pubkey) {
+			return errors.New("non-canonical public key encoding")
+		}
+		if !bytes.Equal(R.Bytes(), encodedR) {
+			return errors.New("non-canonical R encoding")
+		}
+		if mode == Ed25519RejectSmallOrder && isSmallOrder(A) {
+			return errors.New("public key has small order")
+		}
+	case Ed25519ZIP215:
+	default:
+		return errors.Errorf("unknown Ed25519 verification mode %d", mode)
+	}
+
+	// The challenge is computed over the encodings as given.
+	h := sha512.New()
+	h.Write(encodedR)
+	h.Write(pubkey)
+	h.Write(message)
+	k, _ := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+
+	// check = [S]B - [k]A, which must equal R. The point is negated rather
+	// than k, since -k mod L would change the torsion component of [k]A.
+	minusA := new(edwards25519.Point).Negate(A)
+	check := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(k, minusA, S)
+	if mode == Ed25519ZIP215 {
+		// [8]([S]B - [k]A - R) must be the identity.
+		check.Subtract(check, R)
+		if !isSmallOrder(check) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	if check.Equal(R) != 1 {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// isSmallOrder returns whether the point is in the torsion subgroup of order
+// 8, including the identity.
+func isSmallOrder(p *edwards25519.Point) bool {
+	return new(edwards25519.Point).MultByCofactor(p).Equal(edwards25519.NewIdentityPoint()) == 1
+}
@@ -0,0 +1,139 @@
+package cryptoconditions
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"testing"
+
+	"filippo.io/edwards25519"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+var ed25519Modes = []Ed25519VerificationMode{
+	Ed25519Default, Ed25519Strict, Ed25519ZIP215, Ed25519RejectSmallOrder,
+}
+
+const (
+	// ed25519IdentityEncoding is the canonical encoding of the identity point.
+	ed25519IdentityEncoding = "0100000000000000000000000000000000000000000000000000000000000000"
+	// ed25519IdentityNonCanonical encodes the identity with y = p + 1.
+	ed25519IdentityNonCanonical = "eeffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f"
+	// ed25519OrderTwoEncoding is the canonical encoding of the point (0, -1)
+	// of order 2.
+	ed25519OrderTwoEncoding = "ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f"
+	// ed25519ZeroScalar is the encoding of S = 0.
+	ed25519ZeroScalar = "0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+// ed25519MixedOrderVector returns a signature by a public key with a
+// torsion component of order 2, for which the cofactored and cofactorless
+// verification equations disagree.
+func ed25519MixedOrderVector(t *testing.T) (pubkey, message, signature []byte) {
+	var seed [64]byte
+	_, err := rand.Read(seed[:])
+	require.NoError(t, err)
+	a, err := edwards25519.NewScalar().SetUniformBytes(seed[:])
+	require.NoError(t, err)
+	torsion, err := new(edwards25519.Point).SetBytes(unhex(ed25519OrderTwoEncoding))
+	require.NoError(t, err)
+	A := new(edwards25519.Point).ScalarBaseMult(a)
+	A.Add(A, torsion)
+	pubkey = A.Bytes()
+
+	for i := 0; ; i++ {
+		message = []byte{byte(i)}
+		_, err := rand.Read(seed[:])
+		require.NoError(t, err)
+		r, err := edwards25519.NewScalar().SetUniformBytes(seed[:])
+		require.NoError(t, err)
+		R := new(edwards25519.Point).ScalarBaseMult(r).Bytes()
+
+		h := sha512.New()
+		h.Write(R)
+		h.Write(pubkey)
+		h.Write(message)
+		k, err := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+		require.NoError(t, err)
+		// Only odd challenges make the torsion component matter.
+		if k.Bytes()[0]&1 == 0 {
+			continue
+		}
+		S := edwards25519.NewScalar().MultiplyAdd(k, a, r)
+		return pubkey, message, append(R, S.Bytes()...)
+	}
+}
+
+func TestVerifyEd25519_modes(t *testing.T) {
+	pubkey, privkey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	message := []byte("hello")
+	signature := ed25519.Sign(privkey, message)
+
+	// S + L, a non-canonical encoding of S.
+	S, err := edwards25519.NewScalar().SetCanonicalBytes(signature[32:])
+	require.NoError(t, err)
+	order := unhex("edd3f55c1a631258d69cf7a2def9de1400000000000000000000000000000010")
+	nonCanonicalS := append([]byte{}, signature[:32]...)
+	var carry int
+	for i, b := range S.Bytes() {
+		sum := int(b) + int(order[i]) + carry
+		nonCanonicalS = append(nonCanonicalS, byte(sum))
+		carry = sum >> 8
+	}
+
+	mixedKey, mixedMessage, mixedSignature := ed25519MixedOrderVector(t)
+
+	vectors := []struct {
+		name             string
+		pubkey, msg, sig []byte
+		valid            [4]bool // in the order of ed25519Modes
+	}{
+		{"regular signature", pubkey, message, signature,
+			[4]bool{true, true, true, true}},
+		{"non-canonical S", pubkey, message, nonCanonicalS,
+			[4]bool{false, false, false, false}},
+		{"small order public key",
+			unhex(ed25519IdentityEncoding), message,
+			unhex(ed25519IdentityEncoding + ed25519ZeroScalar),
+			[4]bool{true, true, true, false}},
+		{"non-canonical public key",
+			unhex(ed25519IdentityNonCanonical), message,
+			unhex(ed25519IdentityEncoding + ed25519ZeroScalar),
+			[4]bool{true, false, true, false}},
+		{"non-canonical R",
+			unhex(ed25519IdentityEncoding), message,
+			unhex(ed25519IdentityNonCanonical + ed25519ZeroScalar),
+			[4]bool{false, false, true, false}},
+		{"mixed order public key", mixedKey, mixedMessage, mixedSignature,
+			[4]bool{false, false, true, false}},
+	}
+
+	for _, v := range vectors {
+		for i, mode := range ed25519Modes {
+			err := verifyEd25519(mode, v.pubkey, v.msg, v.sig)
+			assert.Equal(t, v.valid[i], err == nil,
+				"%s in %s mode: %v", v.name, mode, err)
+		}
+	}
+}
+
+func TestValidateWithOptions(t *testing.T) {
+	ff, err := NewEd25519Sha256(unhex(ed25519IdentityEncoding),
+		unhex(ed25519IdentityEncoding+ed25519ZeroScalar))
+	require.NoError(t, err)
+	tree := NewThresholdSha256(1, []Fulfillment{
+		NewPrefixSha256([]byte("p"), 16, ff),
+	}, nil)
+	cond := tree.Condition()
+	message := []byte("any message")
+
+	// The small order key signs every message.
+	assert.NoError(t, tree.Validate(cond, message))
+	assert.NoError(t, ValidateWithOptions(tree, cond, message, nil))
+	assert.NoError(t, ValidateWithOptions(tree, cond, message,
+		&ValidationOptions{Ed25519Mode: Ed25519Strict}))
+	assert.Error(t, ValidateWithOptions(tree, cond, message,
+		&ValidationOptions{Ed25519Mode: Ed25519RejectSmallOrder}))
+}
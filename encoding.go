@@ -1,8 +1,10 @@
 package cryptoconditions
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
+	"sort"
 
 	"github.com/pkg/errors"
 	"github.com/stevenroose/asn1"
@@ -47,47 +49,98 @@ type encodableEd25519Sha256 struct {
 	Cost        int    `asn1:"tag:1"`
 }
 
+type encodableSecp256k1Sha256 struct {
+	Fingerprint []byte `asn1:"tag:0"`
+	Cost        int    `asn1:"tag:1"`
+}
+
+type encodableEcdsaP256Sha256 struct {
+	Fingerprint []byte `asn1:"tag:0"`
+	Cost        int    `asn1:"tag:1"`
+}
+
+type encodableWebAuthnSha256 struct {
+	Fingerprint []byte `asn1:"tag:0"`
+	Cost        int    `asn1:"tag:1"`
+}
+
+type encodableWotsSha256 struct {
+	Fingerprint []byte `asn1:"tag:0"`
+	Cost        int    `asn1:"tag:1"`
+}
+
 // castToEncodableCondition translates the condition to an encodable struct.
-func castToEncodableCondition(condition *Condition) interface{} {
+// It returns an error for conditions of unknown types.
+func castToEncodableCondition(condition *Condition) (interface{}, error) {
+	if condition == nil {
+		return nil, errors.New("missing condition")
+	}
 	switch condition.Type() {
 	case CTPreimageSha256:
 		return encodablePreimageSha256{
 			Fingerprint: condition.Fingerprint(),
 			Cost:        condition.Cost(),
-		}
+		}, nil
 
 	case CTPrefixSha256:
 		return encodablePrefixSha256{
 			Fingerprint: condition.Fingerprint(),
 			Cost:        condition.Cost(),
 			SubTypes:    asn1.BitString(condition.SubTypes()),
-		}
+		}, nil
 
 	case CTThresholdSha256:
 		return encodableThresholdSha256{
 			Fingerprint: condition.Fingerprint(),
 			Cost:        condition.Cost(),
 			SubTypes:    asn1.BitString(condition.SubTypes()),
-		}
+		}, nil
 
 	case CTRsaSha256:
 		return encodableRsaSha256{
 			Fingerprint: condition.Fingerprint(),
 			Cost:        condition.Cost(),
-		}
+		}, nil
 
 	case CTEd25519Sha256:
 		return encodableEd25519Sha256{
 			Fingerprint: condition.Fingerprint(),
 			Cost:        condition.Cost(),
-		}
+		}, nil
+
+	case CTSecp256k1Sha256:
+		return encodableSecp256k1Sha256{
+			Fingerprint: condition.Fingerprint(),
+			Cost:        condition.Cost(),
+		}, nil
+
+	case CTEcdsaP256Sha256:
+		return encodableEcdsaP256Sha256{
+			Fingerprint: condition.Fingerprint(),
+			Cost:        condition.Cost(),
+		}, nil
+
+	case CTWebAuthnSha256:
+		return encodableWebAuthnSha256{
+			Fingerprint: condition.Fingerprint(),
+			Cost:        condition.Cost(),
+		}, nil
+
+	case CTWotsSha256:
+		return encodableWotsSha256{
+			Fingerprint: condition.Fingerprint(),
+			Cost:        condition.Cost(),
+		}, nil
 	}
-	return nil
+	return nil, errors.Errorf("unknown condition type %d", condition.Type())
 }
 
 // encodeCondition encodes the given condition to it's DER encoding.
 func encodeCondition(condition *Condition) ([]byte, error) {
-	var encoded = castToEncodableCondition(condition)
+	encoded, err := castToEncodableCondition(condition)
+	if err != nil {
+		return nil, err
+	}
 
 	//TODO determine when an error is possible
 	encoding, err := ASN1Context.EncodeWithOptions(encoded, "choice:condition")
@@ -97,6 +150,31 @@ func encodeCondition(condition *Condition) ([]byte, error) {
 	return encoding, nil
 }
 
+// sortConditions sorts the conditions by their DER encoding, which is the
+// order in which they appear in a DER encoded SET OF. The conditions are left
+// as they are if one of them can not be encoded.
+func sortConditions(conditions []*Condition) error {
+	type encodedCondition struct {
+		condition *Condition
+		encoding  []byte
+	}
+	encoded := make([]encodedCondition, len(conditions))
+	for i, c := range conditions {
+		encoding, err := encodeCondition(c)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode sub-condition %d", i)
+		}
+		encoded[i] = encodedCondition{c, encoding}
+	}
+	sort.SliceStable(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i].encoding, encoded[j].encoding) < 0
+	})
+	for i := range encoded {
+		conditions[i] = encoded[i].condition
+	}
+	return nil
+}
+
 // DecodeCondition decodes the DER encoding of a condition.
 func DecodeCondition(encodedCondition []byte) (*Condition, error) {
 	var obj interface{}
@@ -127,6 +205,18 @@ func DecodeCondition(encodedCondition []byte) (*Condition, error) {
 	case encodableEd25519Sha256:
 		c := obj.(encodableEd25519Sha256)
 		cond = NewSimpleCondition(CTEd25519Sha256, c.Fingerprint, c.Cost)
+	case encodableSecp256k1Sha256:
+		c := obj.(encodableSecp256k1Sha256)
+		cond = NewSimpleCondition(CTSecp256k1Sha256, c.Fingerprint, c.Cost)
+	case encodableEcdsaP256Sha256:
+		c := obj.(encodableEcdsaP256Sha256)
+		cond = NewSimpleCondition(CTEcdsaP256Sha256, c.Fingerprint, c.Cost)
+	case encodableWebAuthnSha256:
+		c := obj.(encodableWebAuthnSha256)
+		cond = NewSimpleCondition(CTWebAuthnSha256, c.Fingerprint, c.Cost)
+	case encodableWotsSha256:
+		c := obj.(encodableWotsSha256)
+		cond = NewSimpleCondition(CTWotsSha256, c.Fingerprint, c.Cost)
 
 	default:
 		return nil, errors.New("encoding was not a condition")
@@ -146,6 +236,74 @@ func encodeFulfillment(fulfillment Fulfillment) ([]byte, error) {
 	return encoded, nil
 }
 
+// encodedSize returns the size of the DER encoding of the fulfillment. The
+// sizes of PREFIX-SHA-256 and THRESHOLD-SHA-256 fulfillments are computed from
+// the sizes of their children rather than by encoding them, since threshold
+// fulfillments with sub-conditions can not be encoded by our ASN.1 package.
+func encodedSize(ff Fulfillment) (int, error) {
+	switch f := derefFulfillment(ff).(type) {
+	case FfPrefixSha256:
+		if !f.IsFulfilled() {
+			return 0, errors.New("unfulfilled prefix fulfillments can not be encoded")
+		}
+		sub, err := encodedSize(f.SubFulfillment)
+		if err != nil {
+			return 0, err
+		}
+		return derElementSize(derElementSize(len(f.Prefix)) +
+			derElementSize(derIntegerSize(uint64(f.MaxMessageLength))) +
+			derElementSize(sub)), nil
+
+	case FfThresholdSha256:
+		subFfs := 0
+		for _, sff := range f.SubFulfillments {
+			size, err := encodedSize(sff)
+			if err != nil {
+				return 0, err
+			}
+			subFfs += size
+		}
+		subConds := 0
+		for _, sc := range f.SubConditions {
+			encoded, err := encodeCondition(sc)
+			if err != nil {
+				return 0, err
+			}
+			subConds += len(encoded)
+		}
+		return derElementSize(derElementSize(subFfs) + derElementSize(subConds)), nil
+	}
+
+	encoded, err := ff.Encode()
+	if err != nil {
+		return 0, err
+	}
+	return len(encoded), nil
+}
+
+// derElementSize returns the size of a DER element with a single-byte tag and
+// content of the given size.
+func derElementSize(contentSize int) int {
+	size := 2 + contentSize
+	if contentSize > 0x7f {
+		// The long form: the number of length bytes, followed by them.
+		for n := contentSize; n > 0; n >>= 8 {
+			size++
+		}
+	}
+	return size
+}
+
+// derIntegerSize returns the size of the content of a DER encoded
+// non-negative INTEGER.
+func derIntegerSize(v uint64) int {
+	size := 1
+	for ; v > 0x7f; v >>= 8 {
+		size++
+	}
+	return size
+}
+
 // DecodeFulfillment decodes the DER encoding of a fulfillment.
 func DecodeFulfillment(encodedFulfillment []byte) (Fulfillment, error) {
 	var obj interface{}
@@ -169,9 +327,31 @@ func DecodeFulfillment(encodedFulfillment []byte) (Fulfillment, error) {
 	if !ok {
 		return nil, errors.New("Encoded object was not a fulfillment")
 	}
+	if err := checkFulfillment(fulfillment); err != nil {
+		return nil, err
+	}
 	return fulfillment, nil
 }
 
+// checkedFulfillment is implemented by fulfillments with rules that the
+// ASN.1 decoding can not enforce.
+type checkedFulfillment interface {
+	check() error
+}
+
+// checkFulfillment checks every node of a decoded fulfillment against the
+// rules of its type.
+func checkFulfillment(ff Fulfillment) error {
+	return Walk(ff, func(path NodePath, node Fulfillment) error {
+		if c, ok := node.(checkedFulfillment); ok {
+			if err := c.check(); err != nil {
+				return errors.Wrapf(err, "invalid fulfillment at %s", path)
+			}
+		}
+		return nil
+	})
+}
+
 // buildAsn1Context builds the context for ASN.1 encoding and decoding.
 // It forces the use of DER and specifies the tags for the CHOICES used for
 // conditions and fulfillments.
@@ -201,6 +381,22 @@ func buildASN1Context() *asn1.Context {
 			Options: fmt.Sprintf("tag:%d", CTEd25519Sha256),
 			Type:    reflect.TypeOf(encodableEd25519Sha256{}),
 		},
+		{
+			Options: fmt.Sprintf("tag:%d", CTSecp256k1Sha256),
+			Type:    reflect.TypeOf(encodableSecp256k1Sha256{}),
+		},
+		{
+			Options: fmt.Sprintf("tag:%d", CTEcdsaP256Sha256),
+			Type:    reflect.TypeOf(encodableEcdsaP256Sha256{}),
+		},
+		{
+			Options: fmt.Sprintf("tag:%d", CTWebAuthnSha256),
+			Type:    reflect.TypeOf(encodableWebAuthnSha256{}),
+		},
+		{
+			Options: fmt.Sprintf("tag:%d", CTWotsSha256),
+			Type:    reflect.TypeOf(encodableWotsSha256{}),
+		},
 	}
 	if err := ctx.AddChoice("condition", conditionChoices); err != nil {
 		panic(err)
@@ -228,6 +424,22 @@ func buildASN1Context() *asn1.Context {
 			Options: fmt.Sprintf("tag:%d", CTEd25519Sha256),
 			Type:    reflect.TypeOf(FfEd25519Sha256{}),
 		},
+		{
+			Options: fmt.Sprintf("tag:%d", CTSecp256k1Sha256),
+			Type:    reflect.TypeOf(FfSecp256k1Sha256{}),
+		},
+		{
+			Options: fmt.Sprintf("tag:%d", CTEcdsaP256Sha256),
+			Type:    reflect.TypeOf(FfEcdsaP256Sha256{}),
+		},
+		{
+			Options: fmt.Sprintf("tag:%d", CTWebAuthnSha256),
+			Type:    reflect.TypeOf(FfWebAuthnSha256{}),
+		},
+		{
+			Options: fmt.Sprintf("tag:%d", CTWotsSha256),
+			Type:    reflect.TypeOf(FfWotsSha256{}),
+		},
 	}
 	if err := ctx.AddChoice("fulfillment", fulfillmentChoices); err != nil {
 		panic(err)
@@ -0,0 +1,214 @@
+package cryptoconditions
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyteasn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+const (
+	// ffEcdsaP256Sha256Cost is the fixed cost value for ECDSA-P256-SHA-256
+	// fulfillments.
+	ffEcdsaP256Sha256Cost = 131072
+
+	// EcdsaP256PublicKeySize is the size of a SEC 1 compressed P-256 public
+	// key.
+	EcdsaP256PublicKeySize = 33
+	// EcdsaP256SignatureSize is the size of an ECDSA-P256-SHA-256 signature,
+	// the concatenation of the 32-byte big-endian values r and s.
+	EcdsaP256SignatureSize = 64
+)
+
+// FfEcdsaP256Sha256 implements the experimental ECDSA-P256-SHA-256
+// fulfillment. The public key is a SEC 1 compressed P-256 public key and the
+// signature is an ECDSA signature over the SHA-256 hash of the message,
+// encoded as r || s with a low s value.
+type FfEcdsaP256Sha256 struct {
+	PublicKey []byte `asn1:"tag:0"`
+	Signature []byte `asn1:"tag:1"`
+}
+
+// NewEcdsaP256Sha256 creates a new ECDSA-P256-SHA-256 fulfillment. The
+// signature may be empty, to be filled in later.
+func NewEcdsaP256Sha256(pubkey []byte, signature []byte) (*FfEcdsaP256Sha256, error) {
+	if _, err := parseEcdsaP256PublicKey(pubkey); err != nil {
+		return nil, err
+	}
+	if len(signature) != 0 {
+		if _, _, err := parseEcdsaP256Signature(signature); err != nil {
+			return nil, err
+		}
+	}
+	return &FfEcdsaP256Sha256{
+		PublicKey: pubkey,
+		Signature: signature,
+	}, nil
+}
+
+// parseEcdsaP256PublicKey parses a compressed P-256 public key.
+func parseEcdsaP256PublicKey(pubkey []byte) (*ecdsa.PublicKey, error) {
+	if len(pubkey) != EcdsaP256PublicKeySize {
+		return nil, errors.Errorf(
+			"wrong pubkey size (%d)", len(pubkey))
+	}
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), pubkey)
+	if x == nil {
+		return nil, errors.New("invalid P-256 public key")
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
+// marshalEcdsaP256PublicKey returns the compressed encoding of a P-256
+// public key.
+func marshalEcdsaP256PublicKey(pubkey *ecdsa.PublicKey) ([]byte, error) {
+	if pubkey == nil || pubkey.Curve != elliptic.P256() {
+		return nil, errors.New("ECDSA public key is not on the P-256 curve")
+	}
+	if pubkey.X == nil || pubkey.Y == nil || !pubkey.Curve.IsOnCurve(pubkey.X, pubkey.Y) {
+		return nil, errors.New("invalid P-256 public key")
+	}
+	return elliptic.MarshalCompressed(pubkey.Curve, pubkey.X, pubkey.Y), nil
+}
+
+// parseEcdsaP256Signature parses a signature encoded as r || s. Both values
+// must be in the range [1, n-1] and s must be at most n/2, so that every
+// signature has a single valid encoding.
+func parseEcdsaP256Signature(signature []byte) (r, s *big.Int, err error) {
+	if len(signature) != EcdsaP256SignatureSize {
+		return nil, nil, errors.Errorf(
+			"wrong signature size (%d)", len(signature))
+	}
+	n := elliptic.P256().Params().N
+	r = new(big.Int).SetBytes(signature[:32])
+	s = new(big.Int).SetBytes(signature[32:])
+	if r.Sign() == 0 || r.Cmp(n) >= 0 {
+		return nil, nil, errors.New("signature value r is out of range")
+	}
+	if s.Sign() == 0 || s.Cmp(n) >= 0 {
+		return nil, nil, errors.New("signature value s is out of range")
+	}
+	if s.Cmp(ecdsaP256HalfOrder) > 0 {
+		return nil, nil, errors.New("signature value s is not low")
+	}
+	return r, s, nil
+}
+
+// ecdsaP256HalfOrder is n/2 for the order n of P-256.
+var ecdsaP256HalfOrder = new(big.Int).Rsh(elliptic.P256().Params().N, 1)
+
+// encodeEcdsaP256Signature encodes the signature as r || s, replacing a high
+// s value by n - s.
+func encodeEcdsaP256Signature(r, s *big.Int) []byte {
+	if s.Cmp(ecdsaP256HalfOrder) > 0 {
+		s = new(big.Int).Sub(elliptic.P256().Params().N, s)
+	}
+	signature := make([]byte, EcdsaP256SignatureSize)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signature
+}
+
+// decodeDERSignature decodes an ASN.1 DER encoded ECDSA signature, as
+// returned by ecdsa.PrivateKey.Sign.
+func decodeDERSignature(der []byte) (r, s *big.Int, err error) {
+	r, s = new(big.Int), new(big.Int)
+	var inner cryptobyte.String
+	input := cryptobyte.String(der)
+	if !input.ReadASN1(&inner, cryptobyteasn1.SEQUENCE) ||
+		!input.Empty() ||
+		!inner.ReadASN1Integer(r) ||
+		!inner.ReadASN1Integer(s) ||
+		!inner.Empty() {
+		return nil, nil, errors.New("invalid ASN.1 ECDSA signature")
+	}
+	return r, s, nil
+}
+
+// EcdsaPublicKey returns the ECDSA public key.
+func (f FfEcdsaP256Sha256) EcdsaPublicKey() (*ecdsa.PublicKey, error) {
+	return parseEcdsaP256PublicKey(f.PublicKey)
+}
+
+func (f FfEcdsaP256Sha256) ConditionType() ConditionType {
+	return CTEcdsaP256Sha256
+}
+
+func (f FfEcdsaP256Sha256) Cost() int {
+	return ffEcdsaP256Sha256Cost
+}
+
+func (f FfEcdsaP256Sha256) fingerprintContents() []byte {
+	content := struct {
+		PubKey []byte `asn1:"tag:0"`
+	}{
+		PubKey: f.PublicKey,
+	}
+
+	encoded, err := ASN1Context.Encode(content)
+	if err != nil {
+		panic(err) //TODO check when this can happen
+	}
+
+	return encoded
+}
+
+func (f FfEcdsaP256Sha256) fingerprint() []byte {
+	hash := sha256.Sum256(f.fingerprintContents())
+	return hash[:]
+}
+
+func (f FfEcdsaP256Sha256) Condition() *Condition {
+	return NewSimpleCondition(f.ConditionType(), f.fingerprint(), f.Cost())
+}
+
+func (f FfEcdsaP256Sha256) Encode() ([]byte, error) {
+	return encodeFulfillment(f)
+}
+
+// check checks the encodings of the public key and the signature.
+func (f FfEcdsaP256Sha256) check() error {
+	if _, err := parseEcdsaP256PublicKey(f.PublicKey); err != nil {
+		return err
+	}
+	_, _, err := parseEcdsaP256Signature(f.Signature)
+	return err
+}
+
+func (f FfEcdsaP256Sha256) Validate(condition *Condition, message []byte) error {
+	return f.validate(condition, message, nil)
+}
+
+func (f FfEcdsaP256Sha256) validate(condition *Condition, message []byte, opts *ValidationOptions) error {
+	if !matches(f, condition) {
+		return fulfillmentDoesNotMatchConditionError
+	}
+
+	if err := verifyEcdsaP256(f.PublicKey, message, f.Signature); err != nil {
+		return fmt.Errorf("Unable to Validate EcdsaP256Sha256 fulfillment: "+
+			"signature verification failed for message %x: %s", message, err)
+	}
+	return nil
+}
+
+// verifyEcdsaP256 verifies an ECDSA-P256-SHA-256 signature of the message.
+func verifyEcdsaP256(pubkey, message, signature []byte) error {
+	key, err := parseEcdsaP256PublicKey(pubkey)
+	if err != nil {
+		return err
+	}
+	r, s, err := parseEcdsaP256Signature(signature)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256(message)
+	if !ecdsa.Verify(key, hashed[:], r, s) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package cryptoconditions
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestFfEcdsaP256Sha256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	// Generate vectors for a number of messages, so that both high and low
+	// s values come out of the signer.
+	for i := 0; i < 16; i++ {
+		message := []byte{byte(i)}
+		ff, err := SignEcdsaP256(key, message)
+		require.NoError(t, err)
+		cond := ff.Condition()
+		assert.NoError(t, ff.Validate(cond, message))
+		assert.Error(t, ff.Validate(cond, []byte("other")))
+		assert.Len(t, ff.PublicKey, EcdsaP256PublicKeySize)
+		assert.Len(t, ff.Signature, EcdsaP256SignatureSize)
+
+		// The signature with the high s value is rejected.
+		s := new(big.Int).SetBytes(ff.Signature[32:])
+		s.Sub(elliptic.P256().Params().N, s)
+		highS := append([]byte{}, ff.Signature[:32]...)
+		highS = append(highS, s.FillBytes(make([]byte, 32))...)
+		assert.Error(t, FfEcdsaP256Sha256{ff.PublicKey, highS}.Validate(cond, message))
+		_, err = NewEcdsaP256Sha256(ff.PublicKey, highS)
+		assert.Error(t, err)
+	}
+
+	ff, err := SignEcdsaP256(key, []byte("hello"))
+	require.NoError(t, err)
+	cond := ff.Condition()
+	assert.Equal(t, CTEcdsaP256Sha256, cond.Type())
+	assert.True(t, cond.Type().IsExperimental())
+	assert.Equal(t, ffEcdsaP256Sha256Cost, cond.Cost())
+	assert.Contains(t, cond.URI(), "fpt=ecdsa-p256-sha-256")
+	parsed, err := ParseURI(cond.URI())
+	require.NoError(t, err)
+	assert.True(t, parsed.Equals(cond))
+
+	pubkey, err := ff.EcdsaPublicKey()
+	require.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(pubkey))
+
+	// Keys on other curves are rejected.
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = SignEcdsaP256(p384, []byte("hello"))
+	assert.Error(t, err)
+	_, err = TemplateForPublicKey(&p384.PublicKey)
+	assert.Error(t, err)
+
+	// Points that are not on the curve are rejected.
+	_, err = TemplateForPublicKey(&ecdsa.PublicKey{Curve: elliptic.P256()})
+	assert.Error(t, err)
+	offCurve := ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).Set(key.X),
+		Y:     new(big.Int).Add(key.Y, big.NewInt(1)),
+	}
+	_, err = TemplateForPublicKey(&offCurve)
+	assert.Error(t, err)
+	_, err = NewEcdsaP256Sha256(ff.PublicKey, make([]byte, EcdsaP256SignatureSize))
+	assert.Error(t, err)
+}
+
+func TestFfEcdsaP256Sha256_roundTrip(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hsm, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	message := []byte("payment 42")
+
+	tmpl, err := AllOf(
+		Ed25519Key(alice.Public().(ed25519.PublicKey)),
+		EcdsaP256Key(&hsm.PublicKey),
+	).Template()
+	require.NoError(t, err)
+	cond := tmpl.Condition()
+
+	// The leaf fulfillment and its condition survive DER encoding.
+	leafFf, err := SignEcdsaP256(hsm, message)
+	require.NoError(t, err)
+	der, err := leafFf.Encode()
+	require.NoError(t, err)
+	decodedFf, err := DecodeFulfillment(der)
+	require.NoError(t, err)
+	assert.NoError(t, decodedFf.Validate(leafFf.Condition(), message))
+	assert.True(t, decodedFf.Condition().Equals(leafFf.Condition()))
+	assert.Equal(t, leafFf.Condition().URI(), decodedFf.Condition().URI())
+	der, err = leafFf.Condition().Encode()
+	require.NoError(t, err)
+	decodedCond, err := DecodeCondition(der)
+	require.NoError(t, err)
+	assert.True(t, decodedCond.Equals(leafFf.Condition()))
+	assert.Equal(t, leafFf.Condition().URI(), decodedCond.URI())
+
+	// The public key survives PEM encoding.
+	leaf, err := TemplateForPublicKey(&hsm.PublicKey)
+	require.NoError(t, err)
+	pemData, err := MarshalPublicKeyPEM(leaf)
+	require.NoError(t, err)
+	fromPEM, err := ParsePEM(pemData)
+	require.NoError(t, err)
+	assert.True(t, fromPEM.Condition().Equals(leaf.Condition()))
+
+	// Sign the leaves separately and exchange them as JSON.
+	partial := NewPartialFulfillment(tmpl)
+	keyring, err := NewMemoryKeyring(hsm)
+	require.NoError(t, err)
+	missing, err := partial.Sign(message, keyring, nil)
+	require.NoError(t, err)
+	require.Len(t, missing, 1)
+
+	encoded, err := json.Marshal(partial)
+	require.NoError(t, err)
+	decoded := new(PartialFulfillment)
+	require.NoError(t, json.Unmarshal(encoded, decoded))
+	keyring, err = NewMemoryKeyring(alice)
+	require.NoError(t, err)
+	missing, err = decoded.Sign(message, keyring, nil)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	ff, err := decoded.Fulfillment()
+	require.NoError(t, err)
+	assert.NoError(t, ff.Validate(cond, message))
+	hashed := sha256.Sum256(message)
+	var found bool
+	Walk(ff, func(_ NodePath, node Fulfillment) error {
+		if f, ok := derefFulfillment(node).(FfEcdsaP256Sha256); ok {
+			r := new(big.Int).SetBytes(f.Signature[:32])
+			s := new(big.Int).SetBytes(f.Signature[32:])
+			found = ecdsa.Verify(&hsm.PublicKey, hashed[:], r, s)
+		}
+		return nil
+	})
+	assert.True(t, found)
+}
@@ -75,14 +75,20 @@ func (f FfEd25519Sha256) Encode() ([]byte, error) {
 }
 
 func (f FfEd25519Sha256) Validate(condition *Condition, message []byte) error {
+	return f.validate(condition, message, nil)
+}
+
+func (f FfEd25519Sha256) validate(condition *Condition, message []byte, opts *ValidationOptions) error {
 	if !matches(f, condition) {
 		return fulfillmentDoesNotMatchConditionError
 	}
 
-	if ed25519.Verify(f.Ed25519PublicKey(), message, f.Signature) {
+	if opts.verifiedEd25519Signature(f.PublicKey, message, f.Signature) {
 		return nil
-	} else {
+	}
+	if err := verifyEd25519(opts.ed25519Mode(), f.PublicKey, message, f.Signature); err != nil {
 		return fmt.Errorf("Unable to Validate Ed25519Sha256 fulfillment: "+
-			"signature verification failed for message %x", message)
+			"signature verification failed for message %x: %s", message, err)
 	}
+	return nil
 }
@@ -15,6 +15,9 @@ type FfPrefixSha256 struct {
 	// Only have either a sub-fulfillment or a sub-condition.
 	SubFulfillment Fulfillment `asn1:"tag:2,explicit,choice:fulfillment"`
 	subCondition   *Condition  `asn1:"-"`
+
+	// memo is set on sealed fulfillments, see Seal.
+	memo *conditionMemo `asn1:"-"`
 }
 
 // NewPrefixSha256 creates a new PREFIX-SHA-256 fulfillment.
@@ -56,13 +59,39 @@ func (f FfPrefixSha256) IsFulfilled() bool {
 }
 
 func (f FfPrefixSha256) Cost() int {
+	condition, err := f.condition()
+	if err != nil {
+		return 0
+	}
+	return condition.Cost()
+}
+
+func (f FfPrefixSha256) cost(subCondition *Condition) int {
 	return len(f.Prefix) +
 		int(f.MaxMessageLength) +
-		f.SubCondition().Cost() +
+		subCondition.Cost() +
 		1024
 }
 
 func (f FfPrefixSha256) fingerprintContents() []byte {
+	subCondition, err := f.subConditionOrError()
+	if err != nil {
+		return nil
+	}
+	encoded, err := f.encodeFingerprintContents(subCondition)
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
+
+// encodeFingerprintContents encodes the fingerprint contents with the given
+// sub-condition.
+func (f FfPrefixSha256) encodeFingerprintContents(subCondition *Condition) ([]byte, error) {
+	encodableSubCondition, err := castToEncodableCondition(subCondition)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode sub-condition")
+	}
 	content := struct {
 		Prefix           []byte      `asn1:"tag:0"`
 		MaxMessageLength uint32      `asn1:"tag:1"`
@@ -70,29 +99,36 @@ func (f FfPrefixSha256) fingerprintContents() []byte {
 	}{
 		Prefix:           f.Prefix,
 		MaxMessageLength: f.MaxMessageLength,
-		SubCondition:     castToEncodableCondition(f.SubCondition()),
+		SubCondition:     encodableSubCondition,
 	}
 
 	encoded, err := ASN1Context.Encode(content)
 	if err != nil {
-		panic(err) //TODO check when this can happen
+		return nil, errors.Wrap(err, "ASN.1 encoding failed")
 	}
 
-	return encoded
+	return encoded, nil
 }
 
 func (f FfPrefixSha256) fingerprint() []byte {
-	hash := sha256.Sum256(f.fingerprintContents())
-	return hash[:]
+	condition, err := f.condition()
+	if err != nil {
+		return nil
+	}
+	return condition.Fingerprint()
 }
 
 func (f FfPrefixSha256) subConditionTypes() ConditionTypeSet {
-	var set ConditionTypeSet
-	if f.IsFulfilled() {
-		set.addRelevant(f.SubFulfillment)
-	} else {
-		set.addRelevant(f.subCondition)
+	condition, err := f.condition()
+	if err != nil {
+		return ConditionTypeSet{}
 	}
+	return condition.SubTypes()
+}
+
+func (f FfPrefixSha256) computeSubConditionTypes(subCondition *Condition) ConditionTypeSet {
+	var set ConditionTypeSet
+	set.addRelevant(subCondition)
 	// As per RFC:
 	// This is the set of types and subtypes of all sub-crypto-conditions,
 	// recursively excluding the type of the root crypto-condition.
@@ -100,8 +136,47 @@ func (f FfPrefixSha256) subConditionTypes() ConditionTypeSet {
 	return set
 }
 
+// Condition returns the condition of the fulfillment. It is computed only
+// once for sealed fulfillments, and every time for others. It is nil if the
+// sub-condition, or a condition further down the tree, has an unknown type;
+// Validate returns the error in that case.
 func (f FfPrefixSha256) Condition() *Condition {
-	return NewCompoundCondition(f.ConditionType(), f.fingerprint(), f.Cost(), f.subConditionTypes())
+	condition, _ := f.condition()
+	return condition
+}
+
+// condition returns the condition of the fulfillment, or the error that
+// prevents computing it.
+func (f FfPrefixSha256) condition() (*Condition, error) {
+	if f.memo == nil {
+		return f.computeCondition()
+	}
+	return f.memo.get(f.computeCondition)
+}
+
+// subConditionOrError returns the sub-condition, or the error that prevents
+// computing the condition of the sub-fulfillment.
+func (f FfPrefixSha256) subConditionOrError() (*Condition, error) {
+	if f.IsFulfilled() {
+		return conditionOf(f.SubFulfillment)
+	}
+	return f.subCondition, nil
+}
+
+// computeCondition computes the condition from the sub-condition, so that
+// the latter is computed only once.
+func (f FfPrefixSha256) computeCondition() (*Condition, error) {
+	subCondition, err := f.subConditionOrError()
+	if err != nil {
+		return nil, err
+	}
+	fingerprintContents, err := f.encodeFingerprintContents(subCondition)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(fingerprintContents)
+	return NewCompoundCondition(f.ConditionType(), hash[:], f.cost(subCondition),
+		f.computeSubConditionTypes(subCondition)), nil
 }
 
 func (f FfPrefixSha256) Encode() ([]byte, error) {
@@ -109,6 +184,13 @@ func (f FfPrefixSha256) Encode() ([]byte, error) {
 }
 
 func (f FfPrefixSha256) Validate(condition *Condition, message []byte) error {
+	return Seal(f).validate(condition, message, nil)
+}
+
+func (f FfPrefixSha256) validate(condition *Condition, message []byte, opts *ValidationOptions) error {
+	if _, err := f.condition(); err != nil {
+		return err
+	}
 	if !matches(f, condition) {
 		return fulfillmentDoesNotMatchConditionError
 	}
@@ -128,6 +210,6 @@ func (f FfPrefixSha256) Validate(condition *Condition, message []byte) error {
 	buffer.Write(message)
 	newMessage := buffer.Bytes()
 
-	return errors.Wrapf(f.SubFulfillment.Validate(nil, newMessage),
+	return errors.Wrapf(validateChild(f.SubFulfillment, newMessage, opts),
 		"failed to validate sub-fulfillment with message %x", newMessage)
 }
@@ -40,6 +40,10 @@ func (f FfPreimageSha256) Encode() ([]byte, error) {
 }
 
 func (f FfPreimageSha256) Validate(condition *Condition, message []byte) error {
+	return f.validate(condition, message, nil)
+}
+
+func (f FfPreimageSha256) validate(condition *Condition, message []byte, opts *ValidationOptions) error {
 	if !matches(f, condition) {
 		return fulfillmentDoesNotMatchConditionError
 	}
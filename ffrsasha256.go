@@ -1,6 +1,7 @@
 package cryptoconditions
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -21,25 +22,72 @@ var ffRsaSha256PssOpts = &rsa.PSSOptions{
 	Hash:       crypto.SHA256,
 }
 
+// Errors returned for RSA-SHA-256 fulfillments that violate the rules of the
+// RFC. They are returned by NewRsaSha256, DecodeFulfillment and Validate,
+// possibly wrapped; use errors.Cause to compare them.
+var (
+	ErrRsaModulusTooSmall    = errors.New("RSA modulus is too small")
+	ErrRsaModulusTooLarge    = errors.New("RSA modulus is too large")
+	ErrRsaModulusLeadingZero = errors.New("RSA modulus has a leading zero byte")
+	ErrRsaSignatureLength    = errors.New("RSA signature length differs from the modulus length")
+	ErrRsaSignatureTooLarge  = errors.New("RSA signature is not smaller than the modulus")
+	ErrRsaSignatureInvalid   = errors.New("RSA signature is invalid")
+)
+
 // NewFfRsaSha256 implements the RSA-SHA-256 fulfillment.
 type FfRsaSha256 struct {
 	Modulus   []byte `asn1:"tag:0"`
 	Signature []byte `asn1:"tag:1"`
 }
 
-// RsaSha256 creates a new RSA-SHA-256 fulfillment.
+// RsaSha256 creates a new RSA-SHA-256 fulfillment. The signature may be
+// empty, to be filled in later.
 func NewRsaSha256(modulus []byte, signature []byte) (*FfRsaSha256, error) {
-	if len(modulus) < ffRsaSha256MinimumModulusLength {
-		return nil, errors.New("modulus is too small.")
+	ff := &FfRsaSha256{
+		Modulus:   modulus,
+		Signature: signature,
+	}
+	if len(signature) == 0 {
+		if err := checkRsaModulus(modulus); err != nil {
+			return nil, err
+		}
+		return ff, nil
 	}
-	if len(modulus) > ffRsaSha256MaximumModulusLength {
-		return nil, errors.New("modulus is too large.")
+	if err := ff.check(); err != nil {
+		return nil, err
 	}
+	return ff, nil
+}
 
-	return &FfRsaSha256{
-		Modulus:   modulus,
-		Signature: signature,
-	}, nil
+// checkRsaModulus checks that the modulus is an unsigned big-endian integer
+// of an allowed length without leading zero bytes, as the RFC requires.
+func checkRsaModulus(modulus []byte) error {
+	switch {
+	case len(modulus) < ffRsaSha256MinimumModulusLength:
+		return ErrRsaModulusTooSmall
+	case len(modulus) > ffRsaSha256MaximumModulusLength:
+		return ErrRsaModulusTooLarge
+	case modulus[0] == 0:
+		return ErrRsaModulusLeadingZero
+	}
+	return nil
+}
+
+// check checks the modulus and the signature against the rules of the RFC:
+// the signature must have the length of the modulus and, read as a
+// big-endian integer, be smaller than it.
+func (f FfRsaSha256) check() error {
+	if err := checkRsaModulus(f.Modulus); err != nil {
+		return err
+	}
+	if len(f.Signature) != len(f.Modulus) {
+		return ErrRsaSignatureLength
+	}
+	// Both have the same length, so comparing the bytes compares the values.
+	if bytes.Compare(f.Signature, f.Modulus) >= 0 {
+		return ErrRsaSignatureTooLarge
+	}
+	return nil
 }
 
 // PublicKey returns the RSA public key.
@@ -87,14 +135,26 @@ func (f FfRsaSha256) Encode() ([]byte, error) {
 }
 
 func (f FfRsaSha256) Validate(condition *Condition, message []byte) error {
+	return f.validate(condition, message, nil)
+}
+
+func (f FfRsaSha256) validate(condition *Condition, message []byte, opts *ValidationOptions) error {
 	if !matches(f, condition) {
 		return fulfillmentDoesNotMatchConditionError
 	}
 
+	if err := f.check(); err != nil {
+		return err
+	}
+
+	// The salt length is given explicitly, so signatures with any other salt
+	// length are rejected.
 	hashed := sha256.Sum256(message)
 	err := rsa.VerifyPSS(
 		f.PublicKey(), crypto.SHA256, hashed[:], f.Signature, ffRsaSha256PssOpts)
-
-	return errors.Wrapf(err,
-		"failed to verify RSA signature of binary message \"%x\" (hex)", message)
+	if err != nil {
+		return errors.Wrapf(ErrRsaSignatureInvalid,
+			"failed to verify RSA signature of binary message \"%x\" (hex)", message)
+	}
+	return nil
 }
@@ -0,0 +1,112 @@
+package cryptoconditions
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// derTLV encodes a DER element with the given tag.
+func derTLV(tag byte, content []byte) []byte {
+	n := len(content)
+	switch {
+	case n < 0x80:
+		return append([]byte{tag, byte(n)}, content...)
+	case n < 0x100:
+		return append([]byte{tag, 0x81, byte(n)}, content...)
+	default:
+		return append([]byte{tag, 0x82, byte(n >> 8), byte(n)}, content...)
+	}
+}
+
+// encodeRsaFulfillment encodes an RSA-SHA-256 fulfillment by hand, so that
+// values the constructor rejects can be encoded.
+func encodeRsaFulfillment(modulus, signature []byte) []byte {
+	return derTLV(0xa3, append(derTLV(0x80, modulus), derTLV(0x81, signature)...))
+}
+
+func TestFfRsaSha256_conformance(t *testing.T) {
+	privkey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	message := []byte("hello")
+	ff, err := SignRsaSha256(privkey, message)
+	require.NoError(t, err)
+	modulus, signature := ff.Modulus, ff.Signature
+	require.NoError(t, ff.Validate(ff.Condition(), message))
+
+	// A signature with a salt of 20 bytes instead of 32.
+	hashed := sha256.Sum256(message)
+	wrongSalt, err := rsa.SignPSS(rand.Reader, privkey, crypto.SHA256, hashed[:],
+		&rsa.PSSOptions{SaltLength: 20, Hash: crypto.SHA256})
+	require.NoError(t, err)
+
+	vectors := []struct {
+		name               string
+		modulus, signature []byte
+		err                error
+	}{
+		{"modulus with leading zero",
+			append([]byte{0}, modulus...), append([]byte{0}, signature...),
+			ErrRsaModulusLeadingZero},
+		{"modulus too small", modulus[:127], signature[:127],
+			ErrRsaModulusTooSmall},
+		{"modulus too large", make([]byte, 513), make([]byte, 513),
+			ErrRsaModulusTooLarge},
+		{"short signature", modulus, signature[1:],
+			ErrRsaSignatureLength},
+		{"zero-padded signature", modulus, append([]byte{0}, signature...),
+			ErrRsaSignatureLength},
+		{"signature equal to modulus", modulus, modulus,
+			ErrRsaSignatureTooLarge},
+		{"wrong salt length", modulus, wrongSalt,
+			ErrRsaSignatureInvalid},
+	}
+
+	for _, v := range vectors {
+		invalid := FfRsaSha256{Modulus: v.modulus, Signature: v.signature}
+		err := invalid.Validate(invalid.Condition(), message)
+		assert.Equal(t, v.err, errors.Cause(err), "%s: %v", v.name, err)
+
+		if v.err != ErrRsaSignatureInvalid {
+			_, err = NewRsaSha256(v.modulus, v.signature)
+			assert.Equal(t, v.err, errors.Cause(err), "%s: %v", v.name, err)
+		}
+	}
+
+	// Templates are checked without a signature.
+	_, err = NewRsaSha256Template(append([]byte{0}, modulus...))
+	assert.Equal(t, ErrRsaModulusLeadingZero, errors.Cause(err))
+	_, err = NewRsaSha256(modulus, nil)
+	assert.NoError(t, err)
+}
+
+func TestDecodeFulfillment_RsaConformance(t *testing.T) {
+	privkey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	ff, err := SignRsaSha256(privkey, []byte("hello"))
+	require.NoError(t, err)
+
+	decoded, err := DecodeFulfillment(encodeRsaFulfillment(ff.Modulus, ff.Signature))
+	require.NoError(t, err)
+	assert.Equal(t, ff.Condition(), decoded.Condition())
+
+	_, err = DecodeFulfillment(encodeRsaFulfillment(
+		append([]byte{0}, ff.Modulus...), append([]byte{0}, ff.Signature...)))
+	assert.Equal(t, ErrRsaModulusLeadingZero, errors.Cause(err))
+
+	_, err = DecodeFulfillment(encodeRsaFulfillment(ff.Modulus, ff.Signature[1:]))
+	assert.Equal(t, ErrRsaSignatureLength, errors.Cause(err))
+
+	// The check also applies to sub-fulfillments.
+	invalid := &FfRsaSha256{Modulus: ff.Modulus, Signature: ff.Modulus}
+	encoded, err := NewPrefixSha256([]byte("p"), 16, invalid).Encode()
+	require.NoError(t, err)
+	_, err = DecodeFulfillment(encoded)
+	assert.Equal(t, ErrRsaSignatureTooLarge, errors.Cause(err))
+}
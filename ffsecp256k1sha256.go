@@ -0,0 +1,202 @@
+package cryptoconditions
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ffSecp256k1Sha256Cost is the fixed cost value for SECP256K1-SHA-256
+	// fulfillments.
+	ffSecp256k1Sha256Cost = 131072
+
+	// Secp256k1PublicKeySize is the size of a compressed secp256k1 public key.
+	Secp256k1PublicKeySize = secp256k1.PubKeyBytesLenCompressed
+	// Secp256k1SignatureSize is the size of a SECP256K1-SHA-256 signature,
+	// the concatenation of the 32-byte big-endian values r and s.
+	Secp256k1SignatureSize = 64
+)
+
+// FfSecp256k1Sha256 implements the experimental SECP256K1-SHA-256
+// fulfillment. The public key is a compressed secp256k1 public key and the
+// signature is an ECDSA signature over the SHA-256 hash of the message,
+// encoded as r || s with a low s value.
+type FfSecp256k1Sha256 struct {
+	PublicKey []byte `asn1:"tag:0"`
+	Signature []byte `asn1:"tag:1"`
+}
+
+// NewSecp256k1Sha256 creates a new SECP256K1-SHA-256 fulfillment. The
+// signature may be empty, to be filled in later.
+func NewSecp256k1Sha256(pubkey []byte, signature []byte) (*FfSecp256k1Sha256, error) {
+	if _, err := parseSecp256k1PublicKey(pubkey); err != nil {
+		return nil, err
+	}
+	if len(signature) != 0 {
+		if _, err := parseSecp256k1Signature(signature); err != nil {
+			return nil, err
+		}
+	}
+	return &FfSecp256k1Sha256{
+		PublicKey: pubkey,
+		Signature: signature,
+	}, nil
+}
+
+// parseSecp256k1PublicKey parses a compressed secp256k1 public key.
+func parseSecp256k1PublicKey(pubkey []byte) (*secp256k1.PublicKey, error) {
+	if len(pubkey) != Secp256k1PublicKeySize {
+		return nil, errors.Errorf(
+			"wrong pubkey size (%d)", len(pubkey))
+	}
+	key, err := secp256k1.ParsePubKey(pubkey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid secp256k1 public key")
+	}
+	return key, nil
+}
+
+// parseSecp256k1Signature parses a signature encoded as r || s. Both values
+// must be in the range [1, n-1] and s must be at most n/2, so that every
+// signature has a single valid encoding.
+func parseSecp256k1Signature(signature []byte) (*ecdsa.Signature, error) {
+	if len(signature) != Secp256k1SignatureSize {
+		return nil, errors.Errorf(
+			"wrong signature size (%d)", len(signature))
+	}
+	var r, s secp256k1.ModNScalar
+	if overflow := r.SetByteSlice(signature[:32]); overflow || r.IsZero() {
+		return nil, errors.New("signature value r is out of range")
+	}
+	if overflow := s.SetByteSlice(signature[32:]); overflow || s.IsZero() {
+		return nil, errors.New("signature value s is out of range")
+	}
+	if s.IsOverHalfOrder() {
+		return nil, errors.New("signature value s is not low")
+	}
+	return ecdsa.NewSignature(&r, &s), nil
+}
+
+// Secp256k1PublicKey returns the secp256k1 public key.
+func (f FfSecp256k1Sha256) Secp256k1PublicKey() (*secp256k1.PublicKey, error) {
+	return parseSecp256k1PublicKey(f.PublicKey)
+}
+
+func (f FfSecp256k1Sha256) ConditionType() ConditionType {
+	return CTSecp256k1Sha256
+}
+
+func (f FfSecp256k1Sha256) Cost() int {
+	return ffSecp256k1Sha256Cost
+}
+
+func (f FfSecp256k1Sha256) fingerprintContents() []byte {
+	content := struct {
+		PubKey []byte `asn1:"tag:0"`
+	}{
+		PubKey: f.PublicKey,
+	}
+
+	encoded, err := ASN1Context.Encode(content)
+	if err != nil {
+		panic(err) //TODO check when this can happen
+	}
+
+	return encoded
+}
+
+func (f FfSecp256k1Sha256) fingerprint() []byte {
+	hash := sha256.Sum256(f.fingerprintContents())
+	return hash[:]
+}
+
+func (f FfSecp256k1Sha256) Condition() *Condition {
+	return NewSimpleCondition(f.ConditionType(), f.fingerprint(), f.Cost())
+}
+
+func (f FfSecp256k1Sha256) Encode() ([]byte, error) {
+	return encodeFulfillment(f)
+}
+
+// check checks the encodings of the public key and the signature.
+func (f FfSecp256k1Sha256) check() error {
+	if _, err := parseSecp256k1PublicKey(f.PublicKey); err != nil {
+		return err
+	}
+	_, err := parseSecp256k1Signature(f.Signature)
+	return err
+}
+
+func (f FfSecp256k1Sha256) Validate(condition *Condition, message []byte) error {
+	return f.validate(condition, message, nil)
+}
+
+func (f FfSecp256k1Sha256) validate(condition *Condition, message []byte, opts *ValidationOptions) error {
+	if !matches(f, condition) {
+		return fulfillmentDoesNotMatchConditionError
+	}
+
+	if err := verifySecp256k1(f.PublicKey, message, f.Signature); err != nil {
+		return fmt.Errorf("Unable to Validate Secp256k1Sha256 fulfillment: "+
+			"signature verification failed for message %x: %s", message, err)
+	}
+	return nil
+}
+
+// verifySecp256k1 verifies a SECP256K1-SHA-256 signature of the message.
+func verifySecp256k1(pubkey, message, signature []byte) error {
+	key, err := parseSecp256k1PublicKey(pubkey)
+	if err != nil {
+		return err
+	}
+	sig, err := parseSecp256k1Signature(signature)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256(message)
+	if !sig.Verify(hashed[:], key) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// Secp256k1Signer is a crypto.Signer for secp256k1 private keys. Its Sign
+// method expects a SHA-256 digest and returns a deterministic RFC 6979
+// signature encoded as r || s, as used by SECP256K1-SHA-256 fulfillments.
+type Secp256k1Signer struct {
+	*secp256k1.PrivateKey
+}
+
+// NewSecp256k1Signer returns the signer for the private key.
+func NewSecp256k1Signer(key *secp256k1.PrivateKey) Secp256k1Signer {
+	return Secp256k1Signer{key}
+}
+
+// Public returns the *secp256k1.PublicKey of the signer.
+func (s Secp256k1Signer) Public() crypto.PublicKey {
+	return s.PubKey()
+}
+
+// Sign signs the SHA-256 digest. The random source is not used, since the
+// nonce is derived from the key and the digest.
+func (s Secp256k1Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, errors.Errorf(
+			"secp256k1 signer expects a SHA-256 digest, not %v", opts.HashFunc())
+	}
+	if len(digest) != sha256.Size {
+		return nil, errors.Errorf("wrong digest size (%d)", len(digest))
+	}
+	sig := ecdsa.Sign(s.PrivateKey, digest)
+	r, sValue := sig.R(), sig.S()
+	signature := make([]byte, Secp256k1SignatureSize)
+	r.PutBytesUnchecked(signature[:32])
+	sValue.PutBytesUnchecked(signature[32:])
+	return signature, nil
+}
@@ -0,0 +1,140 @@
+package cryptoconditions
+
+import (
+	"crypto"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// testDERSecp256k1Signer returns DER encoded signatures, like a key
+// management service would.
+type testDERSecp256k1Signer struct {
+	key *secp256k1.PrivateKey
+}
+
+func (s testDERSecp256k1Signer) Public() crypto.PublicKey {
+	return s.key.PubKey()
+}
+
+func (s testDERSecp256k1Signer) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	return secp256k1ecdsa.Sign(s.key, digest).Serialize(), nil
+}
+
+func TestFfSecp256k1Sha256(t *testing.T) {
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	message := []byte("hello")
+
+	ff, err := SignSecp256k1(NewSecp256k1Signer(key), message)
+	require.NoError(t, err)
+	cond := ff.Condition()
+	assert.NoError(t, ff.Validate(cond, message))
+	assert.Error(t, ff.Validate(cond, []byte("hello!")))
+	assert.Equal(t, CTSecp256k1Sha256, cond.Type())
+	assert.True(t, cond.Type().IsExperimental())
+	assert.False(t, CTEd25519Sha256.IsExperimental())
+	assert.Contains(t, cond.URI(), "fpt=secp256k1-sha-256")
+
+	parsed, err := ParseURI(cond.URI())
+	require.NoError(t, err)
+	assert.True(t, parsed.Equals(cond))
+
+	// Signing is deterministic.
+	again, err := SignSecp256k1(NewSecp256k1Signer(key), message)
+	require.NoError(t, err)
+	assert.Equal(t, ff.Signature, again.Signature)
+
+	// DER encoded signatures are converted.
+	der, err := SignSecp256k1(testDERSecp256k1Signer{key}, message)
+	require.NoError(t, err)
+	assert.Equal(t, ff.Signature, der.Signature)
+
+	// The high s value of the same signature is rejected.
+	var s secp256k1.ModNScalar
+	s.SetByteSlice(ff.Signature[32:])
+	s.Negate()
+	highS := append([]byte{}, ff.Signature...)
+	s.PutBytesUnchecked(highS[32:])
+	assert.Error(t, FfSecp256k1Sha256{ff.PublicKey, highS}.Validate(cond, message))
+	_, err = NewSecp256k1Sha256(ff.PublicKey, highS)
+	assert.Error(t, err)
+
+	// Uncompressed public keys are rejected.
+	_, err = NewSecp256k1Sha256(key.PubKey().SerializeUncompressed(), nil)
+	assert.Error(t, err)
+	_, err = NewSecp256k1Sha256(ff.PublicKey, make([]byte, Secp256k1SignatureSize))
+	assert.Error(t, err)
+}
+
+const (
+	testSecp256k1FulfillmentEncoding = "B06580210284BF7562262BBD6940085748F3BE6AFA52AE317155181ECE31B66351CCFFA4B0" +
+		"8140EFB1BA8DC1B98D188D3C39A2198031CBDDAC13EC028F8705FEC2796253F5C1826A8AF23FD2" +
+		"DDFC1FBC04BF0E7F7F191558C3EF606F8EEACD43B23A7F3BCB9C26"
+	testSecp256k1ConditionEncoding = "B027802039FF0050DD9204CEF522A3EA7A99CD203A5CE17E2E47469C72BE6369101102B0" +
+		"8103020000"
+	testSecp256k1ConditionURI = "ni:///sha-256;Of8AUN2SBM71IqPqepnNIDpc4X4uR0accr5jaRARArA?fpt=secp256k1-sha-256&cost=131072"
+)
+
+func TestFfSecp256k1Sha256_encoding(t *testing.T) {
+	seed := make([]byte, 32)
+	for i := range seed {
+		seed[i] = byte(i + 1)
+	}
+	key := secp256k1.PrivKeyFromBytes(seed)
+	message := []byte("hello")
+
+	ff, err := SignSecp256k1(NewSecp256k1Signer(key), message)
+	require.NoError(t, err)
+	encodedFf, err := ff.Encode()
+	require.NoError(t, err)
+	assert.Equal(t, unhex(testSecp256k1FulfillmentEncoding), encodedFf)
+
+	decodedFf, err := DecodeFulfillment(encodedFf)
+	require.NoError(t, err)
+	assert.Equal(t, CTSecp256k1Sha256, decodedFf.ConditionType())
+	assert.NoError(t, decodedFf.Validate(ff.Condition(), message))
+
+	encodedCond, err := ff.Condition().Encode()
+	require.NoError(t, err)
+	assert.Equal(t, unhex(testSecp256k1ConditionEncoding), encodedCond)
+
+	decodedCond, err := DecodeCondition(encodedCond)
+	require.NoError(t, err)
+	assert.True(t, decodedCond.Equals(ff.Condition()))
+	assertEquivalentURIs(t, testSecp256k1ConditionURI, decodedCond.URI())
+}
+
+func TestSign_secp256k1(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ledger, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	message := []byte("payment 42")
+
+	tmpl, err := AllOf(
+		Ed25519Key(alice.Public().(ed25519.PublicKey)),
+		WithPrefix([]byte("ledger:"), 64, Secp256k1Key(ledger.PubKey())),
+	).Template()
+	require.NoError(t, err)
+	cond := tmpl.Condition()
+	assert.True(t, cond.SubTypes().Has(CTSecp256k1Sha256))
+
+	keyring, err := NewMemoryKeyring(alice, NewSecp256k1Signer(ledger))
+	require.NoError(t, err)
+	ff, missing, err := Sign(tmpl, message, keyring, nil)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.NoError(t, ff.Validate(cond, message))
+
+	ledgerFf := ff.(*FfThresholdSha256).SubFulfillments[1].(*FfPrefixSha256).
+		SubFulfillment.(*FfSecp256k1Sha256)
+	assert.NoError(t, verifySecp256k1(ledgerFf.PublicKey,
+		[]byte("ledger:payment 42"), ledgerFf.Signature))
+}
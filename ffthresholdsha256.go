@@ -1,9 +1,12 @@
 package cryptoconditions
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	"sort"
+
+	"github.com/pkg/errors"
 )
 
 //TODO Currently not working due to missing ASN.1 features from our dependency
@@ -16,6 +19,9 @@ type FfThresholdSha256 struct {
 
 	SubFulfillments []Fulfillment `asn1:"tag:0,explicit,set,choice:fulfillment"`
 	SubConditions   []*Condition  `asn1:"tag:1,explicit,set,choice:condition"`
+
+	// memo is set on sealed fulfillments, see Seal.
+	memo *conditionMemo `asn1:"-"`
 }
 
 //TODO ADD NORMALIZE METHOD that makes sure the FF is of minimal size by replacing (threshold - nbFulfillments) fulfillments
@@ -36,18 +42,34 @@ func (f FfThresholdSha256) ConditionType() ConditionType {
 }
 
 func (f FfThresholdSha256) Cost() int {
-	// The cost is the sum of the F.threshold largest cost values of all
-	// sub-conditions, added to 1024 times the total number of sub-conditions.
-	conditionCosts := make([]int,
+	condition, err := f.condition()
+	if err != nil {
+		return 0
+	}
+	return condition.Cost()
+}
+
+// childConditions returns the conditions of all children, both the fulfilled
+// and the unfulfilled ones.
+func (f FfThresholdSha256) childConditions() ([]*Condition, error) {
+	conditions := make([]*Condition, 0,
 		len(f.SubFulfillments)+len(f.SubConditions))
-	index := 0
-	for _, fulfillment := range f.SubFulfillments {
-		conditionCosts[index] = fulfillment.Cost()
-		index++
+	for i, sff := range f.SubFulfillments {
+		condition, err := conditionOf(sff)
+		if err != nil {
+			return nil, errors.Wrapf(err, "sub-fulfillment %d", i)
+		}
+		conditions = append(conditions, condition)
 	}
-	for _, condition := range f.SubConditions {
-		conditionCosts[index] = condition.Cost()
-		index++
+	return append(conditions, f.SubConditions...), nil
+}
+
+func (f FfThresholdSha256) cost(conditions []*Condition) int {
+	// The cost is the sum of the F.threshold largest cost values of all
+	// sub-conditions, added to 1024 times the total number of sub-conditions.
+	conditionCosts := make([]int, len(conditions))
+	for i, condition := range conditions {
+		conditionCosts[i] = condition.Cost()
 	}
 	sort.Ints(conditionCosts)
 	// We need the sum of the [threshold] highest costs.
@@ -60,13 +82,32 @@ func (f FfThresholdSha256) Cost() int {
 }
 
 func (f FfThresholdSha256) fingerprintContents() []byte {
-	subConditions := make([]*Condition, len(f.SubFulfillments))
-	for i, sff := range f.SubFulfillments {
-		subConditions[i] = sff.Condition()
+	conditions, err := f.childConditions()
+	if err != nil {
+		return nil
+	}
+	encoded, err := f.encodeFingerprintContents(conditions)
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
+
+// encodeFingerprintContents encodes the fingerprint contents, which cover
+// the conditions of all children in the order of a DER SET OF. The
+// conditions are sorted in place.
+func (f FfThresholdSha256) encodeFingerprintContents(conditions []*Condition) ([]byte, error) {
+	if err := sortConditions(conditions); err != nil {
+		return nil, err
+	}
+	subConditions := make([]interface{}, len(conditions))
+	for i, c := range conditions {
+		// The conditions could all be encoded to be sorted.
+		subConditions[i], _ = castToEncodableCondition(c)
 	}
 	content := struct {
-		Threshold     uint16       `asn1:"tag:0"`
-		SubConditions []*Condition `asn1:"tag:1,explicit,set,choice:condition"`
+		Threshold     uint16        `asn1:"tag:0"`
+		SubConditions []interface{} `asn1:"tag:1,explicit,set,choice:condition"`
 	}{
 		Threshold:     f.Threshold,
 		SubConditions: subConditions,
@@ -74,25 +115,32 @@ func (f FfThresholdSha256) fingerprintContents() []byte {
 
 	encoded, err := ASN1Context.Encode(content)
 	if err != nil {
-		//TODO
-		panic(err)
+		return nil, errors.Wrap(err, "ASN.1 encoding failed")
 	}
 
-	return encoded
+	return encoded, nil
 }
 
 func (f FfThresholdSha256) fingerprint() []byte {
-	hash := sha256.Sum256(f.fingerprintContents())
-	return hash[:]
+	condition, err := f.condition()
+	if err != nil {
+		return nil
+	}
+	return condition.Fingerprint()
 }
 
 func (f FfThresholdSha256) subConditionTypes() ConditionTypeSet {
-	var set ConditionTypeSet
-	for _, sff := range f.SubFulfillments {
-		set.addRelevant(sff)
+	condition, err := f.condition()
+	if err != nil {
+		return ConditionTypeSet{}
 	}
-	for _, sc := range f.SubConditions {
-		set.addRelevant(sc)
+	return condition.SubTypes()
+}
+
+func (f FfThresholdSha256) computeSubConditionTypes(conditions []*Condition) ConditionTypeSet {
+	var set ConditionTypeSet
+	for _, c := range conditions {
+		set.addRelevant(c)
 	}
 	// As per RFC:
 	// This is the set of types and subtypes of all sub-crypto-conditions,
@@ -101,8 +149,39 @@ func (f FfThresholdSha256) subConditionTypes() ConditionTypeSet {
 	return set
 }
 
+// Condition returns the condition of the fulfillment. It is computed only
+// once for sealed fulfillments, and every time for others. It is nil if one
+// of the sub-conditions, or a condition further down the tree, has an
+// unknown type; Validate returns the error in that case.
 func (f FfThresholdSha256) Condition() *Condition {
-	return NewCompoundCondition(f.ConditionType(), f.fingerprint(), f.Cost(), f.subConditionTypes())
+	condition, _ := f.condition()
+	return condition
+}
+
+// condition returns the condition of the fulfillment, or the error that
+// prevents computing it.
+func (f FfThresholdSha256) condition() (*Condition, error) {
+	if f.memo == nil {
+		return f.computeCondition()
+	}
+	return f.memo.get(f.computeCondition)
+}
+
+// computeCondition computes the condition from the conditions of the
+// children, so that each of them is computed only once.
+func (f FfThresholdSha256) computeCondition() (*Condition, error) {
+	conditions, err := f.childConditions()
+	if err != nil {
+		return nil, err
+	}
+	cost := f.cost(conditions)
+	fingerprintContents, err := f.encodeFingerprintContents(conditions)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(fingerprintContents)
+	return NewCompoundCondition(f.ConditionType(), hash[:], cost,
+		f.computeSubConditionTypes(conditions)), nil
 }
 
 func (f FfThresholdSha256) Encode() ([]byte, error) {
@@ -110,6 +189,13 @@ func (f FfThresholdSha256) Encode() ([]byte, error) {
 }
 
 func (f FfThresholdSha256) Validate(condition *Condition, message []byte) error {
+	return Seal(f).validate(condition, message, nil)
+}
+
+func (f FfThresholdSha256) validate(condition *Condition, message []byte, opts *ValidationOptions) error {
+	if _, err := f.condition(); err != nil {
+		return err
+	}
 	if !matches(f, condition) {
 		return fulfillmentDoesNotMatchConditionError
 	}
@@ -124,18 +210,96 @@ func (f FfThresholdSha256) Validate(condition *Condition, message []byte) error
 		return fmt.Errorf("Not enough fulfillments: %v of %v", len(f.SubFulfillments), th)
 	}
 
-	// Try to verify the fulfillments one by one.
-	for _, ff := range f.SubFulfillments {
-		if ff.Validate(nil, message) == nil {
-			th--
-			if th == 0 {
+	// Verify the cheapest fulfillments first.
+	subs := make([]Fulfillment, len(f.SubFulfillments))
+	copy(subs, f.SubFulfillments)
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].Cost() < subs[j].Cost()
+	})
+
+	var valid int
+	if opts.concurrent() {
+		valid = validateConcurrently(subs, th, message, opts)
+	} else {
+		valid = validateSequentially(subs, th, message, opts)
+	}
+
+	if valid < th {
+		if err := opts.context().Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("Could only verify %v of %v fulfillments", valid, th)
+	}
+	return nil
+}
+
+// validateSequentially validates the sub-fulfillments one by one until
+// threshold of them are valid or the threshold can no longer be reached, and
+// returns the number of valid ones.
+func validateSequentially(subs []Fulfillment, threshold int, message []byte, opts *ValidationOptions) int {
+	valid := 0
+	for i, ff := range subs {
+		if valid+len(subs)-i < threshold {
+			break
+		}
+		if validateChild(ff, message, opts) == nil {
+			valid++
+			if valid == threshold {
 				break
 			}
 		}
 	}
+	return valid
+}
 
-	if th != 0 {
-		return fmt.Errorf("Could only verify %v of %v fulfillments", int(f.Threshold)-th, th)
+// validateConcurrently validates the sub-fulfillments concurrently, in the
+// given order, until threshold of them are valid, the threshold can no
+// longer be reached or the context of the options is done. It returns the
+// number of valid sub-fulfillments.
+// Only as many sub-fulfillments as are still needed are validated at a time,
+// so that no more work is done than sequential validation would do unless
+// some of them are invalid.
+// A sub-fulfillment is only validated in a new goroutine if a worker token
+// is free, and in the calling goroutine otherwise, so that the number of
+// goroutines stays bounded however wide and deep the tree is.
+func validateConcurrently(subs []Fulfillment, threshold int, message []byte, opts *ValidationOptions) int {
+	ctx, cancel := context.WithCancel(opts.ctx)
+	defer cancel()
+	childOpts := *opts
+	childOpts.ctx = ctx
+
+	// The channel is buffered so that the remaining goroutines can finish
+	// after returning.
+	results := make(chan error, len(subs))
+	next, inFlight, valid := 0, 0, 0
+	for {
+		for valid+inFlight < threshold && next < len(subs) {
+			ff := subs[next]
+			next++
+			select {
+			case opts.workers <- struct{}{}:
+				inFlight++
+				go func() {
+					defer func() { <-opts.workers }()
+					results <- validateChild(ff, message, &childOpts)
+				}()
+			default:
+				if validateChild(ff, message, &childOpts) == nil {
+					valid++
+				}
+			}
+		}
+		if valid >= threshold || valid+inFlight < threshold {
+			return valid
+		}
+		select {
+		case err := <-results:
+			inFlight--
+			if err == nil {
+				valid++
+			}
+		case <-opts.ctx.Done():
+			return valid
+		}
 	}
-	return nil
 }
@@ -0,0 +1,203 @@
+package cryptoconditions
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ffWebAuthnSha256Cost is the fixed cost value for WEBAUTHN-SHA-256
+	// fulfillments.
+	ffWebAuthnSha256Cost = 131072
+
+	// webAuthnMinAuthenticatorDataSize is the size of the authenticator data
+	// without attested credential data or extensions: the RP ID hash, the
+	// flags and the signature counter.
+	webAuthnMinAuthenticatorDataSize = sha256.Size + 1 + 4
+	// webAuthnFlagUserPresent is the UP flag in the authenticator data.
+	webAuthnFlagUserPresent = 0x01
+	// webAuthnAssertionType is the type in the client data of assertions.
+	webAuthnAssertionType = "webauthn.get"
+)
+
+// FfWebAuthnSha256 implements the experimental WEBAUTHN-SHA-256 fulfillment.
+// The condition is defined by a P-256 credential public key and the SHA-256
+// hash of the relying party ID. The fulfillment is a WebAuthn assertion by
+// that credential whose challenge is the message, which lets users fulfill
+// conditions with passkeys.
+//
+// The signature is encoded as r || s with a low s value, like the signature
+// of ECDSA-P256-SHA-256 fulfillments; FulfillAssertion converts the DER
+// encoded signatures that authenticators return. The origin in the client
+// data is not checked, since it is not part of the condition.
+type FfWebAuthnSha256 struct {
+	PublicKey         []byte `asn1:"tag:0"`
+	RpIdHash          []byte `asn1:"tag:1"`
+	AuthenticatorData []byte `asn1:"tag:2"`
+	ClientDataJSON    []byte `asn1:"tag:3"`
+	Signature         []byte `asn1:"tag:4"`
+}
+
+// NewWebAuthnSha256 creates a new WEBAUTHN-SHA-256 fulfillment from the parts
+// of an assertion. The signature must be encoded as r || s.
+func NewWebAuthnSha256(pubkey, rpIdHash, authenticatorData, clientDataJSON, signature []byte) (*FfWebAuthnSha256, error) {
+	ff := &FfWebAuthnSha256{
+		PublicKey:         pubkey,
+		RpIdHash:          rpIdHash,
+		AuthenticatorData: authenticatorData,
+		ClientDataJSON:    clientDataJSON,
+		Signature:         signature,
+	}
+	if err := ff.check(); err != nil {
+		return nil, err
+	}
+	return ff, nil
+}
+
+// WebAuthnRpIdHash returns the SHA-256 hash of the relying party ID, as it
+// appears in the authenticator data.
+func WebAuthnRpIdHash(rpID string) []byte {
+	hash := sha256.Sum256([]byte(rpID))
+	return hash[:]
+}
+
+// WebAuthnChallenge returns the challenge to request an assertion for the
+// message with, base64url encoded as in the client data. The message is the
+// message that the fulfillment will be validated with, including the
+// prefixes of the PREFIX-SHA-256 conditions above it.
+func WebAuthnChallenge(message []byte) string {
+	return base64.RawURLEncoding.EncodeToString(message)
+}
+
+// webAuthnClientData holds the members of the client data that are checked.
+type webAuthnClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+}
+
+// checkWebAuthnCondition checks the public data of a WEBAUTHN-SHA-256
+// condition.
+func checkWebAuthnCondition(pubkey, rpIdHash []byte) error {
+	if _, err := parseEcdsaP256PublicKey(pubkey); err != nil {
+		return err
+	}
+	if len(rpIdHash) != sha256.Size {
+		return errors.Errorf(
+			"wrong RP ID hash size (%d)", len(rpIdHash))
+	}
+	return nil
+}
+
+// EcdsaPublicKey returns the ECDSA public key of the credential.
+func (f FfWebAuthnSha256) EcdsaPublicKey() (*ecdsa.PublicKey, error) {
+	return parseEcdsaP256PublicKey(f.PublicKey)
+}
+
+func (f FfWebAuthnSha256) ConditionType() ConditionType {
+	return CTWebAuthnSha256
+}
+
+func (f FfWebAuthnSha256) Cost() int {
+	return ffWebAuthnSha256Cost
+}
+
+func (f FfWebAuthnSha256) fingerprintContents() []byte {
+	content := struct {
+		PubKey   []byte `asn1:"tag:0"`
+		RpIdHash []byte `asn1:"tag:1"`
+	}{
+		PubKey:   f.PublicKey,
+		RpIdHash: f.RpIdHash,
+	}
+
+	encoded, err := ASN1Context.Encode(content)
+	if err != nil {
+		panic(err) //TODO check when this can happen
+	}
+
+	return encoded
+}
+
+func (f FfWebAuthnSha256) fingerprint() []byte {
+	hash := sha256.Sum256(f.fingerprintContents())
+	return hash[:]
+}
+
+func (f FfWebAuthnSha256) Condition() *Condition {
+	return NewSimpleCondition(f.ConditionType(), f.fingerprint(), f.Cost())
+}
+
+func (f FfWebAuthnSha256) Encode() ([]byte, error) {
+	return encodeFulfillment(f)
+}
+
+// check checks the encodings of the parts of the fulfillment.
+func (f FfWebAuthnSha256) check() error {
+	if err := checkWebAuthnCondition(f.PublicKey, f.RpIdHash); err != nil {
+		return err
+	}
+	if len(f.AuthenticatorData) < webAuthnMinAuthenticatorDataSize {
+		return errors.Errorf(
+			"authenticator data is too short (%d bytes)", len(f.AuthenticatorData))
+	}
+	_, _, err := parseEcdsaP256Signature(f.Signature)
+	return err
+}
+
+func (f FfWebAuthnSha256) Validate(condition *Condition, message []byte) error {
+	return f.validate(condition, message, nil)
+}
+
+func (f FfWebAuthnSha256) validate(condition *Condition, message []byte, opts *ValidationOptions) error {
+	if !matches(f, condition) {
+		return fulfillmentDoesNotMatchConditionError
+	}
+
+	if err := f.verify(message); err != nil {
+		return fmt.Errorf("Unable to Validate WebAuthnSha256 fulfillment: "+
+			"assertion verification failed for message %x: %s", message, err)
+	}
+	return nil
+}
+
+// verify checks the assertion against the condition and the message.
+func (f FfWebAuthnSha256) verify(message []byte) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	if !bytes.Equal(f.AuthenticatorData[:sha256.Size], f.RpIdHash) {
+		return errors.New("RP ID hash does not match")
+	}
+	if f.AuthenticatorData[sha256.Size]&webAuthnFlagUserPresent == 0 {
+		return errors.New("user was not present")
+	}
+
+	var clientData webAuthnClientData
+	if err := json.Unmarshal(f.ClientDataJSON, &clientData); err != nil {
+		return errors.Wrap(err, "invalid client data")
+	}
+	if clientData.Type != webAuthnAssertionType {
+		return errors.Errorf("wrong client data type %q", clientData.Type)
+	}
+	challenge, err := base64.RawURLEncoding.DecodeString(clientData.Challenge)
+	if err != nil {
+		return errors.Wrap(err, "invalid challenge encoding")
+	}
+	if !bytes.Equal(challenge, message) {
+		return errors.New("challenge does not match the message")
+	}
+
+	// The signature is over the authenticator data and the hash of the
+	// client data.
+	clientDataHash := sha256.Sum256(f.ClientDataJSON)
+	signed := make([]byte, 0, len(f.AuthenticatorData)+len(clientDataHash))
+	signed = append(signed, f.AuthenticatorData...)
+	signed = append(signed, clientDataHash[:]...)
+	return verifyEcdsaP256(f.PublicKey, signed, f.Signature)
+}
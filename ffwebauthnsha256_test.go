@@ -0,0 +1,152 @@
+package cryptoconditions
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAuthenticator is a software WebAuthn authenticator.
+type testAuthenticator struct {
+	key     *ecdsa.PrivateKey
+	rpID    string
+	counter uint32
+}
+
+func newTestAuthenticator(t *testing.T, rpID string) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &testAuthenticator{key: key, rpID: rpID}
+}
+
+// assert returns an assertion for the challenge with the given client data
+// type and flags, with a DER encoded signature.
+func (a *testAuthenticator) assert(t *testing.T, challenge []byte, clientDataType string, flags byte) (authenticatorData, clientDataJSON, signature []byte) {
+	a.counter++
+	authenticatorData = append(WebAuthnRpIdHash(a.rpID), flags)
+	authenticatorData = binary.BigEndian.AppendUint32(authenticatorData, a.counter)
+
+	clientDataJSON, err := json.Marshal(map[string]interface{}{
+		"type":        clientDataType,
+		"challenge":   WebAuthnChallenge(challenge),
+		"origin":      "https://" + a.rpID,
+		"crossOrigin": false,
+	})
+	require.NoError(t, err)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := sha256.Sum256(append(append([]byte{}, authenticatorData...), clientDataHash[:]...))
+	signature, err = ecdsa.SignASN1(rand.Reader, a.key, signed[:])
+	require.NoError(t, err)
+	return authenticatorData, clientDataJSON, signature
+}
+
+func TestFfWebAuthnSha256(t *testing.T) {
+	authenticator := newTestAuthenticator(t, "escrow.example")
+	message := []byte("release 42")
+
+	tmpl, err := WithPrefix([]byte("escrow:"), 64,
+		WebAuthnKey(&authenticator.key.PublicKey, "escrow.example")).Template()
+	require.NoError(t, err)
+	cond := tmpl.Condition()
+	assert.True(t, cond.SubTypes().Has(CTWebAuthnSha256))
+	assert.True(t, CTWebAuthnSha256.IsExperimental())
+	leafTmpl := tmpl.(*PrefixSha256Template).SubTemplate.(*WebAuthnSha256Template)
+
+	fulfill := func(leaf *FfWebAuthnSha256) Fulfillment {
+		partial := NewPartialFulfillment(tmpl)
+		require.NoError(t, partial.SetLeaf(NodePath{0}, leaf))
+		ff, err := partial.Fulfillment()
+		require.NoError(t, err)
+		return ff
+	}
+
+	// The challenge is the message after the prefix.
+	authData, clientData, sig := authenticator.assert(t,
+		[]byte("escrow:release 42"), "webauthn.get", webAuthnFlagUserPresent)
+	leaf, err := leafTmpl.FulfillAssertion(authData, clientData, sig)
+	require.NoError(t, err)
+	ff := fulfill(leaf)
+	assert.NoError(t, ff.Validate(cond, message))
+	assert.Error(t, ff.Validate(cond, []byte("release 43")))
+
+	// The fulfillment and the condition survive DER encoding.
+	der, err := ff.Encode()
+	require.NoError(t, err)
+	decodedFf, err := DecodeFulfillment(der)
+	require.NoError(t, err)
+	assert.NoError(t, decodedFf.Validate(cond, message))
+	assert.True(t, decodedFf.Condition().Equals(cond))
+	assert.Equal(t, cond.URI(), decodedFf.Condition().URI())
+	der, err = cond.Encode()
+	require.NoError(t, err)
+	decodedCond, err := DecodeCondition(der)
+	require.NoError(t, err)
+	assert.True(t, decodedCond.Equals(cond))
+	assert.True(t, decodedCond.SubTypes().Has(CTWebAuthnSha256))
+	assert.Equal(t, cond.URI(), decodedCond.URI())
+
+	invalid := []struct {
+		name      string
+		challenge string
+		typ       string
+		flags     byte
+	}{
+		{"challenge without prefix", "release 42", "webauthn.get", webAuthnFlagUserPresent},
+		{"registration", "escrow:release 42", "webauthn.create", webAuthnFlagUserPresent},
+		{"user not present", "escrow:release 42", "webauthn.get", 0},
+	}
+	for _, v := range invalid {
+		authData, clientData, sig := authenticator.assert(t,
+			[]byte(v.challenge), v.typ, v.flags)
+		leaf, err := leafTmpl.FulfillAssertion(authData, clientData, sig)
+		require.NoError(t, err)
+		assert.Error(t, fulfill(leaf).Validate(cond, message), v.name)
+	}
+
+	// An assertion for another relying party does not fulfill the condition.
+	other := &testAuthenticator{key: authenticator.key, rpID: "evil.example"}
+	authData, clientData, sig = other.assert(t,
+		[]byte("escrow:release 42"), "webauthn.get", webAuthnFlagUserPresent)
+	leaf, err = leafTmpl.FulfillAssertion(authData, clientData, sig)
+	require.NoError(t, err)
+	assert.Error(t, fulfill(leaf).Validate(cond, message))
+
+	// A tampered signature does not validate.
+	authData, clientData, _ = authenticator.assert(t,
+		[]byte("escrow:release 42"), "webauthn.get", webAuthnFlagUserPresent)
+	leaf, err = leafTmpl.Fulfill(authData, clientData, ff.(*FfPrefixSha256).
+		SubFulfillment.(*FfWebAuthnSha256).Signature)
+	require.NoError(t, err)
+	assert.Error(t, fulfill(leaf).Validate(cond, message))
+}
+
+func TestFfWebAuthnSha256_partialJSON(t *testing.T) {
+	authenticator := newTestAuthenticator(t, "escrow.example")
+	message := []byte("release 42")
+	tmpl, err := WebAuthnKey(&authenticator.key.PublicKey, "escrow.example").Template()
+	require.NoError(t, err)
+
+	authData, clientData, sig := authenticator.assert(t, message,
+		"webauthn.get", webAuthnFlagUserPresent)
+	leaf, err := tmpl.(*WebAuthnSha256Template).FulfillAssertion(authData, clientData, sig)
+	require.NoError(t, err)
+	partial := NewPartialFulfillment(tmpl)
+	require.NoError(t, partial.SetLeaf(nil, leaf))
+
+	encoded, err := json.Marshal(partial)
+	require.NoError(t, err)
+	decoded := new(PartialFulfillment)
+	require.NoError(t, json.Unmarshal(encoded, decoded))
+	ff, err := decoded.Fulfillment()
+	require.NoError(t, err)
+	assert.True(t, FulfillmentsEqual(leaf, ff))
+	assert.NoError(t, ff.Validate(tmpl.Condition(), message))
+}
@@ -0,0 +1,220 @@
+package cryptoconditions
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// The parameters of the Winternitz one-time signatures. Every chain encodes
+// four bits, so the 256 bits of the message digest take 64 chains and the
+// checksum, at most 64*15, takes another three.
+const (
+	wotsN    = sha256.Size
+	wotsW    = 16
+	wotsLen1 = 2 * sha256.Size
+	wotsLen2 = 3
+	wotsLen  = wotsLen1 + wotsLen2
+
+	// WotsSignatureSize is the size of a WOTS-SHA-256 signature.
+	WotsSignatureSize = wotsLen * wotsN
+
+	// Domain separation bytes of the hash functions.
+	wotsDomainChain     = 0x00
+	wotsDomainPublicKey = 0x01
+	wotsDomainSecret    = 0x02
+	wotsDomainMessage   = 0x03
+
+	// wotsChainInputSize is the size of the input of a chain step: domain,
+	// public seed, chain index, step index and value.
+	wotsChainInputSize = 1 + wotsN + 1 + 1 + wotsN
+	// wotsPublicKeyInputSize is the size of the input of the public key hash:
+	// domain, public seed and chain ends.
+	wotsPublicKeyInputSize = 1 + wotsN + wotsLen*wotsN
+
+	// ffWotsSha256Cost is the fixed cost value for WOTS-SHA-256 fulfillments.
+	// It is the number of bytes that validation hashes in the worst case,
+	// apart from the message.
+	ffWotsSha256Cost = wotsLen*(wotsW-1)*wotsChainInputSize + wotsPublicKeyInputSize
+)
+
+// FfWotsSha256 implements the experimental WOTS-SHA-256 fulfillment, a
+// Winternitz one-time signature that relies only on SHA-256.
+// The condition is defined by a random public seed and the hash of the
+// public key. A key must sign only a single message; signing two messages
+// allows others to forge signatures.
+type FfWotsSha256 struct {
+	PublicSeed    []byte `asn1:"tag:0"`
+	PublicKeyHash []byte `asn1:"tag:1"`
+	Signature     []byte `asn1:"tag:2"`
+}
+
+// NewWotsSha256 creates a new WOTS-SHA-256 fulfillment. The signature may be
+// empty, to be filled in later.
+func NewWotsSha256(publicSeed, publicKeyHash, signature []byte) (*FfWotsSha256, error) {
+	if err := checkWotsPublicKey(publicSeed, publicKeyHash); err != nil {
+		return nil, err
+	}
+	if len(signature) != WotsSignatureSize && len(signature) != 0 {
+		return nil, errors.Errorf(
+			"wrong signature size (%d)", len(signature))
+	}
+	return &FfWotsSha256{
+		PublicSeed:    publicSeed,
+		PublicKeyHash: publicKeyHash,
+		Signature:     signature,
+	}, nil
+}
+
+// checkWotsPublicKey checks the sizes of the public data of a WOTS-SHA-256
+// condition.
+func checkWotsPublicKey(publicSeed, publicKeyHash []byte) error {
+	if len(publicSeed) != wotsN {
+		return errors.Errorf(
+			"wrong public seed size (%d)", len(publicSeed))
+	}
+	if len(publicKeyHash) != wotsN {
+		return errors.Errorf(
+			"wrong public key hash size (%d)", len(publicKeyHash))
+	}
+	return nil
+}
+
+// WotsPublicKey returns the one-time public key.
+func (f FfWotsSha256) WotsPublicKey() WotsPublicKey {
+	return WotsPublicKey{Seed: f.PublicSeed, Hash: f.PublicKeyHash}
+}
+
+func (f FfWotsSha256) ConditionType() ConditionType {
+	return CTWotsSha256
+}
+
+func (f FfWotsSha256) Cost() int {
+	return ffWotsSha256Cost
+}
+
+func (f FfWotsSha256) fingerprintContents() []byte {
+	content := struct {
+		PublicSeed    []byte `asn1:"tag:0"`
+		PublicKeyHash []byte `asn1:"tag:1"`
+	}{
+		PublicSeed:    f.PublicSeed,
+		PublicKeyHash: f.PublicKeyHash,
+	}
+
+	encoded, err := ASN1Context.Encode(content)
+	if err != nil {
+		panic(err) //TODO check when this can happen
+	}
+
+	return encoded
+}
+
+func (f FfWotsSha256) fingerprint() []byte {
+	hash := sha256.Sum256(f.fingerprintContents())
+	return hash[:]
+}
+
+func (f FfWotsSha256) Condition() *Condition {
+	return NewSimpleCondition(f.ConditionType(), f.fingerprint(), f.Cost())
+}
+
+func (f FfWotsSha256) Encode() ([]byte, error) {
+	return encodeFulfillment(f)
+}
+
+// check checks the sizes of the parts of the fulfillment.
+func (f FfWotsSha256) check() error {
+	if err := checkWotsPublicKey(f.PublicSeed, f.PublicKeyHash); err != nil {
+		return err
+	}
+	if len(f.Signature) != WotsSignatureSize {
+		return errors.Errorf(
+			"wrong signature size (%d)", len(f.Signature))
+	}
+	return nil
+}
+
+func (f FfWotsSha256) Validate(condition *Condition, message []byte) error {
+	return f.validate(condition, message, nil)
+}
+
+func (f FfWotsSha256) validate(condition *Condition, message []byte, opts *ValidationOptions) error {
+	if !matches(f, condition) {
+		return fulfillmentDoesNotMatchConditionError
+	}
+
+	if err := verifyWots(f.PublicSeed, f.PublicKeyHash, message, f.Signature); err != nil {
+		return fmt.Errorf("Unable to Validate WotsSha256 fulfillment: "+
+			"signature verification failed for message %x: %s", message, err)
+	}
+	return nil
+}
+
+// verifyWots verifies a WOTS-SHA-256 signature of the message by completing
+// the chains and comparing the hash of their ends with the public key hash.
+func verifyWots(publicSeed, publicKeyHash, message, signature []byte) error {
+	if err := checkWotsPublicKey(publicSeed, publicKeyHash); err != nil {
+		return err
+	}
+	if len(signature) != WotsSignatureSize {
+		return errors.Errorf(
+			"wrong signature size (%d)", len(signature))
+	}
+
+	digits := wotsDigits(publicSeed, message)
+	ends := make([]byte, 0, WotsSignatureSize)
+	for i, d := range digits {
+		value := signature[i*wotsN : (i+1)*wotsN]
+		ends = append(ends, wotsChain(publicSeed, i, int(d), wotsW-1-int(d), value)...)
+	}
+	if !bytes.Equal(wotsPublicKeyHash(publicSeed, ends), publicKeyHash) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// wotsDigits returns the base-16 digits of the message digest followed by
+// the digits of their checksum.
+func wotsDigits(publicSeed, message []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{wotsDomainMessage})
+	h.Write(publicSeed)
+	h.Write(message)
+	digest := h.Sum(nil)
+
+	digits := make([]byte, 0, wotsLen)
+	checksum := 0
+	for _, b := range digest {
+		digits = append(digits, b>>4, b&0x0f)
+		checksum += 2*(wotsW-1) - int(b>>4) - int(b&0x0f)
+	}
+	return append(digits,
+		byte(checksum>>8)&0x0f, byte(checksum>>4)&0x0f, byte(checksum)&0x0f)
+}
+
+// wotsChain applies steps steps of chain i to the value, starting at step
+// start.
+func wotsChain(publicSeed []byte, i, start, steps int, value []byte) []byte {
+	input := make([]byte, 0, wotsChainInputSize)
+	for j := start; j < start+steps; j++ {
+		input = append(input[:0], wotsDomainChain)
+		input = append(input, publicSeed...)
+		input = append(input, byte(i), byte(j))
+		input = append(input, value...)
+		hash := sha256.Sum256(input)
+		value = hash[:]
+	}
+	return value
+}
+
+// wotsPublicKeyHash hashes the ends of all chains to the public key hash.
+func wotsPublicKeyHash(publicSeed, ends []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{wotsDomainPublicKey})
+	h.Write(publicSeed)
+	h.Write(ends)
+	return h.Sum(nil)
+}
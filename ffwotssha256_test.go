@@ -0,0 +1,97 @@
+package cryptoconditions
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestFfWotsSha256(t *testing.T) {
+	key, err := GenerateWotsKey(rand.Reader)
+	require.NoError(t, err)
+	message := []byte("hello")
+
+	// A stored copy of the key has the same public key.
+	stored, err := key.MarshalBinary()
+	require.NoError(t, err)
+	copied, err := ParseWotsPrivateKey(stored)
+	require.NoError(t, err)
+	assert.Equal(t, key.PublicKey(), copied.PublicKey())
+
+	ff, err := SignWots(key, message)
+	require.NoError(t, err)
+	cond := ff.Condition()
+	assert.Equal(t, CTWotsSha256, cond.Type())
+	assert.True(t, cond.Type().IsExperimental())
+	assert.Equal(t, ffWotsSha256Cost, cond.Cost())
+	assert.Len(t, ff.Signature, WotsSignatureSize)
+	assert.NoError(t, ff.Validate(cond, message))
+	assert.Error(t, ff.Validate(cond, []byte("hello!")))
+
+	tmpl, err := TemplateForPublicKey(key.Public())
+	require.NoError(t, err)
+	assert.True(t, tmpl.Condition().Equals(cond))
+
+	// The fulfillment and the condition survive DER encoding.
+	der, err := ff.Encode()
+	require.NoError(t, err)
+	decodedFf, err := DecodeFulfillment(der)
+	require.NoError(t, err)
+	assert.NoError(t, decodedFf.Validate(cond, message))
+	assert.True(t, decodedFf.Condition().Equals(cond))
+	assert.Equal(t, cond.URI(), decodedFf.Condition().URI())
+	der, err = cond.Encode()
+	require.NoError(t, err)
+	decodedCond, err := DecodeCondition(der)
+	require.NoError(t, err)
+	assert.True(t, decodedCond.Equals(cond))
+	assert.Equal(t, cond.URI(), decodedCond.URI())
+
+	// Every chain value of the signature matters.
+	for i := 0; i < WotsSignatureSize; i += wotsN {
+		tampered := append([]byte{}, ff.Signature...)
+		tampered[i] ^= 1
+		assert.Error(t, FfWotsSha256{ff.PublicSeed, ff.PublicKeyHash, tampered}.
+			Validate(cond, message))
+	}
+
+	// The key refuses to sign a second message.
+	assert.True(t, key.Used())
+	_, err = SignWots(key, []byte("other"))
+	assert.Equal(t, ErrWotsKeyUsed, errors.Cause(err))
+	_, err = key.MarshalBinary()
+	assert.Equal(t, ErrWotsKeyUsed, err)
+
+	_, err = NewWotsSha256(ff.PublicSeed, ff.PublicKeyHash, ff.Signature[1:])
+	assert.Error(t, err)
+}
+
+func TestSign_wots(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	fallback, err := GenerateWotsKey(rand.Reader)
+	require.NoError(t, err)
+	message := []byte("payment 42")
+
+	tmpl, err := AllOf(
+		Ed25519Key(alice.Public().(ed25519.PublicKey)),
+		WotsKey(fallback.PublicKey()),
+	).Template()
+	require.NoError(t, err)
+	cond := tmpl.Condition()
+
+	keyring, err := NewMemoryKeyring(alice, fallback)
+	require.NoError(t, err)
+	ff, missing, err := Sign(tmpl, message, keyring, nil)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.NoError(t, ff.Validate(cond, message))
+
+	// Signing another message with the same tree fails.
+	_, _, err = Sign(tmpl, []byte("payment 43"), keyring, nil)
+	assert.Equal(t, ErrWotsKeyUsed, errors.Cause(err))
+}
@@ -0,0 +1,244 @@
+package frost
+
+import (
+	"io"
+
+	"filippo.io/edwards25519"
+	"github.com/pkg/errors"
+)
+
+// Round1Package is broadcast by every participant to all others in the
+// first round of the distributed key generation.
+type Round1Package struct {
+	// Commitment holds the commitments to the coefficients of the secret
+	// polynomial of the participant.
+	Commitment [][]byte
+	// ProofR and ProofZ are a Schnorr proof of knowledge of the secret
+	// constant term of the polynomial.
+	ProofR []byte
+	ProofZ []byte
+}
+
+// Round2Package is sent by every participant to each other participant in
+// the second round of the distributed key generation. It holds a secret
+// share and must be sent over a confidential and authenticated channel.
+type Round2Package struct {
+	SigningShare []byte
+}
+
+// DKGParticipant runs the distributed key generation for one participant.
+// It follows the key generation of the original FROST paper: every
+// participant deals shares of its own secret polynomial to the others and
+// proves knowledge of its secret, and the group key is the sum of all
+// secrets.
+type DKGParticipant struct {
+	identifier   Identifier
+	threshold    int
+	maxSigners   int
+	coefficients []*edwards25519.Scalar
+	commitment   []*edwards25519.Point
+	// commitments holds the commitments of the other participants, received
+	// in the first round.
+	commitments map[Identifier][]*edwards25519.Point
+}
+
+// NewDKGParticipant starts the distributed key generation for the
+// participant with the given identifier, in a group of maxSigners
+// participants of which threshold are needed to sign. It returns the package
+// to broadcast to the other participants.
+func NewDKGParticipant(id Identifier, threshold, maxSigners int, rand io.Reader) (*DKGParticipant, *Round1Package, error) {
+	switch {
+	case id == 0:
+		return nil, nil, errors.New("identifier must not be zero")
+	case threshold < 2:
+		return nil, nil, errors.Errorf("threshold must be at least 2, not %d", threshold)
+	case threshold > maxSigners:
+		return nil, nil, errors.Errorf(
+			"threshold of %d exceeds the number of signers (%d)", threshold, maxSigners)
+	}
+
+	p := &DKGParticipant{
+		identifier:   id,
+		threshold:    threshold,
+		maxSigners:   maxSigners,
+		coefficients: make([]*edwards25519.Scalar, threshold),
+		commitment:   make([]*edwards25519.Point, threshold),
+	}
+	pkg := &Round1Package{Commitment: make([][]byte, threshold)}
+	for i := range p.coefficients {
+		coefficient, err := randomScalar(rand)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.coefficients[i] = coefficient
+		p.commitment[i] = new(edwards25519.Point).ScalarBaseMult(coefficient)
+		pkg.Commitment[i] = p.commitment[i].Bytes()
+	}
+
+	k, err := randomScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := new(edwards25519.Point).ScalarBaseMult(k)
+	c := proofChallenge(id, p.commitment[0], r)
+	pkg.ProofR = r.Bytes()
+	pkg.ProofZ = edwards25519.NewScalar().MultiplyAdd(p.coefficients[0], c, k).Bytes()
+	return p, pkg, nil
+}
+
+// proofChallenge computes the challenge of the proof of knowledge of the
+// secret of a participant.
+func proofChallenge(id Identifier, secretCommitment, r *edwards25519.Point) *edwards25519.Scalar {
+	var input []byte
+	input = append(input, id.scalar().Bytes()...)
+	input = append(input, secretCommitment.Bytes()...)
+	input = append(input, r.Bytes()...)
+	return hdkg(input)
+}
+
+// Round2 checks the first round packages of all other participants and
+// returns the packages to send to each of them.
+func (p *DKGParticipant) Round2(packages map[Identifier]*Round1Package) (map[Identifier]*Round2Package, error) {
+	if p.coefficients == nil {
+		return nil, errors.New("key generation is already finished")
+	}
+	if len(packages) != p.maxSigners-1 {
+		return nil, errors.Errorf("expected %d packages, got %d",
+			p.maxSigners-1, len(packages))
+	}
+
+	commitments := make(map[Identifier][]*edwards25519.Point, len(packages))
+	for id, pkg := range packages {
+		if id == 0 || id == p.identifier {
+			return nil, errors.Errorf("unexpected package from participant %d", id)
+		}
+		commitment, err := p.checkRound1Package(id, pkg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid package from participant %d", id)
+		}
+		commitments[id] = commitment
+	}
+	p.commitments = commitments
+
+	shares := make(map[Identifier]*Round2Package, len(packages))
+	for id := range packages {
+		share := evaluatePolynomial(p.coefficients, id.scalar())
+		shares[id] = &Round2Package{SigningShare: share.Bytes()}
+	}
+	return shares, nil
+}
+
+// checkRound1Package checks the proof of knowledge in the package and returns
+// the decoded commitment.
+func (p *DKGParticipant) checkRound1Package(id Identifier, pkg *Round1Package) ([]*edwards25519.Point, error) {
+	if len(pkg.Commitment) != p.threshold {
+		return nil, errors.Errorf("expected %d commitments, got %d",
+			p.threshold, len(pkg.Commitment))
+	}
+	commitment := make([]*edwards25519.Point, len(pkg.Commitment))
+	for i, encoded := range pkg.Commitment {
+		element, err := decodeElement(encoded)
+		if err != nil {
+			return nil, err
+		}
+		commitment[i] = element
+	}
+
+	r, err := decodeElement(pkg.ProofR)
+	if err != nil {
+		return nil, err
+	}
+	z, err := decodeScalar(pkg.ProofZ)
+	if err != nil {
+		return nil, err
+	}
+	// Check that z*G - c*commitment[0] = R.
+	c := proofChallenge(id, commitment[0], r)
+	negCommitment := new(edwards25519.Point).Negate(commitment[0])
+	check := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(c, negCommitment, z)
+	if check.Equal(r) != 1 {
+		return nil, errors.New("invalid proof of knowledge")
+	}
+	return commitment, nil
+}
+
+// Finish checks the second round packages of all other participants and
+// returns the key package of the participant and the public key package of
+// the group.
+func (p *DKGParticipant) Finish(packages map[Identifier]*Round2Package) (*KeyPackage, *PublicKeyPackage, error) {
+	if p.commitments == nil {
+		return nil, nil, errors.New("round 2 has not been run")
+	}
+	if len(packages) != len(p.commitments) {
+		return nil, nil, errors.Errorf("expected %d packages, got %d",
+			len(p.commitments), len(packages))
+	}
+
+	x := p.identifier.scalar()
+	secretShare := evaluatePolynomial(p.coefficients, x)
+	for id, pkg := range packages {
+		commitment, ok := p.commitments[id]
+		if !ok {
+			return nil, nil, errors.Errorf("unexpected package from participant %d", id)
+		}
+		share, err := decodeScalar(pkg.SigningShare)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid package from participant %d", id)
+		}
+		expected := evaluateCommitment(commitment, x)
+		if new(edwards25519.Point).ScalarBaseMult(share).Equal(expected) != 1 {
+			return nil, nil, errors.Errorf(
+				"share from participant %d does not match its commitment", id)
+		}
+		secretShare.Add(secretShare, share)
+	}
+
+	all := make(map[Identifier][]*edwards25519.Point, len(p.commitments)+1)
+	for id, commitment := range p.commitments {
+		all[id] = commitment
+	}
+	all[p.identifier] = p.commitment
+
+	groupPublicKey := edwards25519.NewIdentityPoint()
+	for _, commitment := range all {
+		groupPublicKey.Add(groupPublicKey, commitment[0])
+	}
+	verifyingShares := make(map[Identifier]*edwards25519.Point, len(all))
+	for id := range all {
+		share := edwards25519.NewIdentityPoint()
+		for _, commitment := range all {
+			share.Add(share, evaluateCommitment(commitment, id.scalar()))
+		}
+		verifyingShares[id] = share
+	}
+
+	// The polynomial is no longer needed.
+	for _, coefficient := range p.coefficients {
+		coefficient.Set(edwards25519.NewScalar())
+	}
+	p.coefficients = nil
+
+	keyPackage := &KeyPackage{
+		identifier:     p.identifier,
+		secretShare:    secretShare,
+		verifyingShare: verifyingShares[p.identifier],
+		groupPublicKey: groupPublicKey,
+		threshold:      p.threshold,
+	}
+	publicKeyPackage := &PublicKeyPackage{
+		verifyingShares: verifyingShares,
+		groupPublicKey:  groupPublicKey,
+	}
+	return keyPackage, publicKeyPackage, nil
+}
+
+// evaluateCommitment evaluates the polynomial committed to at x, in the
+// exponent.
+func evaluateCommitment(commitment []*edwards25519.Point, x *edwards25519.Scalar) *edwards25519.Point {
+	value := edwards25519.NewIdentityPoint()
+	for i := len(commitment) - 1; i >= 0; i-- {
+		value.ScalarMult(x, value)
+		value.Add(value, commitment[i])
+	}
+	return value
+}
@@ -0,0 +1,215 @@
+// Package frost implements FROST threshold signatures over Ed25519 as
+// specified in RFC 9591, with the FROST(Ed25519, SHA-512) ciphersuite.
+//
+// A group of participants runs a distributed key generation to obtain a
+// single Ed25519 public key of which no participant knows the private key.
+// Any threshold of the participants can then produce a signature in two
+// rounds. The signature is an ordinary Ed25519 signature, so the
+// ED25519-SHA-256 condition of the group public key looks like any other
+// single-key condition, and the fulfillment is a plain FfEd25519Sha256.
+//
+// Unlike THRESHOLD-SHA-256 conditions, the fulfillment does not reveal the
+// signers and its size and cost do not grow with the size of the group.
+package frost
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"io"
+
+	"filippo.io/edwards25519"
+	"github.com/go-interledger/cryptoconditions"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// contextString is the context string of the FROST(Ed25519, SHA-512)
+// ciphersuite.
+const contextString = "FROST-ED25519-SHA512-v1"
+
+// Identifier identifies a participant. Identifiers must be non-zero.
+type Identifier uint16
+
+// scalar returns the identifier as a scalar.
+func (id Identifier) scalar() *edwards25519.Scalar {
+	var b [32]byte
+	binary.LittleEndian.PutUint16(b[:], uint16(id))
+	s, _ := edwards25519.NewScalar().SetCanonicalBytes(b[:])
+	return s
+}
+
+// KeyPackage holds the key material of one participant, as produced by the
+// distributed key generation. The secret share must be kept private.
+type KeyPackage struct {
+	identifier     Identifier
+	secretShare    *edwards25519.Scalar
+	verifyingShare *edwards25519.Point
+	groupPublicKey *edwards25519.Point
+	threshold      int
+}
+
+// Identifier returns the identifier of the participant.
+func (k *KeyPackage) Identifier() Identifier {
+	return k.identifier
+}
+
+// Threshold returns the number of participants needed to sign.
+func (k *KeyPackage) Threshold() int {
+	return k.threshold
+}
+
+// GroupPublicKey returns the Ed25519 public key of the group.
+func (k *KeyPackage) GroupPublicKey() ed25519.PublicKey {
+	return ed25519.PublicKey(k.groupPublicKey.Bytes())
+}
+
+// PublicKeyPackage holds the public key material of a group: the group public
+// key and the public key shares of all participants, which are used to check
+// the signature shares.
+type PublicKeyPackage struct {
+	verifyingShares map[Identifier]*edwards25519.Point
+	groupPublicKey  *edwards25519.Point
+}
+
+// GroupPublicKey returns the Ed25519 public key of the group.
+func (p *PublicKeyPackage) GroupPublicKey() ed25519.PublicKey {
+	return ed25519.PublicKey(p.groupPublicKey.Bytes())
+}
+
+// VerifyingShare returns the public key share of the participant, or nil if
+// the participant is not part of the group.
+func (p *PublicKeyPackage) VerifyingShare(id Identifier) []byte {
+	share, ok := p.verifyingShares[id]
+	if !ok {
+		return nil
+	}
+	return share.Bytes()
+}
+
+// Template returns the ED25519-SHA-256 template for the group public key.
+func (p *PublicKeyPackage) Template() *cryptoconditions.Ed25519Sha256Template {
+	return &cryptoconditions.Ed25519Sha256Template{PublicKey: p.GroupPublicKey()}
+}
+
+// Condition returns the ED25519-SHA-256 condition for the group public key.
+func (p *PublicKeyPackage) Condition() *cryptoconditions.Condition {
+	return p.Template().Condition()
+}
+
+// hashToScalar hashes the inputs with SHA-512 and reduces the digest to a
+// scalar.
+func hashToScalar(inputs ...[]byte) *edwards25519.Scalar {
+	h := sha512.New()
+	for _, input := range inputs {
+		h.Write(input)
+	}
+	s, _ := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	return s
+}
+
+// hash is SHA-512 over the inputs.
+func hash(inputs ...[]byte) []byte {
+	h := sha512.New()
+	for _, input := range inputs {
+		h.Write(input)
+	}
+	return h.Sum(nil)
+}
+
+// h1 computes the binding factors.
+func h1(m []byte) *edwards25519.Scalar {
+	return hashToScalar([]byte(contextString+"rho"), m)
+}
+
+// h2 computes the challenge. It has no domain separation, so that the
+// signatures are RFC 8032 signatures.
+func h2(m []byte) *edwards25519.Scalar {
+	return hashToScalar(m)
+}
+
+// h3 derives nonces.
+func h3(m []byte) *edwards25519.Scalar {
+	return hashToScalar([]byte(contextString+"nonce"), m)
+}
+
+// h4 hashes the message for the binding factors.
+func h4(m []byte) []byte {
+	return hash([]byte(contextString+"msg"), m)
+}
+
+// h5 hashes the commitments for the binding factors.
+func h5(m []byte) []byte {
+	return hash([]byte(contextString+"com"), m)
+}
+
+// hdkg computes the challenge of the proofs of knowledge in the distributed
+// key generation.
+func hdkg(m []byte) *edwards25519.Scalar {
+	return hashToScalar([]byte(contextString+"dkg"), m)
+}
+
+// randomScalar returns a uniformly random scalar.
+func randomScalar(rand io.Reader) (*edwards25519.Scalar, error) {
+	var b [64]byte
+	if _, err := io.ReadFull(rand, b[:]); err != nil {
+		return nil, errors.Wrap(err, "failed to read randomness")
+	}
+	return edwards25519.NewScalar().SetUniformBytes(b[:])
+}
+
+// decodeElement decodes a group element and rejects the identity.
+func decodeElement(b []byte) (*edwards25519.Point, error) {
+	p, err := new(edwards25519.Point).SetBytes(b)
+	if err != nil {
+		return nil, errors.New("invalid group element")
+	}
+	if p.Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return nil, errors.New("group element is the identity")
+	}
+	return p, nil
+}
+
+// decodeScalar decodes a canonically encoded scalar.
+func decodeScalar(b []byte) (*edwards25519.Scalar, error) {
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(b)
+	if err != nil {
+		return nil, errors.New("invalid scalar")
+	}
+	return s, nil
+}
+
+// interpolatingValue computes the Lagrange coefficient of the participant for
+// interpolating at zero over the given participants.
+func interpolatingValue(participants []Identifier, id Identifier) (*edwards25519.Scalar, error) {
+	x := id.scalar()
+	numerator := edwards25519.NewScalar().Set(oneScalar())
+	denominator := edwards25519.NewScalar().Set(oneScalar())
+	found := false
+	for _, other := range participants {
+		if other == id {
+			found = true
+			continue
+		}
+		xj := other.scalar()
+		numerator.Multiply(numerator, xj)
+		denominator.Multiply(denominator, edwards25519.NewScalar().Subtract(xj, x))
+	}
+	if !found {
+		return nil, errors.Errorf("participant %d is not among the signers", id)
+	}
+	return numerator.Multiply(numerator, denominator.Invert(denominator)), nil
+}
+
+func oneScalar() *edwards25519.Scalar {
+	return Identifier(1).scalar()
+}
+
+// evaluatePolynomial evaluates the polynomial with the given coefficients,
+// constant term first, at x.
+func evaluatePolynomial(coefficients []*edwards25519.Scalar, x *edwards25519.Scalar) *edwards25519.Scalar {
+	value := edwards25519.NewScalar()
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		value.MultiplyAdd(value, x, coefficients[i])
+	}
+	return value
+}
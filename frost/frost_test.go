@@ -0,0 +1,277 @@
+package frost
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"filippo.io/edwards25519"
+	"github.com/go-interledger/cryptoconditions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// runDKG simulates the distributed key generation between in-process
+// participants.
+func runDKG(t *testing.T, threshold, maxSigners int) (map[Identifier]*KeyPackage, *PublicKeyPackage) {
+	participants := make(map[Identifier]*DKGParticipant)
+	round1 := make(map[Identifier]*Round1Package)
+	for i := 1; i <= maxSigners; i++ {
+		id := Identifier(i)
+		p, pkg, err := NewDKGParticipant(id, threshold, maxSigners, rand.Reader)
+		require.NoError(t, err)
+		participants[id] = p
+		round1[id] = pkg
+	}
+
+	// round2[to][from] holds the package sent from one participant to
+	// another.
+	round2 := make(map[Identifier]map[Identifier]*Round2Package)
+	for id := range participants {
+		round2[id] = make(map[Identifier]*Round2Package)
+	}
+	for id, p := range participants {
+		received := make(map[Identifier]*Round1Package)
+		for other, pkg := range round1 {
+			if other != id {
+				received[other] = pkg
+			}
+		}
+		sent, err := p.Round2(received)
+		require.NoError(t, err)
+		for to, pkg := range sent {
+			round2[to][id] = pkg
+		}
+	}
+
+	keys := make(map[Identifier]*KeyPackage)
+	var pub *PublicKeyPackage
+	for id, p := range participants {
+		key, pkp, err := p.Finish(round2[id])
+		require.NoError(t, err)
+		keys[id] = key
+		if pub != nil {
+			assert.Equal(t, pub.GroupPublicKey(), pkp.GroupPublicKey())
+		}
+		pub = pkp
+	}
+	return keys, pub
+}
+
+// sign simulates a signing session between the given signers.
+func sign(t *testing.T, keys map[Identifier]*KeyPackage, signers []Identifier, message []byte) (*SigningPackage, []*SignatureShare) {
+	nonces := make(map[Identifier]*SigningNonces)
+	var commitments []*SigningCommitment
+	for _, id := range signers {
+		n, c, err := keys[id].Commit(rand.Reader)
+		require.NoError(t, err)
+		nonces[id] = n
+		commitments = append(commitments, c)
+	}
+	pkg, err := NewSigningPackage(commitments, message)
+	require.NoError(t, err)
+
+	var shares []*SignatureShare
+	for _, id := range signers {
+		share, err := keys[id].Sign(nonces[id], pkg)
+		require.NoError(t, err)
+		shares = append(shares, share)
+	}
+	return pkg, shares
+}
+
+func TestFROST(t *testing.T) {
+	keys, pub := runDKG(t, 3, 5)
+	cond := pub.Condition()
+	assert.Equal(t, cryptoconditions.CTEd25519Sha256, cond.Type())
+	message := []byte("payment 42")
+
+	for _, signers := range [][]Identifier{{1, 2, 3}, {5, 3, 1}, {2, 3, 4, 5}} {
+		pkg, shares := sign(t, keys, signers, message)
+		ff, err := AggregateFulfillment(pkg, shares, pub)
+		require.NoError(t, err, "signers %v", signers)
+		assert.NoError(t, ff.Validate(cond, message))
+		assert.True(t, ed25519.Verify(pub.GroupPublicKey(), message, ff.Signature))
+	}
+
+	// Two signers are not enough.
+	pkg, shares := sign(t, keys, []Identifier{1, 2}, message)
+	_, err := Aggregate(pkg, shares, pub)
+	assert.Error(t, err)
+}
+
+func TestFROST_invalidShare(t *testing.T) {
+	keys, pub := runDKG(t, 2, 3)
+	message := []byte("payment 42")
+
+	pkg, shares := sign(t, keys, []Identifier{1, 3}, message)
+	shares[1].Share[0] ^= 1
+	_, err := Aggregate(pkg, shares, pub)
+	assert.EqualError(t, err, "invalid signature share of participant 3")
+
+	// Nonces cannot be reused.
+	nonces, commitment, err := keys[1].Commit(rand.Reader)
+	require.NoError(t, err)
+	_, other, err := keys[2].Commit(rand.Reader)
+	require.NoError(t, err)
+	pkg, err = NewSigningPackage([]*SigningCommitment{commitment, other}, message)
+	require.NoError(t, err)
+	_, err = keys[1].Sign(nonces, pkg)
+	require.NoError(t, err)
+	_, err = keys[1].Sign(nonces, pkg)
+	assert.EqualError(t, err, "nonces have already been used")
+}
+
+// unhexScalar decodes a scalar from the test vectors.
+func unhexScalar(t *testing.T, s string) *edwards25519.Scalar {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	scalar, err := decodeScalar(b)
+	require.NoError(t, err)
+	return scalar
+}
+
+// TestFROST_rfc9591 checks the FROST(Ed25519, SHA-512) test vectors of
+// RFC 9591, Appendix E.1, in which participants 1 and 3 of a group with a
+// threshold of 2 sign the message "test".
+func TestFROST_rfc9591(t *testing.T) {
+	groupSecretKey := unhexScalar(t, "7b1c33d3f5291d85de664833beb1ad469f7fb6025a0ec78b3a790c6e13a98304")
+	coefficient := unhexScalar(t, "178199860edd8c62f5212ee91eff1295d0d670ab4ed4506866bae57e7030b204")
+	groupPublicKey := new(edwards25519.Point).ScalarBaseMult(groupSecretKey)
+	assert.Equal(t, "15d21ccd7ee42959562fc8aa63224c8851fb3ec85a3faf66040d380fb9738673",
+		hex.EncodeToString(groupPublicKey.Bytes()))
+	message, err := hex.DecodeString("74657374")
+	require.NoError(t, err)
+
+	// The shares of all three participants.
+	expectedShares := map[Identifier]string{
+		1: "929dcc590407aae7d388761cddb0c0db6f5627aea8e217f4a033f2ec83d93509",
+		2: "a91e66e012e4364ac9aaa405fcafd370402d9859f7b6685c07eed76bf409e80d",
+		3: "d3cb090a075eb154e82fdb4b3cb507f110040905468bb9c46da8bdea643a9a02",
+	}
+	keys := make(map[Identifier]*KeyPackage)
+	pub := &PublicKeyPackage{
+		verifyingShares: make(map[Identifier]*edwards25519.Point),
+		groupPublicKey:  groupPublicKey,
+	}
+	for id, expected := range expectedShares {
+		share := evaluatePolynomial(
+			[]*edwards25519.Scalar{groupSecretKey, coefficient}, id.scalar())
+		assert.Equal(t, expected, hex.EncodeToString(share.Bytes()))
+		verifyingShare := new(edwards25519.Point).ScalarBaseMult(share)
+		keys[id] = &KeyPackage{
+			identifier:     id,
+			secretShare:    share,
+			verifyingShare: verifyingShare,
+			groupPublicKey: groupPublicKey,
+			threshold:      2,
+		}
+		pub.verifyingShares[id] = verifyingShare
+	}
+
+	// The nonces of participant 1 are derived from the given randomness.
+	randomness, err := hex.DecodeString(
+		"0fd2e39e111cdc266f6c0f4d0fd45c947761f1f5d3cb583dfcb9bbaf8d4c9fec" +
+			"69cd85f631d5f7f2721ed5e40519b1366f340a87c2f6856363dbdcda348a7501")
+	require.NoError(t, err)
+	derived, _, err := keys[1].Commit(bytes.NewReader(randomness))
+	require.NoError(t, err)
+	assert.Equal(t, "812d6104142944d5a55924de6d49940956206909f2acaeedecda2b726e630407",
+		hex.EncodeToString(derived.hiding.Bytes()))
+	assert.Equal(t, "b1110165fc2334149750b28dd813a39244f315cff14d4e89e6142f262ed83301",
+		hex.EncodeToString(derived.binding.Bytes()))
+
+	vectors := []struct {
+		id                        Identifier
+		hidingNonce, bindingNonce string
+		hidingCommitment          string
+		bindingCommitment         string
+		bindingFactor             string
+		signatureShare            string
+	}{
+		{
+			id:                1,
+			hidingNonce:       "812d6104142944d5a55924de6d49940956206909f2acaeedecda2b726e630407",
+			bindingNonce:      "b1110165fc2334149750b28dd813a39244f315cff14d4e89e6142f262ed83301",
+			hidingCommitment:  "b5aa8ab305882a6fc69cbee9327e5a45e54c08af61ae77cb8207be3d2ce13de3",
+			bindingCommitment: "67e98ab55aa310c3120418e5050c9cf76cf387cb20ac9e4b6fdb6f82a469f932",
+			bindingFactor:     "f2cb9d7dd9beff688da6fcc83fa89046b3479417f47f55600b106760eb3b5603",
+			signatureShare:    "001719ab5a53ee1a12095cd088fd149702c0720ce5fd2f29dbecf24b7281b603",
+		},
+		{
+			id:                3,
+			hidingNonce:       "c256de65476204095ebdc01bd11dc10e57b36bc96284595b8215222374f99c0e",
+			bindingNonce:      "243d71944d929063bc51205714ae3c2218bd3451d0214dfb5aeec2a90c35180d",
+			hidingCommitment:  "cfbdb165bd8aad6eb79deb8d287bcc0ab6658ae57fdcc98ed12c0669e90aec91",
+			bindingCommitment: "7487bc41a6e712eea2f2af24681b58b1cf1da278ea11fe4e8b78398965f13552",
+			bindingFactor:     "b087686bf35a13f3dc78e780a34b0fe8a77fef1b9938c563f5573d71d8d7890f",
+			signatureShare:    "bd86125de990acc5e1f13781d8e32c03a9bbd4c53539bbc106058bfd14326007",
+		},
+	}
+
+	nonces := make(map[Identifier]*SigningNonces)
+	var commitments []*SigningCommitment
+	for _, v := range vectors {
+		hiding := unhexScalar(t, v.hidingNonce)
+		binding := unhexScalar(t, v.bindingNonce)
+		commitment := &SigningCommitment{
+			Identifier: v.id,
+			Hiding:     new(edwards25519.Point).ScalarBaseMult(hiding).Bytes(),
+			Binding:    new(edwards25519.Point).ScalarBaseMult(binding).Bytes(),
+		}
+		assert.Equal(t, v.hidingCommitment, hex.EncodeToString(commitment.Hiding))
+		assert.Equal(t, v.bindingCommitment, hex.EncodeToString(commitment.Binding))
+		nonces[v.id] = &SigningNonces{hiding, binding, commitment}
+		commitments = append(commitments, commitment)
+	}
+	pkg, err := NewSigningPackage(commitments, message)
+	require.NoError(t, err)
+
+	state, err := pkg.state(groupPublicKey)
+	require.NoError(t, err)
+	var shares []*SignatureShare
+	for _, v := range vectors {
+		assert.Equal(t, v.bindingFactor,
+			hex.EncodeToString(state.bindingFactors[v.id].Bytes()))
+		share, err := keys[v.id].Sign(nonces[v.id], pkg)
+		require.NoError(t, err)
+		assert.Equal(t, v.signatureShare, hex.EncodeToString(share.Share))
+		shares = append(shares, share)
+	}
+
+	signature, err := Aggregate(pkg, shares, pub)
+	require.NoError(t, err)
+	assert.Equal(t, "36282629c383bb820a88b71cae937d41f2f2adfcc3d02e55507e2fb9e2dd3cbe"+
+		"bd9d2b0844e49ae0f3fa935161e1419aab7b47d21a37ebeae1f17d4987b3160b",
+		hex.EncodeToString(signature))
+	assert.True(t, ed25519.Verify(pub.GroupPublicKey(), message, signature))
+}
+
+func TestDKG_cheating(t *testing.T) {
+	p1, pkg1, err := NewDKGParticipant(1, 2, 3, rand.Reader)
+	require.NoError(t, err)
+	p2, pkg2, err := NewDKGParticipant(2, 2, 3, rand.Reader)
+	require.NoError(t, err)
+	_, pkg3, err := NewDKGParticipant(3, 2, 3, rand.Reader)
+	require.NoError(t, err)
+
+	// A participant that does not know its secret is caught in round 2.
+	forged := *pkg3
+	forged.ProofZ = pkg2.ProofZ
+	_, err = p1.Round2(map[Identifier]*Round1Package{2: pkg2, 3: &forged})
+	assert.EqualError(t, err,
+		"invalid package from participant 3: invalid proof of knowledge")
+
+	// A participant that sends a wrong share is caught when finishing.
+	_, err = p1.Round2(map[Identifier]*Round1Package{2: pkg2, 3: pkg3})
+	require.NoError(t, err)
+	shares, err := p2.Round2(map[Identifier]*Round1Package{1: pkg1, 3: pkg3})
+	require.NoError(t, err)
+	_, _, err = p1.Finish(map[Identifier]*Round2Package{
+		2: shares[1],
+		3: shares[3],
+	})
+	assert.EqualError(t, err, "share from participant 3 does not match its commitment")
+}
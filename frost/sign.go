@@ -0,0 +1,292 @@
+package frost
+
+import (
+	"io"
+	"sort"
+
+	"filippo.io/edwards25519"
+	"github.com/go-interledger/cryptoconditions"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// SigningCommitment is the public commitment of a participant to its nonces,
+// sent to the coordinator in the first round of signing.
+type SigningCommitment struct {
+	Identifier Identifier
+	Hiding     []byte
+	Binding    []byte
+}
+
+// SigningNonces holds the secret nonces of a participant for one signing
+// operation. Nonces must never be used for more than one signature, so they
+// are erased once used.
+type SigningNonces struct {
+	hiding     *edwards25519.Scalar
+	binding    *edwards25519.Scalar
+	commitment *SigningCommitment
+}
+
+// Commit generates the nonces of the participant for the first round of
+// signing and returns them together with the commitment to send to the
+// coordinator.
+func (k *KeyPackage) Commit(rand io.Reader) (*SigningNonces, *SigningCommitment, error) {
+	hiding, err := k.generateNonce(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	binding, err := k.generateNonce(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	commitment := &SigningCommitment{
+		Identifier: k.identifier,
+		Hiding:     new(edwards25519.Point).ScalarBaseMult(hiding).Bytes(),
+		Binding:    new(edwards25519.Point).ScalarBaseMult(binding).Bytes(),
+	}
+	return &SigningNonces{hiding, binding, commitment}, commitment, nil
+}
+
+// generateNonce derives a nonce from fresh randomness and the secret share,
+// so that a weak source of randomness does not leak the secret share.
+func (k *KeyPackage) generateNonce(rand io.Reader) (*edwards25519.Scalar, error) {
+	var random [32]byte
+	if _, err := io.ReadFull(rand, random[:]); err != nil {
+		return nil, errors.Wrap(err, "failed to read randomness")
+	}
+	return h3(append(random[:], k.secretShare.Bytes()...)), nil
+}
+
+// SigningPackage is assembled by the coordinator from the commitments of the
+// participating signers and sent to each of them in the second round.
+type SigningPackage struct {
+	// Commitments holds the commitments of the signers, ordered by
+	// identifier.
+	Commitments []*SigningCommitment
+	Message     []byte
+}
+
+// NewSigningPackage creates the signing package for the message.
+func NewSigningPackage(commitments []*SigningCommitment, message []byte) (*SigningPackage, error) {
+	sorted := make([]*SigningCommitment, len(commitments))
+	copy(sorted, commitments)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Identifier < sorted[j].Identifier
+	})
+	pkg := &SigningPackage{Commitments: sorted, Message: message}
+	if _, err := pkg.decode(); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// decodedCommitment is a commitment with decoded elements.
+type decodedCommitment struct {
+	identifier Identifier
+	hiding     *edwards25519.Point
+	binding    *edwards25519.Point
+}
+
+// decode checks the signing package and decodes its commitments.
+func (pkg *SigningPackage) decode() ([]decodedCommitment, error) {
+	if len(pkg.Commitments) < 2 {
+		return nil, errors.New("at least two signers are required")
+	}
+	decoded := make([]decodedCommitment, len(pkg.Commitments))
+	for i, c := range pkg.Commitments {
+		if c.Identifier == 0 {
+			return nil, errors.New("identifier must not be zero")
+		}
+		if i > 0 && c.Identifier <= pkg.Commitments[i-1].Identifier {
+			return nil, errors.New("commitments must be ordered by unique identifiers")
+		}
+		hiding, err := decodeElement(c.Hiding)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid commitment of participant %d", c.Identifier)
+		}
+		binding, err := decodeElement(c.Binding)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid commitment of participant %d", c.Identifier)
+		}
+		decoded[i] = decodedCommitment{c.Identifier, hiding, binding}
+	}
+	return decoded, nil
+}
+
+// signers returns the identifiers of the signers.
+func (pkg *SigningPackage) signers() []Identifier {
+	ids := make([]Identifier, len(pkg.Commitments))
+	for i, c := range pkg.Commitments {
+		ids[i] = c.Identifier
+	}
+	return ids
+}
+
+// signingState holds the values derived from the signing package that both
+// signers and the coordinator need.
+type signingState struct {
+	commitments    []decodedCommitment
+	bindingFactors map[Identifier]*edwards25519.Scalar
+	groupCommit    *edwards25519.Point
+	challenge      *edwards25519.Scalar
+}
+
+// state computes the binding factors, the group commitment and the
+// challenge for the signing package.
+func (pkg *SigningPackage) state(groupPublicKey *edwards25519.Point) (*signingState, error) {
+	commitments, err := pkg.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	var encodedCommitments []byte
+	for _, c := range commitments {
+		encodedCommitments = append(encodedCommitments, c.identifier.scalar().Bytes()...)
+		encodedCommitments = append(encodedCommitments, c.hiding.Bytes()...)
+		encodedCommitments = append(encodedCommitments, c.binding.Bytes()...)
+	}
+	var prefix []byte
+	prefix = append(prefix, groupPublicKey.Bytes()...)
+	prefix = append(prefix, h4(pkg.Message)...)
+	prefix = append(prefix, h5(encodedCommitments)...)
+
+	state := &signingState{
+		commitments:    commitments,
+		bindingFactors: make(map[Identifier]*edwards25519.Scalar, len(commitments)),
+		groupCommit:    edwards25519.NewIdentityPoint(),
+	}
+	for _, c := range commitments {
+		input := append(append([]byte{}, prefix...), c.identifier.scalar().Bytes()...)
+		factor := h1(input)
+		state.bindingFactors[c.identifier] = factor
+		state.groupCommit.Add(state.groupCommit, c.hiding)
+		state.groupCommit.Add(state.groupCommit,
+			new(edwards25519.Point).ScalarMult(factor, c.binding))
+	}
+
+	var challengeInput []byte
+	challengeInput = append(challengeInput, state.groupCommit.Bytes()...)
+	challengeInput = append(challengeInput, groupPublicKey.Bytes()...)
+	challengeInput = append(challengeInput, pkg.Message...)
+	state.challenge = h2(challengeInput)
+	return state, nil
+}
+
+// SignatureShare is the share of the signature produced by one participant
+// in the second round of signing.
+type SignatureShare struct {
+	Identifier Identifier
+	Share      []byte
+}
+
+// Sign produces the signature share of the participant for the signing
+// package, using the nonces generated for it by Commit. The nonces are erased
+// afterwards and cannot be used again.
+func (k *KeyPackage) Sign(nonces *SigningNonces, pkg *SigningPackage) (*SignatureShare, error) {
+	if nonces.hiding == nil {
+		return nil, errors.New("nonces have already been used")
+	}
+	var own *SigningCommitment
+	for _, c := range pkg.Commitments {
+		if c.Identifier == k.identifier {
+			own = c
+		}
+	}
+	if own == nil || string(own.Hiding) != string(nonces.commitment.Hiding) ||
+		string(own.Binding) != string(nonces.commitment.Binding) {
+		return nil, errors.New("signing package does not hold the commitment to the nonces")
+	}
+
+	state, err := pkg.state(k.groupPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	lambda, err := interpolatingValue(pkg.signers(), k.identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	// z = hiding + binding * rho + lambda * secretShare * challenge
+	z := edwards25519.NewScalar().MultiplyAdd(nonces.binding, state.bindingFactors[k.identifier], nonces.hiding)
+	lambdaC := edwards25519.NewScalar().Multiply(lambda, state.challenge)
+	z.MultiplyAdd(lambdaC, k.secretShare, z)
+
+	nonces.hiding.Set(edwards25519.NewScalar())
+	nonces.binding.Set(edwards25519.NewScalar())
+	nonces.hiding, nonces.binding = nil, nil
+
+	return &SignatureShare{Identifier: k.identifier, Share: z.Bytes()}, nil
+}
+
+// Aggregate checks the signature shares of all signers of the signing
+// package and combines them into an Ed25519 signature by the group public
+// key.
+func Aggregate(pkg *SigningPackage, shares []*SignatureShare, pub *PublicKeyPackage) ([]byte, error) {
+	state, err := pkg.state(pub.groupPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(shares) != len(state.commitments) {
+		return nil, errors.Errorf("expected %d signature shares, got %d",
+			len(state.commitments), len(shares))
+	}
+	byIdentifier := make(map[Identifier]*SignatureShare, len(shares))
+	for _, share := range shares {
+		byIdentifier[share.Identifier] = share
+	}
+
+	z := edwards25519.NewScalar()
+	for _, c := range state.commitments {
+		share, ok := byIdentifier[c.identifier]
+		if !ok {
+			return nil, errors.Errorf("missing signature share of participant %d", c.identifier)
+		}
+		zi, err := verifyShare(state, pkg, c, share, pub)
+		if err != nil {
+			return nil, err
+		}
+		z.Add(z, zi)
+	}
+
+	signature := append(state.groupCommit.Bytes(), z.Bytes()...)
+	if !ed25519.Verify(pub.GroupPublicKey(), pkg.Message, signature) {
+		return nil, errors.New("aggregated signature is invalid")
+	}
+	return signature, nil
+}
+
+// verifyShare checks the signature share of a signer and returns it decoded.
+func verifyShare(state *signingState, pkg *SigningPackage, c decodedCommitment, share *SignatureShare, pub *PublicKeyPackage) (*edwards25519.Scalar, error) {
+	verifyingShare, ok := pub.verifyingShares[c.identifier]
+	if !ok {
+		return nil, errors.Errorf("participant %d is not part of the group", c.identifier)
+	}
+	zi, err := decodeScalar(share.Share)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid signature share of participant %d", c.identifier)
+	}
+	lambda, err := interpolatingValue(pkg.signers(), c.identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check that zi*G = hiding + binding * rho + verifyingShare * challenge * lambda.
+	expected := new(edwards25519.Point).ScalarMult(state.bindingFactors[c.identifier], c.binding)
+	expected.Add(expected, c.hiding)
+	cLambda := edwards25519.NewScalar().Multiply(state.challenge, lambda)
+	expected.Add(expected, new(edwards25519.Point).ScalarMult(cLambda, verifyingShare))
+	if new(edwards25519.Point).ScalarBaseMult(zi).Equal(expected) != 1 {
+		return nil, errors.Errorf("invalid signature share of participant %d", c.identifier)
+	}
+	return zi, nil
+}
+
+// AggregateFulfillment is like Aggregate, but returns the ED25519-SHA-256
+// fulfillment for the group public key.
+func AggregateFulfillment(pkg *SigningPackage, shares []*SignatureShare, pub *PublicKeyPackage) (*cryptoconditions.FfEd25519Sha256, error) {
+	signature, err := Aggregate(pkg, shares, pub)
+	if err != nil {
+		return nil, err
+	}
+	return cryptoconditions.NewEd25519Sha256(pub.GroupPublicKey(), signature)
+}
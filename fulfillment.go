@@ -21,6 +21,9 @@ type Fulfillment interface {
 	// It returns nil if it does, an error indicating the problem otherwise.
 	Validate(*Condition, []byte) error
 
+	// validate is like Validate, but uses the given validation options.
+	validate(*Condition, []byte, *ValidationOptions) error
+
 	// fingerprint calculates the fingerprint of the condition this fulfillment
 	// fulfills.
 	fingerprint() []byte
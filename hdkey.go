@@ -0,0 +1,172 @@
+package cryptoconditions
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// HardenedKeyStart is the index of the first hardened child key. Only
+// hardened derivation is defined for Ed25519.
+const HardenedKeyStart uint32 = 0x80000000
+
+// hdKeyMasterSecret is the HMAC key used to derive the master key from a
+// seed, as defined by SLIP-0010 for Ed25519.
+var hdKeyMasterSecret = []byte("ed25519 seed")
+
+const (
+	hdKeyMinSeedLength = 16
+	hdKeyMaxSeedLength = 64
+)
+
+// HDKey is an Ed25519 key in a hierarchy of keys that are deterministically
+// derived from a master seed following SLIP-0010. It makes it possible to
+// use a fresh ED25519-SHA-256 condition for every payment while only
+// storing the seed.
+type HDKey struct {
+	key       []byte
+	chainCode []byte
+	path      []uint32
+}
+
+// NewMasterKey derives the master key from a seed of 16 to 64 bytes.
+func NewMasterKey(seed []byte) (*HDKey, error) {
+	if len(seed) < hdKeyMinSeedLength || len(seed) > hdKeyMaxSeedLength {
+		return nil, errors.Errorf(
+			"seed must be %d to %d bytes, not %d",
+			hdKeyMinSeedLength, hdKeyMaxSeedLength, len(seed))
+	}
+	mac := hmac.New(sha512.New, hdKeyMasterSecret)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	return &HDKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// Child derives the hardened child key with the given index. Indexes below
+// HardenedKeyStart are hardened by adding HardenedKeyStart.
+func (k *HDKey) Child(index uint32) *HDKey {
+	if index < HardenedKeyStart {
+		index += HardenedKeyStart
+	}
+	data := make([]byte, 1+len(k.key)+4)
+	copy(data[1:], k.key)
+	binary.BigEndian.PutUint32(data[1+len(k.key):], index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	path := make([]uint32, len(k.path), len(k.path)+1)
+	copy(path, k.path)
+	return &HDKey{key: sum[:32], chainCode: sum[32:], path: append(path, index)}
+}
+
+// Derive derives the key at the given path relative to this key.
+func (k *HDKey) Derive(path []uint32) *HDKey {
+	key := k
+	for _, index := range path {
+		key = key.Child(index)
+	}
+	return key
+}
+
+// DeriveKey derives the key at the given path, like "m/44'/0'/3'", from the
+// master seed.
+func DeriveKey(seed []byte, path string) (*HDKey, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	return master.Derive(indexes), nil
+}
+
+// ParseDerivationPath parses a derivation path like "m/44'/0'/3'". Since only
+// hardened derivation is possible, the apostrophes or "H" suffixes can be
+// left out.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, errors.Errorf("derivation path must start with m: %s", path)
+	}
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		trimmed := strings.TrimRight(part, "'hH")
+		index, err := strconv.ParseUint(trimmed, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, errors.Errorf("invalid derivation path index: %s", part)
+		}
+		indexes = append(indexes, uint32(index)+HardenedKeyStart)
+	}
+	return indexes, nil
+}
+
+// Path returns the derivation path of the key relative to the master key.
+func (k *HDKey) Path() string {
+	parts := make([]string, len(k.path)+1)
+	parts[0] = "m"
+	for i, index := range k.path {
+		parts[i+1] = strconv.FormatUint(uint64(index-HardenedKeyStart), 10) + "'"
+	}
+	return strings.Join(parts, "/")
+}
+
+// ChainCode returns the chain code of the key.
+func (k *HDKey) ChainCode() []byte {
+	return k.chainCode
+}
+
+// PrivateKey returns the Ed25519 private key.
+func (k *HDKey) PrivateKey() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(k.key)
+}
+
+// PublicKey returns the Ed25519 public key.
+func (k *HDKey) PublicKey() ed25519.PublicKey {
+	return k.PrivateKey().Public().(ed25519.PublicKey)
+}
+
+// Template returns the ED25519-SHA-256 template for the key.
+func (k *HDKey) Template() *Ed25519Sha256Template {
+	return &Ed25519Sha256Template{PublicKey: k.PublicKey()}
+}
+
+// Condition returns the ED25519-SHA-256 condition for the key.
+func (k *HDKey) Condition() *Condition {
+	return k.Template().Condition()
+}
+
+// Sign signs the message and returns the resulting ED25519-SHA-256
+// fulfillment.
+func (k *HDKey) Sign(message []byte) (*FfEd25519Sha256, error) {
+	return SignEd25519(k.PrivateKey(), message)
+}
+
+// FindChild searches the children of the key with indexes from start up to
+// start+gapLimit (exclusive) for the one that produces the given condition.
+// Indexes are given without the HardenedKeyStart offset.
+// It returns the child key, or nil if none of the children matches.
+func (k *HDKey) FindChild(cond *Condition, start, gapLimit uint32) *HDKey {
+	if cond.Type() != CTEd25519Sha256 {
+		return nil
+	}
+	for i := uint32(0); i < gapLimit; i++ {
+		index := start + i
+		if index >= HardenedKeyStart {
+			break
+		}
+		child := k.Child(index)
+		if child.Condition().Equals(cond) {
+			return child
+		}
+	}
+	return nil
+}
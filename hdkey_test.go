@@ -0,0 +1,66 @@
+package cryptoconditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test vector 1 for Ed25519 from SLIP-0010.
+func TestHDKey_slip10Vectors(t *testing.T) {
+	seed := unhex("000102030405060708090a0b0c0d0e0f")
+	vectors := []struct {
+		path, chainCode, privkey, pubkey string
+	}{
+		{"m",
+			"90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb",
+			"2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7",
+			"a4b2856bfec510abab89753fac1ac0e1112364e7d250545963f135f2a33188ed"},
+		{"m/0'",
+			"8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69",
+			"68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3",
+			"8c8a13df77a28f3445213a0f432fde644acaa215fc72dcdf300d5efaa85d350c"},
+		{"m/0'/1'",
+			"a320425f77d1b5c2505a6b1b27382b37368ee640e3557c315416801243552f14",
+			"b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2",
+			"1932a5270f335bed617d5b935c80aedb1a35bd9fc1e31acafd5372c30f5c1187"},
+	}
+
+	for _, v := range vectors {
+		key, err := DeriveKey(seed, v.path)
+		require.NoError(t, err)
+		assert.Equal(t, v.path, key.Path())
+		assert.Equal(t, unhex(v.chainCode), key.ChainCode(), v.path)
+		assert.Equal(t, unhex(v.privkey), []byte(key.PrivateKey().Seed()), v.path)
+		assert.Equal(t, unhex(v.pubkey), []byte(key.PublicKey()), v.path)
+	}
+}
+
+func TestHDKey(t *testing.T) {
+	master, err := NewMasterKey(unhex("000102030405060708090a0b0c0d0e0f"))
+	require.NoError(t, err)
+	account := master.Derive([]uint32{44, 7})
+	assert.Equal(t, "m/44'/7'", account.Path())
+
+	// Fresh conditions for every invoice can be traced back to their index.
+	invoice := account.Child(12)
+	cond := invoice.Condition()
+	found := account.FindChild(cond, 0, 20)
+	require.NotNil(t, found)
+	assert.Equal(t, "m/44'/7'/12'", found.Path())
+	assert.Nil(t, account.FindChild(cond, 0, 12))
+	assert.Nil(t, account.FindChild(cond, 13, 20))
+
+	message := []byte("invoice 12")
+	ff, err := found.Sign(message)
+	require.NoError(t, err)
+	assert.NoError(t, ff.Validate(cond, message))
+
+	_, err = NewMasterKey(make([]byte, 8))
+	assert.Error(t, err)
+	_, err = ParseDerivationPath("44'/0'")
+	assert.Error(t, err)
+	_, err = ParseDerivationPath("m/2147483648'")
+	assert.Error(t, err)
+}
@@ -0,0 +1,307 @@
+package cryptoconditions
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+
+	"github.com/kalaspuffar/base64url"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// PEM block types supported by ParsePEM and ParsePrivateKeyPEM.
+const (
+	pemTypePublicKey     = "PUBLIC KEY"
+	pemTypeRsaPublicKey  = "RSA PUBLIC KEY"
+	pemTypePrivateKey    = "PRIVATE KEY"
+	pemTypeRsaPrivateKey = "RSA PRIVATE KEY"
+	pemTypeOpenSSHKey    = "OPENSSH PRIVATE KEY"
+	pemTypeCertificate   = "CERTIFICATE"
+)
+
+// PublicKeyOf returns the public key of an ED25519-SHA-256, RSA-SHA-256,
+// SECP256K1-SHA-256, ECDSA-P256-SHA-256 or WOTS-SHA-256 leaf template, as an
+// ed25519.PublicKey, an *rsa.PublicKey, a *secp256k1.PublicKey, an
+// *ecdsa.PublicKey or a WotsPublicKey.
+func PublicKeyOf(t Template) (crypto.PublicKey, error) {
+	switch tmpl := derefTemplate(t).(type) {
+	case Ed25519Sha256Template:
+		return tmpl.PublicKey, nil
+	case RsaSha256Template:
+		return tmpl.PublicKey(), nil
+	case Secp256k1Sha256Template:
+		return parseSecp256k1PublicKey(tmpl.PublicKey)
+	case EcdsaP256Sha256Template:
+		return parseEcdsaP256PublicKey(tmpl.PublicKey)
+	case WotsSha256Template:
+		return tmpl.PublicKey, nil
+	}
+	return nil, errors.Errorf(
+		"%s templates have no public key", t.ConditionType())
+}
+
+// ParsePEM returns the leaf template for the key in the first PEM block of
+// data. Supported are PKIX and PKCS #1 public keys, PKCS #8, PKCS #1 and
+// OpenSSH private keys, of which only the public key is used, and X.509
+// certificates.
+func ParsePEM(data []byte) (Template, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var pubkey crypto.PublicKey
+	switch block.Type {
+	case pemTypePublicKey:
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid PKIX public key")
+		}
+		pubkey = key
+	case pemTypeRsaPublicKey:
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid PKCS #1 public key")
+		}
+		pubkey = key
+	case pemTypeCertificate:
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid certificate")
+		}
+		return TemplateForCertificate(cert)
+	default:
+		key, err := parsePrivateKeyBlock(data, block)
+		if err != nil {
+			return nil, err
+		}
+		pubkey = key.Public()
+	}
+	return TemplateForPublicKey(pubkey)
+}
+
+// TemplateForCertificate returns the leaf template for the public key of the
+// certificate. The certificate itself is not verified.
+func TemplateForCertificate(cert *x509.Certificate) (Template, error) {
+	return TemplateForPublicKey(cert.PublicKey)
+}
+
+// ParsePrivateKeyPEM parses an Ed25519 or RSA private key in PKCS #8, PKCS #1
+// or OpenSSH format. Encrypted keys are not supported.
+// RSA keys must satisfy the constraints of RSA-SHA-256.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	key, err := parsePrivateKeyBlock(data, block)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := TemplateForPublicKey(key.Public()); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func parsePrivateKeyBlock(data []byte, block *pem.Block) (crypto.Signer, error) {
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case pemTypePrivateKey:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case pemTypeRsaPrivateKey:
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case pemTypeOpenSSHKey:
+		parsed, err = ssh.ParseRawPrivateKey(data)
+	default:
+		return nil, errors.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", block.Type)
+	}
+
+	switch key := parsed.(type) {
+	case *ed25519.PrivateKey:
+		// The ssh package returns pointers to Ed25519 keys.
+		return *key, nil
+	case crypto.Signer:
+		return key, nil
+	}
+	return nil, errors.Errorf("unsupported private key type %T", parsed)
+}
+
+// MarshalPublicKeyPEM encodes the public key of a leaf template as a PKIX
+// public key in a PEM block.
+func MarshalPublicKeyPEM(t Template) ([]byte, error) {
+	pubkey, err := PublicKeyOf(t)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(pubkey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode public key")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemTypePublicKey, Bytes: der}), nil
+}
+
+// MarshalPrivateKeyPEM encodes an Ed25519 or RSA private key as a PKCS #8
+// private key in a PEM block.
+func MarshalPrivateKeyPEM(key crypto.Signer) ([]byte, error) {
+	if _, err := TemplateForPublicKey(key.Public()); err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode private key")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemTypePrivateKey, Bytes: der}), nil
+}
+
+// ParseAuthorizedKey returns the leaf template for the key of a line in the
+// OpenSSH authorized_keys format, such as "ssh-ed25519 AAAA... comment".
+func ParseAuthorizedKey(line []byte) (Template, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey(line)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid authorized key")
+	}
+	cryptoKey, ok := key.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, errors.Errorf("unsupported SSH key type %s", key.Type())
+	}
+	return TemplateForPublicKey(cryptoKey.CryptoPublicKey())
+}
+
+// MarshalAuthorizedKey encodes the public key of a leaf template as a line in
+// the OpenSSH authorized_keys format, ending with a newline.
+func MarshalAuthorizedKey(t Template) ([]byte, error) {
+	pubkey, err := PublicKeyOf(t)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ssh.NewPublicKey(pubkey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode public key")
+	}
+	return ssh.MarshalAuthorizedKey(key), nil
+}
+
+// jwk holds the members of a JSON Web Key (RFC 7517) for Ed25519 (RFC 8037),
+// P-256 and RSA (RFC 7518) public keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// ParseJWK returns the leaf template for the key in a JSON Web Key. Supported
+// are OKP keys on the Ed25519 curve, EC keys on the P-256 curve and RSA keys.
+// Private key members are ignored.
+func ParseJWK(data []byte) (Template, error) {
+	var key jwk
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, errors.Wrap(err, "invalid JWK")
+	}
+
+	switch key.Kty {
+	case "OKP":
+		if key.Crv != "Ed25519" {
+			return nil, errors.Errorf("unsupported OKP curve %q", key.Crv)
+		}
+		x, err := base64url.Decode(key.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid JWK member x")
+		}
+		return NewEd25519Sha256Template(x)
+
+	case "EC":
+		if key.Crv != "P-256" {
+			return nil, errors.Errorf("unsupported EC curve %q", key.Crv)
+		}
+		x, err := base64url.Decode(key.X)
+		if err != nil || len(x) != p256CoordinateSize {
+			return nil, errors.New("invalid JWK member x")
+		}
+		y, err := base64url.Decode(key.Y)
+		if err != nil || len(y) != p256CoordinateSize {
+			return nil, errors.New("invalid JWK member y")
+		}
+		pubkey := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pubkey.Curve.IsOnCurve(pubkey.X, pubkey.Y) {
+			return nil, errors.New("invalid P-256 public key")
+		}
+		return TemplateForPublicKey(pubkey)
+
+	case "RSA":
+		n, err := base64url.Decode(key.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid JWK member n")
+		}
+		e, err := base64url.Decode(key.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid JWK member e")
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() != ffRsaSha256PublicExponent {
+			return nil, errors.Errorf(
+				"public exponent must be %d, not %s",
+				ffRsaSha256PublicExponent, exponent)
+		}
+		return TemplateForPublicKey(&rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: ffRsaSha256PublicExponent,
+		})
+	}
+	return nil, errors.Errorf("unsupported JWK key type %q", key.Kty)
+}
+
+// p256CoordinateSize is the size of the coordinates of P-256 points in JSON
+// Web Keys.
+const p256CoordinateSize = 32
+
+// MarshalJWK encodes the public key of an ED25519-SHA-256, ECDSA-P256-SHA-256
+// or RSA-SHA-256 leaf template as a JSON Web Key. The keys of the other
+// templates have no JWK representation.
+func MarshalJWK(t Template) ([]byte, error) {
+	pubkey, err := PublicKeyOf(t)
+	if err != nil {
+		return nil, err
+	}
+
+	var key jwk
+	switch k := pubkey.(type) {
+	case ed25519.PublicKey:
+		key = jwk{Kty: "OKP", Crv: "Ed25519", X: base64url.Encode(k)}
+	case *rsa.PublicKey:
+		key = jwk{
+			Kty: "RSA",
+			N:   base64url.Encode(k.N.Bytes()),
+			E:   base64url.Encode(big.NewInt(int64(k.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		key = jwk{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64url.Encode(k.X.FillBytes(make([]byte, p256CoordinateSize))),
+			Y:   base64url.Encode(k.Y.FillBytes(make([]byte, p256CoordinateSize))),
+		}
+	default:
+		return nil, errors.Errorf(
+			"%s templates cannot be encoded as JWK", t.ConditionType())
+	}
+	return json.Marshal(key)
+}
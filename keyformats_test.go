@@ -0,0 +1,163 @@
+package cryptoconditions
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestKeyFormats_ed25519(t *testing.T) {
+	pubkey, privkey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	tmpl, err := NewEd25519Sha256Template(pubkey)
+	require.NoError(t, err)
+	cond := tmpl.Condition()
+
+	pemData, err := MarshalPublicKeyPEM(tmpl)
+	require.NoError(t, err)
+	parsed, err := ParsePEM(pemData)
+	require.NoError(t, err)
+	assert.True(t, cond.Equals(parsed.Condition()))
+
+	privData, err := MarshalPrivateKeyPEM(privkey)
+	require.NoError(t, err)
+	parsed, err = ParsePEM(privData)
+	require.NoError(t, err)
+	assert.True(t, cond.Equals(parsed.Condition()))
+	signer, err := ParsePrivateKeyPEM(privData)
+	require.NoError(t, err)
+	assert.Equal(t, pubkey, signer.Public())
+
+	line, err := MarshalAuthorizedKey(tmpl)
+	require.NoError(t, err)
+	assert.Contains(t, string(line), "ssh-ed25519 ")
+	parsed, err = ParseAuthorizedKey(line)
+	require.NoError(t, err)
+	assert.True(t, cond.Equals(parsed.Condition()))
+
+	jwkData, err := MarshalJWK(tmpl)
+	require.NoError(t, err)
+	parsed, err = ParseJWK(jwkData)
+	require.NoError(t, err)
+	assert.True(t, cond.Equals(parsed.Condition()))
+
+	// OpenSSH private keys.
+	block, err := ssh.MarshalPrivateKey(privkey, "")
+	require.NoError(t, err)
+	signer, err = ParsePrivateKeyPEM(pem.EncodeToMemory(block))
+	require.NoError(t, err)
+	assert.Equal(t, pubkey, signer.Public())
+
+	// Certificates.
+	certTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "notary"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, certTemplate, certTemplate, pubkey, privkey)
+	require.NoError(t, err)
+	parsed, err = ParsePEM(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	require.NoError(t, err)
+	assert.True(t, cond.Equals(parsed.Condition()))
+}
+
+func TestKeyFormats_rsa(t *testing.T) {
+	privkey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	tmpl, err := TemplateForPublicKey(&privkey.PublicKey)
+	require.NoError(t, err)
+	cond := tmpl.Condition()
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PUBLIC KEY",
+		Bytes: x509.MarshalPKCS1PublicKey(&privkey.PublicKey),
+	})
+	parsed, err := ParsePEM(pkcs1)
+	require.NoError(t, err)
+	assert.True(t, cond.Equals(parsed.Condition()))
+
+	pkcs1Private := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privkey),
+	})
+	signer, err := ParsePrivateKeyPEM(pkcs1Private)
+	require.NoError(t, err)
+	assert.Equal(t, &privkey.PublicKey, signer.Public())
+
+	line, err := MarshalAuthorizedKey(tmpl)
+	require.NoError(t, err)
+	parsed, err = ParseAuthorizedKey(line)
+	require.NoError(t, err)
+	assert.True(t, cond.Equals(parsed.Condition()))
+
+	jwkData, err := MarshalJWK(tmpl)
+	require.NoError(t, err)
+	assert.Contains(t, string(jwkData), `"e":"AQAB"`)
+	parsed, err = ParseJWK(jwkData)
+	require.NoError(t, err)
+	assert.True(t, cond.Equals(parsed.Condition()))
+
+	// Keys that violate the RSA-SHA-256 constraints are rejected.
+	wrongExponent := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PUBLIC KEY",
+		Bytes: x509.MarshalPKCS1PublicKey(&rsa.PublicKey{N: privkey.N, E: 3}),
+	})
+	_, err = ParsePEM(wrongExponent)
+	assert.EqualError(t, err, "public exponent must be 65537, not 3")
+
+	_, err = ParseJWK([]byte(`{"kty":"RSA","n":"AQAB","e":"AQAB"}`))
+	assert.EqualError(t, err, "modulus is too small (3 bytes)")
+	_, err = ParseJWK([]byte(`{"kty":"EC","crv":"P-256"}`))
+	assert.Error(t, err)
+}
+
+func TestKeyFormats_ecdsaP256(t *testing.T) {
+	privkey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl, err := TemplateForPublicKey(&privkey.PublicKey)
+	require.NoError(t, err)
+	cond := tmpl.Condition()
+
+	jwkData, err := MarshalJWK(tmpl)
+	require.NoError(t, err)
+	assert.Contains(t, string(jwkData), `"kty":"EC","crv":"P-256"`)
+	parsed, err := ParseJWK(jwkData)
+	require.NoError(t, err)
+	assert.True(t, cond.Equals(parsed.Condition()))
+
+	// Points that are not on the curve are rejected.
+	_, err = ParseJWK([]byte(`{"kty":"EC","crv":"P-256",` +
+		`"x":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",` +
+		`"y":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}`))
+	assert.EqualError(t, err, "invalid P-256 public key")
+	_, err = ParseJWK([]byte(`{"kty":"EC","crv":"P-384"}`))
+	assert.EqualError(t, err, `unsupported EC curve "P-384"`)
+}
+
+func TestMarshalJWK_unsupported(t *testing.T) {
+	secpKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	wotsKey, err := GenerateWotsKey(rand.Reader)
+	require.NoError(t, err)
+
+	for _, pubkey := range []interface{}{secpKey.PubKey(), wotsKey.PublicKey()} {
+		tmpl, err := TemplateForPublicKey(pubkey)
+		require.NoError(t, err)
+		_, err = MarshalJWK(tmpl)
+		assert.EqualError(t, err, tmpl.ConditionType().String()+" templates cannot be encoded as JWK")
+	}
+}
@@ -0,0 +1,140 @@
+package cryptoconditions
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Keyring provides the signers for the keys used in condition trees.
+// Keys are identified by the condition they produce, which makes it possible
+// to look up "the key for this condition" directly.
+type Keyring interface {
+	// Signer returns the signer for the key that produces the given
+	// condition, or nil if the key is not available.
+	Signer(cond *Condition) (crypto.Signer, error)
+}
+
+// PreimageStore provides the preimages of PREIMAGE-SHA-256 conditions.
+type PreimageStore interface {
+	// Preimage returns the preimage for the given condition. The second
+	// return value is false if the preimage is not available.
+	Preimage(cond *Condition) ([]byte, bool, error)
+}
+
+// TemplateForPublicKey returns the leaf template for conditions of the given
+// public key. Supported keys are ed25519.PublicKey, *rsa.PublicKey,
+// *secp256k1.PublicKey, *ecdsa.PublicKey on the P-256 curve and
+// WotsPublicKey.
+func TemplateForPublicKey(pubkey crypto.PublicKey) (Template, error) {
+	switch key := pubkey.(type) {
+	case ed25519.PublicKey:
+		return NewEd25519Sha256Template(key)
+	case *rsa.PublicKey:
+		if err := checkRsaPublicKey(key); err != nil {
+			return nil, err
+		}
+		return &RsaSha256Template{Modulus: key.N.Bytes()}, nil
+	case *secp256k1.PublicKey:
+		return &Secp256k1Sha256Template{PublicKey: key.SerializeCompressed()}, nil
+	case *ecdsa.PublicKey:
+		encoded, err := marshalEcdsaP256PublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return &EcdsaP256Sha256Template{PublicKey: encoded}, nil
+	case WotsPublicKey:
+		return NewWotsSha256Template(key)
+	}
+	return nil, errors.Errorf("unsupported public key type %T", pubkey)
+}
+
+// MemoryKeyring is a Keyring that holds signers in memory.
+// It is safe for concurrent use.
+type MemoryKeyring struct {
+	mu      sync.RWMutex
+	signers map[string]crypto.Signer
+}
+
+// NewMemoryKeyring creates a new keyring holding the given signers.
+func NewMemoryKeyring(signers ...crypto.Signer) (*MemoryKeyring, error) {
+	k := &MemoryKeyring{
+		signers: make(map[string]crypto.Signer),
+	}
+	for _, signer := range signers {
+		if _, err := k.Add(signer); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Add adds the signer to the keyring and returns the condition of its key.
+func (k *MemoryKeyring) Add(signer crypto.Signer) (*Condition, error) {
+	t, err := TemplateForPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	cond := t.Condition()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.signers[keyringIndex(cond)] = signer
+	return cond, nil
+}
+
+// Signer returns the signer for the key that produces the given condition,
+// or nil if the key is not in the keyring.
+func (k *MemoryKeyring) Signer(cond *Condition) (crypto.Signer, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.signers[keyringIndex(cond)], nil
+}
+
+// MemoryPreimageStore is a PreimageStore that holds preimages in memory.
+// It is safe for concurrent use.
+type MemoryPreimageStore struct {
+	mu        sync.RWMutex
+	preimages map[string][]byte
+}
+
+// NewMemoryPreimageStore creates a new store holding the given preimages.
+func NewMemoryPreimageStore(preimages ...[]byte) *MemoryPreimageStore {
+	s := &MemoryPreimageStore{
+		preimages: make(map[string][]byte),
+	}
+	for _, preimage := range preimages {
+		s.Add(preimage)
+	}
+	return s
+}
+
+// Add adds the preimage to the store and returns its condition.
+func (s *MemoryPreimageStore) Add(preimage []byte) *Condition {
+	cond := NewPreimageSha256(preimage).Condition()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.preimages[keyringIndex(cond)] = preimage
+	return cond
+}
+
+// Preimage returns the preimage for the given condition.
+func (s *MemoryPreimageStore) Preimage(cond *Condition) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	preimage, found := s.preimages[keyringIndex(cond)]
+	return preimage, found, nil
+}
+
+// keyringIndex returns the map key under which the keys and preimages of a
+// condition are stored.
+func keyringIndex(cond *Condition) string {
+	return fmt.Sprintf("%d:%x", cond.Type(), cond.Fingerprint())
+}
@@ -0,0 +1,598 @@
+// Package keystore stores the private keys of crypto-conditions on disk,
+// encrypted under a passphrase.
+//
+// Every key is stored in its own file in the keystore directory and is
+// identified by the condition it produces, so that signing code can look up
+// the key for a condition directly. The private key is encoded in PKCS #8,
+// encrypted with ChaCha20-Poly1305 under a key derived from the passphrase
+// with scrypt. The condition, the label and the retirement of the key are
+// authenticated along with the key, so that an encrypted key cannot be passed
+// off as the key of another condition and its metadata cannot be altered
+// without the passphrase.
+package keystore
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-interledger/cryptoconditions"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	// ErrNotFound is returned when the keystore holds no key for a condition.
+	ErrNotFound = errors.New("key not found")
+	// ErrWrongPassphrase is returned when a key cannot be decrypted with the
+	// given passphrase.
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+)
+
+const (
+	// entryVersion is the version of the format of the key files.
+	entryVersion = 1
+	// entryExtension is the extension of the key files.
+	entryExtension = ".json"
+
+	kdfScrypt          = "scrypt"
+	cipherChacha20     = "chacha20-poly1305"
+	scryptSaltSize     = 32
+	scryptKeySize      = chacha20poly1305.KeySize
+	entryFilePerm      = 0600
+	keystoreDirPerm    = 0700
+	temporaryExtension = ".tmp"
+)
+
+// ScryptParams are the scrypt parameters used to derive the encryption keys
+// from passphrases.
+type ScryptParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+// DefaultScryptParams are the scrypt parameters used for new keys by default.
+var DefaultScryptParams = ScryptParams{N: 1 << 15, R: 8, P: 1}
+
+// DefaultMaxScryptParams are the largest scrypt parameters that keys are
+// decrypted with by default. With them, deriving a key takes 1 GiB of memory.
+var DefaultMaxScryptParams = ScryptParams{N: 1 << 20, R: 8, P: 4}
+
+// exceeds returns whether any of the parameters is larger than in max.
+func (p ScryptParams) exceeds(max ScryptParams) bool {
+	return p.N > max.N || p.R > max.R || p.P > max.P
+}
+
+// KeyInfo holds the public information about a key in the keystore.
+type KeyInfo struct {
+	// Condition is the condition the key produces.
+	Condition *cryptoconditions.Condition
+	// Label is a free-form description of the key.
+	Label string
+	// Created is the time the key was added to the keystore.
+	Created time.Time
+	// Retired is the time the key was rotated out, or zero if the key is
+	// still in use.
+	Retired time.Time
+	// ReplacedBy is the condition of the key that replaced this key when it
+	// was rotated out, if any.
+	ReplacedBy *cryptoconditions.Condition
+}
+
+// IsRetired returns whether the key has been rotated out.
+func (i *KeyInfo) IsRetired() bool {
+	return !i.Retired.IsZero()
+}
+
+// Store is a directory of encrypted keys.
+// It is safe for concurrent use within one process.
+type Store struct {
+	// Params are the scrypt parameters used to encrypt keys that are added
+	// to the store. Keys are always decrypted with the parameters they were
+	// encrypted with.
+	Params ScryptParams
+	// MaxParams are the largest scrypt parameters that keys are decrypted
+	// with. Key files with larger parameters, which could make deriving the
+	// key exhaust the memory or the time of the process, are rejected.
+	MaxParams ScryptParams
+
+	dir string
+	mu  sync.Mutex
+}
+
+// Open opens the keystore in the given directory, creating the directory if
+// it does not exist.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, keystoreDirPerm); err != nil {
+		return nil, errors.Wrap(err, "failed to create keystore directory")
+	}
+	return &Store{
+		Params:    DefaultScryptParams,
+		MaxParams: DefaultMaxScryptParams,
+		dir:       dir,
+	}, nil
+}
+
+// entry is the content of a key file.
+type entry struct {
+	Version    int          `json:"version"`
+	Condition  string       `json:"condition"`
+	Label      string       `json:"label,omitempty"`
+	Created    time.Time    `json:"created"`
+	Retired    *time.Time   `json:"retired,omitempty"`
+	ReplacedBy string       `json:"replacedBy,omitempty"`
+	KDF        string       `json:"kdf"`
+	KDFParams  ScryptParams `json:"kdfParams"`
+	Salt       string       `json:"salt"`
+	Cipher     string       `json:"cipher"`
+	Nonce      string       `json:"nonce"`
+	Ciphertext string       `json:"ciphertext"`
+}
+
+// entryMetadata is the metadata of an entry that is authenticated along with
+// the key.
+type entryMetadata struct {
+	Version    int          `json:"version"`
+	Condition  string       `json:"condition"`
+	Label      string       `json:"label"`
+	Created    time.Time    `json:"created"`
+	Retired    *time.Time   `json:"retired"`
+	ReplacedBy string       `json:"replacedBy"`
+	KDF        string       `json:"kdf"`
+	KDFParams  ScryptParams `json:"kdfParams"`
+	Cipher     string       `json:"cipher"`
+}
+
+// additionalData returns the additional data that the key of the entry is
+// encrypted with.
+func (e *entry) additionalData() ([]byte, error) {
+	ad, err := json.Marshal(entryMetadata{
+		Version:    e.Version,
+		Condition:  e.Condition,
+		Label:      e.Label,
+		Created:    e.Created,
+		Retired:    e.Retired,
+		ReplacedBy: e.ReplacedBy,
+		KDF:        e.KDF,
+		KDFParams:  e.KDFParams,
+		Cipher:     e.Cipher,
+	})
+	return ad, errors.Wrap(err, "failed to encode key metadata")
+}
+
+// info returns the public information of the entry.
+func (e *entry) info() (*KeyInfo, error) {
+	cond, err := cryptoconditions.ParseURI(e.Condition)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid condition")
+	}
+	info := &KeyInfo{
+		Condition: cond,
+		Label:     e.Label,
+		Created:   e.Created,
+	}
+	if e.Retired != nil {
+		info.Retired = *e.Retired
+	}
+	if e.ReplacedBy != "" {
+		if info.ReplacedBy, err = cryptoconditions.ParseURI(e.ReplacedBy); err != nil {
+			return nil, errors.Wrap(err, "invalid replacement condition")
+		}
+	}
+	return info, nil
+}
+
+// check checks that the entry is in a format supported by this package.
+func (e *entry) check() error {
+	switch {
+	case e.Version != entryVersion:
+		return errors.Errorf("unsupported key file version %d", e.Version)
+	case e.KDF != kdfScrypt:
+		return errors.Errorf("unsupported key derivation function %q", e.KDF)
+	case e.Cipher != cipherChacha20:
+		return errors.Errorf("unsupported cipher %q", e.Cipher)
+	}
+	_, err := e.info()
+	return err
+}
+
+// Add encrypts the key under the passphrase and adds it to the keystore.
+// The key must be an Ed25519 or RSA private key that can be used for
+// conditions, such as an ed25519.PrivateKey or an *rsa.PrivateKey.
+func (s *Store) Add(key crypto.Signer, label string, passphrase []byte) (*KeyInfo, error) {
+	e, err := s.encrypt(key, label, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.write(e, false); err != nil {
+		return nil, err
+	}
+	return e.info()
+}
+
+// encrypt creates the entry for the key.
+func (s *Store) encrypt(key crypto.Signer, label string, passphrase []byte) (*entry, error) {
+	t, err := cryptoconditions.TemplateForPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	cond := t.Condition()
+	plaintext, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode private key")
+	}
+
+	salt := make([]byte, scryptSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt, s.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &entry{
+		Version:   entryVersion,
+		Condition: cond.URI(),
+		Label:     label,
+		Created:   time.Now().UTC(),
+		KDF:       kdfScrypt,
+		KDFParams: s.Params,
+		Salt:      base64.StdEncoding.EncodeToString(salt),
+		Cipher:    cipherChacha20,
+	}
+	if err := e.seal(aead, plaintext); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// seal encrypts the private key of the entry under a new nonce, with the
+// current metadata of the entry as additional data.
+func (e *entry) seal(aead cipher.AEAD, plaintext []byte) error {
+	ad, err := e.additionalData()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	ciphertext := aead.Seal(nil, nonce, plaintext, ad)
+	e.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	e.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
+	return nil
+}
+
+// open decrypts the encoded private key of the entry. The key is not derived
+// if the scrypt parameters of the entry exceed maxParams.
+func (e *entry) open(passphrase []byte, maxParams ScryptParams) ([]byte, cipher.AEAD, error) {
+	if err := e.check(); err != nil {
+		return nil, nil, err
+	}
+	if e.KDFParams.exceeds(maxParams) {
+		return nil, nil, errors.Errorf("scrypt parameters %+v exceed the maximum %+v",
+			e.KDFParams, maxParams)
+	}
+	salt, err := base64.StdEncoding.DecodeString(e.Salt)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid salt")
+	}
+	nonce, err := base64.StdEncoding.DecodeString(e.Nonce)
+	if err != nil || len(nonce) != chacha20poly1305.NonceSize {
+		return nil, nil, errors.New("invalid nonce")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(e.Ciphertext)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid ciphertext")
+	}
+	ad, err := e.additionalData()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := newAEAD(passphrase, salt, e.KDFParams)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return nil, nil, ErrWrongPassphrase
+	}
+	return plaintext, aead, nil
+}
+
+// decrypt decrypts the private key of the entry.
+func (e *entry) decrypt(passphrase []byte, maxParams ScryptParams) (crypto.Signer, error) {
+	plaintext, _, err := e.open(passphrase, maxParams)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(plaintext)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid private key")
+	}
+	key, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("unsupported private key type %T", parsed)
+	}
+	return key, nil
+}
+
+// newAEAD derives the encryption key from the passphrase.
+func newAEAD(passphrase, salt []byte, params ScryptParams) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, params.N, params.R, params.P, scryptKeySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive key")
+	}
+	return chacha20poly1305.New(key)
+}
+
+// List returns the information about all keys in the keystore, ordered by
+// creation time.
+func (s *Store) List() ([]*KeyInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read keystore directory")
+	}
+	var infos []*KeyInfo
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), entryExtension) {
+			continue
+		}
+		e, err := s.readFile(filepath.Join(s.dir, file.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key file %s", file.Name())
+		}
+		info, err := e.info()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key file %s", file.Name())
+		}
+		infos = append(infos, info)
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Created.Before(infos[j].Created)
+	})
+	return infos, nil
+}
+
+// Info returns the information about the key for the condition.
+func (s *Store) Info(cond *cryptoconditions.Condition) (*KeyInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.read(cond)
+	if err != nil {
+		return nil, err
+	}
+	return e.info()
+}
+
+// Signer decrypts the key for the condition with the passphrase.
+func (s *Store) Signer(cond *cryptoconditions.Condition, passphrase []byte) (crypto.Signer, error) {
+	s.mu.Lock()
+	e, err := s.read(cond)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return e.decrypt(passphrase, s.MaxParams)
+}
+
+// Export returns the encrypted key file for the condition, which can be
+// imported in another keystore with Import.
+func (s *Store) Export(cond *cryptoconditions.Condition) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.read(cond)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(e, "", "  ")
+}
+
+// Import adds an encrypted key file produced by Export to the keystore.
+// The passphrase is needed to check that the key produces the condition the
+// file claims. Files whose scrypt parameters exceed MaxParams are rejected
+// before the key is derived.
+func (s *Store) Import(data []byte, passphrase []byte) (*KeyInfo, error) {
+	e := new(entry)
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, errors.Wrap(err, "invalid key file")
+	}
+	key, err := e.decrypt(passphrase, s.MaxParams)
+	if err != nil {
+		return nil, err
+	}
+	t, err := cryptoconditions.TemplateForPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	info, err := e.info()
+	if err != nil {
+		return nil, err
+	}
+	if !t.Condition().Equals(info.Condition) {
+		return nil, errors.New("key does not match the condition of the file")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.write(e, false); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Rotate adds the new key to the keystore and marks the key for the old
+// condition as retired and replaced by the new key. Retired keys stay
+// available for signing.
+// The new key is encrypted under the passphrase, which must also decrypt the
+// old key, since its retirement is authenticated along with it.
+//
+// The old key is marked as retired before the new key is written, so that
+// an interrupted rotation never leaves both keys in use. If the new key
+// cannot be written, the retirement is undone.
+func (s *Store) Rotate(old *cryptoconditions.Condition, newKey crypto.Signer, label string, passphrase []byte) (*KeyInfo, error) {
+	newEntry, err := s.encrypt(newKey, label, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	newInfo, err := newEntry.info()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldEntry, err := s.read(old)
+	if err != nil {
+		return nil, err
+	}
+	if oldEntry.Retired != nil {
+		return nil, errors.Errorf("key %s is already retired", old.URI())
+	}
+	if _, err := os.Stat(s.path(newInfo.Condition)); err == nil {
+		return nil, errors.Errorf("keystore already holds key %s", newEntry.Condition)
+	}
+	plaintext, aead, err := oldEntry.open(passphrase, s.MaxParams)
+	if err != nil {
+		return nil, err
+	}
+
+	retiredEntry := *oldEntry
+	retired := newEntry.Created
+	retiredEntry.Retired = &retired
+	retiredEntry.ReplacedBy = newEntry.Condition
+	if err := retiredEntry.seal(aead, plaintext); err != nil {
+		return nil, err
+	}
+	if err := s.write(&retiredEntry, true); err != nil {
+		return nil, err
+	}
+	if err := s.write(newEntry, false); err != nil {
+		if restoreErr := s.write(oldEntry, true); restoreErr != nil {
+			return nil, errors.Wrapf(err, "failed to restore key %s (%v)", old.URI(), restoreErr)
+		}
+		return nil, err
+	}
+	return newInfo, nil
+}
+
+// Delete removes the key for the condition from the keystore.
+func (s *Store) Delete(cond *cryptoconditions.Condition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path(cond))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Keyring returns a cryptoconditions.Keyring that decrypts the keys of the
+// keystore with the given passphrase when they are needed.
+func (s *Store) Keyring(passphrase []byte) cryptoconditions.Keyring {
+	return &keyring{store: s, passphrase: passphrase}
+}
+
+type keyring struct {
+	store      *Store
+	passphrase []byte
+}
+
+func (k *keyring) Signer(cond *cryptoconditions.Condition) (crypto.Signer, error) {
+	signer, err := k.store.Signer(cond, k.passphrase)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	return signer, err
+}
+
+// path returns the path of the key file for the condition.
+func (s *Store) path(cond *cryptoconditions.Condition) string {
+	name := fmt.Sprintf("%s-%x%s",
+		strings.ToLower(cond.Type().String()), cond.Fingerprint(), entryExtension)
+	return filepath.Join(s.dir, name)
+}
+
+// read reads the key file for the condition.
+func (s *Store) read(cond *cryptoconditions.Condition) (*entry, error) {
+	e, err := s.readFile(s.path(cond))
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	info, err := e.info()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Condition.Equals(cond) {
+		return nil, errors.New("key file does not match its condition")
+	}
+	return e, nil
+}
+
+func (s *Store) readFile(path string) (*entry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e := new(entry)
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, errors.Wrap(err, "invalid key file")
+	}
+	if err := e.check(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// write writes the key file of the entry. Unless overwrite is set, existing
+// key files are not replaced.
+func (s *Store) write(e *entry, overwrite bool) error {
+	info, err := e.info()
+	if err != nil {
+		return err
+	}
+	path := s.path(info.Condition)
+	if !overwrite {
+		if _, err := os.Stat(path); err == nil {
+			return errors.Errorf("keystore already holds key %s", e.Condition)
+		}
+	}
+
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	temporary := path + temporaryExtension
+	if err := ioutil.WriteFile(temporary, data, entryFilePerm); err != nil {
+		return errors.Wrap(err, "failed to write key file")
+	}
+	if err := os.Rename(temporary, path); err != nil {
+		os.Remove(temporary)
+		return errors.Wrap(err, "failed to write key file")
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/go-interledger/cryptoconditions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// testScryptParams are weak scrypt parameters that keep the tests fast.
+var testScryptParams = ScryptParams{N: 1 << 10, R: 8, P: 1}
+
+func openTestStore(t *testing.T) *Store {
+	store, err := Open(t.TempDir())
+	require.NoError(t, err)
+	store.Params = testScryptParams
+	return store
+}
+
+func TestStore(t *testing.T) {
+	store := openTestStore(t)
+	passphrase := []byte("correct horse")
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	edInfo, err := store.Add(edKey, "notary", passphrase)
+	require.NoError(t, err)
+	assert.Equal(t, cryptoconditions.CTEd25519Sha256, edInfo.Condition.Type())
+	assert.Equal(t, "notary", edInfo.Label)
+	assert.False(t, edInfo.IsRetired())
+	rsaInfo, err := store.Add(rsaKey, "escrow", passphrase)
+	require.NoError(t, err)
+
+	_, err = store.Add(edKey, "again", passphrase)
+	assert.Error(t, err)
+
+	infos, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.True(t, infos[0].Condition.Equals(edInfo.Condition))
+	assert.True(t, infos[1].Condition.Equals(rsaInfo.Condition))
+
+	// Keys are found by their condition.
+	signer, err := store.Signer(rsaInfo.Condition, passphrase)
+	require.NoError(t, err)
+	assert.Equal(t, rsaKey.Public(), signer.Public())
+	_, err = store.Signer(edInfo.Condition, []byte("wrong"))
+	assert.Equal(t, ErrWrongPassphrase, err)
+	unknown := cryptoconditions.NewPreimageSha256([]byte("x")).Condition()
+	_, err = store.Signer(unknown, passphrase)
+	assert.Equal(t, ErrNotFound, err)
+
+	// The keystore can be used to sign templates.
+	tmpl, err := cryptoconditions.AllOf(
+		cryptoconditions.Ed25519Key(edKey.Public().(ed25519.PublicKey)),
+		cryptoconditions.RSAKey(&rsaKey.PublicKey),
+	).Template()
+	require.NoError(t, err)
+	message := []byte("hello")
+	ff, missing, err := cryptoconditions.Sign(tmpl, message, store.Keyring(passphrase), nil)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.NoError(t, ff.Validate(tmpl.Condition(), message))
+
+	require.NoError(t, store.Delete(rsaInfo.Condition))
+	assert.Equal(t, ErrNotFound, store.Delete(rsaInfo.Condition))
+	_, err = store.Info(rsaInfo.Condition)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestStore_exportImport(t *testing.T) {
+	store := openTestStore(t)
+	other := openTestStore(t)
+	passphrase := []byte("correct horse")
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	info, err := store.Add(key, "notary", passphrase)
+	require.NoError(t, err)
+
+	exported, err := store.Export(info.Condition)
+	require.NoError(t, err)
+	_, err = other.Import(exported, []byte("wrong"))
+	assert.Equal(t, ErrWrongPassphrase, err)
+	imported, err := other.Import(exported, passphrase)
+	require.NoError(t, err)
+	assert.True(t, imported.Condition.Equals(info.Condition))
+	assert.Equal(t, "notary", imported.Label)
+
+	signer, err := other.Signer(info.Condition, passphrase)
+	require.NoError(t, err)
+	assert.Equal(t, key.Public(), signer.Public())
+}
+
+func TestStore_importUntrusted(t *testing.T) {
+	store := openTestStore(t)
+	other := openTestStore(t)
+	passphrase := []byte("correct horse")
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	info, err := store.Add(key, "notary", passphrase)
+	require.NoError(t, err)
+	exported, err := store.Export(info.Condition)
+	require.NoError(t, err)
+
+	modify := func(f func(e *entry)) []byte {
+		e := new(entry)
+		require.NoError(t, json.Unmarshal(exported, e))
+		f(e)
+		data, err := json.Marshal(e)
+		require.NoError(t, err)
+		return data
+	}
+
+	// The key is not derived with parameters above the maximum.
+	other.MaxParams = ScryptParams{N: 1 << 9, R: 8, P: 1}
+	_, err = other.Import(exported, passphrase)
+	assert.EqualError(t, err,
+		"scrypt parameters {N:1024 R:8 P:1} exceed the maximum {N:512 R:8 P:1}")
+	other.MaxParams = DefaultMaxScryptParams
+	_, err = other.Import(modify(func(e *entry) { e.KDFParams.N = 1 << 30 }), passphrase)
+	assert.Error(t, err)
+
+	// The metadata cannot be altered.
+	_, err = other.Import(modify(func(e *entry) { e.Label = "escrow" }), passphrase)
+	assert.Equal(t, ErrWrongPassphrase, err)
+	_, err = other.Import(modify(func(e *entry) { e.Created = e.Created.Add(1) }), passphrase)
+	assert.Equal(t, ErrWrongPassphrase, err)
+	_, err = other.Import(modify(func(e *entry) {
+		retired := e.Created
+		e.Retired = &retired
+	}), passphrase)
+	assert.Equal(t, ErrWrongPassphrase, err)
+	infos, err := other.List()
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+}
+
+func TestStore_rotate(t *testing.T) {
+	store := openTestStore(t)
+	passphrase := []byte("correct horse")
+
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	oldInfo, err := store.Add(oldKey, "notary", passphrase)
+	require.NoError(t, err)
+
+	newInfo, err := store.Rotate(oldInfo.Condition, newKey, "notary 2", passphrase)
+	require.NoError(t, err)
+	assert.False(t, newInfo.IsRetired())
+
+	oldInfo, err = store.Info(oldInfo.Condition)
+	require.NoError(t, err)
+	assert.True(t, oldInfo.IsRetired())
+	assert.True(t, oldInfo.ReplacedBy.Equals(newInfo.Condition))
+
+	// Retired keys can still sign, but not be rotated again.
+	_, err = store.Signer(oldInfo.Condition, passphrase)
+	assert.NoError(t, err)
+	_, err = store.Rotate(oldInfo.Condition, newKey, "", passphrase)
+	assert.Error(t, err)
+
+	// The retirement is authenticated along with the old key.
+	exported, err := store.Export(oldInfo.Condition)
+	require.NoError(t, err)
+	other := openTestStore(t)
+	imported, err := other.Import(exported, passphrase)
+	require.NoError(t, err)
+	assert.True(t, imported.IsRetired())
+	assert.True(t, imported.ReplacedBy.Equals(newInfo.Condition))
+}
+
+func TestStore_rotateFailure(t *testing.T) {
+	store := openTestStore(t)
+	passphrase := []byte("correct horse")
+
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	oldInfo, err := store.Add(oldKey, "notary", passphrase)
+	require.NoError(t, err)
+
+	// The passphrase must decrypt the old key.
+	_, err = store.Rotate(oldInfo.Condition, newKey, "notary 2", []byte("wrong"))
+	assert.Equal(t, ErrWrongPassphrase, err)
+
+	// A new key that is already in the keystore is refused before the old
+	// key is retired.
+	newInfo, err := store.Add(newKey, "notary 2", passphrase)
+	require.NoError(t, err)
+	_, err = store.Rotate(oldInfo.Condition, newKey, "notary 2", passphrase)
+	assert.Error(t, err)
+	info, err := store.Info(oldInfo.Condition)
+	require.NoError(t, err)
+	assert.False(t, info.IsRetired())
+
+	// If the new key cannot be written, the retirement is undone.
+	require.NoError(t, store.Delete(newInfo.Condition))
+	require.NoError(t, os.Mkdir(store.path(newInfo.Condition)+temporaryExtension, 0700))
+	_, err = store.Rotate(oldInfo.Condition, newKey, "notary 2", passphrase)
+	assert.Error(t, err)
+	info, err = store.Info(oldInfo.Condition)
+	require.NoError(t, err)
+	assert.False(t, info.IsRetired())
+	_, err = store.Signer(oldInfo.Condition, passphrase)
+	assert.NoError(t, err)
+}
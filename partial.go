@@ -0,0 +1,627 @@
+package cryptoconditions
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/kalaspuffar/base64url"
+	"github.com/pkg/errors"
+)
+
+// PartialFulfillment is a template of which some of the leaves are already
+// fulfilled. It is used when the leaves of a condition are fulfilled by
+// different parties: every party fulfills the leaves it holds the keys or
+// preimages for, the results are merged with Combine and the final
+// fulfillment is produced once enough leaves are fulfilled.
+//
+// Partial fulfillments can be exchanged in a JSON format that follows the
+// format of the RFC test vectors. Leaves that carry a signature or preimage
+// are fulfilled, the others only describe their condition. Leaves of which
+// only the condition is known, see ConditionTemplate, carry its URI.
+type PartialFulfillment struct {
+	template Template
+	// leaves holds the leaf fulfillments indexed by the string form of their
+	// path in the template.
+	leaves map[string]Fulfillment
+}
+
+// NewPartialFulfillment creates a partial fulfillment for the template
+// without any fulfilled leaves.
+func NewPartialFulfillment(t Template) *PartialFulfillment {
+	return &PartialFulfillment{
+		template: t,
+		leaves:   make(map[string]Fulfillment),
+	}
+}
+
+// Template returns the template of the partial fulfillment.
+func (p *PartialFulfillment) Template() Template {
+	return p.template
+}
+
+// Condition returns the condition the partial fulfillment is for.
+func (p *PartialFulfillment) Condition() *Condition {
+	return p.template.Condition()
+}
+
+// Leaf returns the fulfillment of the leaf at the given path, or nil if the
+// leaf is not fulfilled.
+func (p *PartialFulfillment) Leaf(path NodePath) Fulfillment {
+	return p.leaves[path.String()]
+}
+
+// SetLeaf sets the fulfillment of the leaf at the given path.
+// The fulfillment must fulfill the condition of the leaf. Signatures can not
+// be checked without the message, use ValidateLeaves for that.
+func (p *PartialFulfillment) SetLeaf(path NodePath, ff Fulfillment) error {
+	leaf, err := p.leafAt(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := derefTemplate(leaf).(ConditionTemplate); ok {
+		return errors.Errorf(
+			"leaf %s only describes its condition and can not be fulfilled", path)
+	}
+	if !ff.Condition().Equals(leaf.Condition()) {
+		return errors.Errorf(
+			"fulfillment for leaf %s does not match the template", path)
+	}
+	p.leaves[path.String()] = ff
+	return nil
+}
+
+// leafAt returns the leaf template at the given path.
+func (p *PartialFulfillment) leafAt(path NodePath) (Template, error) {
+	node, err := templateAt(p.template, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(node.subTemplates()) != 0 {
+		return nil, errors.Errorf("node at %s is not a leaf", path)
+	}
+	return node, nil
+}
+
+// FulfilledLeaves returns the paths of the fulfilled leaves.
+func (p *PartialFulfillment) FulfilledLeaves() []NodePath {
+	var paths []NodePath
+	p.walkLeaves(func(path NodePath, _ Template) {
+		if p.leaves[path.String()] != nil {
+			paths = append(paths, path)
+		}
+	})
+	return paths
+}
+
+// UnfulfilledLeaves returns the paths of the leaves that are not fulfilled.
+func (p *PartialFulfillment) UnfulfilledLeaves() []NodePath {
+	var paths []NodePath
+	p.walkLeaves(func(path NodePath, _ Template) {
+		if p.leaves[path.String()] == nil {
+			paths = append(paths, path)
+		}
+	})
+	return paths
+}
+
+func (p *PartialFulfillment) walkLeaves(fn func(NodePath, Template)) {
+	WalkTemplate(p.template, func(path NodePath, node Template) error {
+		if len(node.subTemplates()) == 0 {
+			fn(path, node)
+		}
+		return nil
+	})
+}
+
+// Sign fulfills the leaves that are not yet fulfilled and of which the key is
+// in the keyring or the preimage is in the preimage store, like Sign does.
+// It returns the leaves that are still not fulfilled afterwards.
+func (p *PartialFulfillment) Sign(message []byte, keyring Keyring, preimages PreimageStore) ([]MissingLeaf, error) {
+	var missing []MissingLeaf
+	err := walkLeafMessages(p.template, message, func(path NodePath, leaf Template, msg []byte, unreachable string) error {
+		if p.leaves[path.String()] != nil {
+			return nil
+		}
+		if unreachable != "" {
+			missing = append(missing, MissingLeaf{path, leaf, unreachable})
+			return nil
+		}
+		ff, reason, err := signLeaf(leaf, msg, keyring, preimages)
+		if err != nil {
+			return errors.Wrapf(err, "failed to fulfill leaf %s", path)
+		}
+		if ff == nil {
+			missing = append(missing, MissingLeaf{path, leaf, reason})
+			return nil
+		}
+		p.leaves[path.String()] = ff
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// ValidateLeaves validates the fulfilled leaves for the given message, taking
+// into account the prefixes above them. It returns the paths of the leaves
+// that are invalid.
+func (p *PartialFulfillment) ValidateLeaves(message []byte) []NodePath {
+	var invalid []NodePath
+	walkLeafMessages(p.template, message, func(path NodePath, leaf Template, msg []byte, unreachable string) error {
+		ff := p.leaves[path.String()]
+		if ff == nil {
+			return nil
+		}
+		if unreachable != "" || ff.Validate(leaf.Condition(), msg) != nil {
+			invalid = append(invalid, path)
+		}
+		return nil
+	})
+	return invalid
+}
+
+// Fulfillment builds the fulfillment from the fulfilled leaves.
+// If not enough leaves are fulfilled, ErrUnsatisfied is returned.
+func (p *PartialFulfillment) Fulfillment() (Fulfillment, error) {
+	return FulfillTemplate(p.template, func(path NodePath, _ Template) (Fulfillment, error) {
+		return p.leaves[path.String()], nil
+	})
+}
+
+// Combine merges the fulfilled leaves of both partial fulfillments into a new
+// partial fulfillment. Both must be for the same condition and have their
+// templates ordered in the same way, which is not implied by equal conditions
+// since the children of thresholds are sorted. If both fulfill the same leaf,
+// their fulfillments must be equal, otherwise an error is returned, since it
+// can not be told which of them is valid.
+func Combine(a, b *PartialFulfillment) (*PartialFulfillment, error) {
+	if !a.Condition().Equals(b.Condition()) {
+		return nil, errors.New(
+			"partial fulfillments are for different conditions")
+	}
+	errMismatch := errors.New("templates differ")
+	var mismatch NodePath
+	WalkTemplate(a.template, func(path NodePath, node Template) error {
+		other, err := templateAt(b.template, path)
+		if err != nil || !node.Condition().Equals(other.Condition()) {
+			mismatch = path
+			return errMismatch
+		}
+		return nil
+	})
+	if mismatch != nil {
+		return nil, errors.Errorf(
+			"partial fulfillments are ordered differently at %s", mismatch)
+	}
+
+	combined := NewPartialFulfillment(a.template)
+	for path, ff := range a.leaves {
+		combined.leaves[path] = ff
+	}
+	for path, ff := range b.leaves {
+		if existing, ok := combined.leaves[path]; ok && !FulfillmentsEqual(existing, ff) {
+			return nil, errors.Errorf(
+				"partial fulfillments fulfill the leaf at %s differently", path)
+		}
+		combined.leaves[path] = ff
+	}
+	return combined, nil
+}
+
+// templateAt returns the node of the template at the given path.
+func templateAt(t Template, path NodePath) (Template, error) {
+	node := t
+	for _, index := range path {
+		subs := node.subTemplates()
+		if index < 0 || index >= len(subs) {
+			return nil, errors.Errorf("no node at %s", path)
+		}
+		node = subs[index]
+	}
+	return node, nil
+}
+
+// partialJSON is the JSON representation of a partial fulfillment.
+type partialJSON struct {
+	// Condition holds the URI of the condition, as a check on the tree.
+	Condition string           `json:"condition"`
+	Tree      *partialJSONNode `json:"tree"`
+}
+
+// partialJSONNode is the JSON representation of a node of a partial
+// fulfillment. Binary values are encoded in base64url like in the RFC test
+// vectors.
+type partialJSONNode struct {
+	Type string `json:"type"`
+
+	// The URI of the condition of leaves of which only the condition is
+	// known, of any type
+	Condition string `json:"condition,omitempty"`
+
+	// PREIMAGE-SHA-256
+	Hash     string  `json:"hash,omitempty"`
+	Size     *int    `json:"size,omitempty"`
+	Preimage *string `json:"preimage,omitempty"`
+
+	// PREFIX-SHA-256
+	Prefix           *string          `json:"prefix,omitempty"`
+	MaxMessageLength *uint32          `json:"maxMessageLength,omitempty"`
+	SubFulfillment   *partialJSONNode `json:"subfulfillment,omitempty"`
+
+	// THRESHOLD-SHA-256
+	Threshold       *uint16            `json:"threshold,omitempty"`
+	SubFulfillments []*partialJSONNode `json:"subfulfillments,omitempty"`
+
+	// RSA-SHA-256
+	Modulus string `json:"modulus,omitempty"`
+
+	// ED25519-SHA-256, SECP256K1-SHA-256, ECDSA-P256-SHA-256 and
+	// WEBAUTHN-SHA-256
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// WEBAUTHN-SHA-256
+	RpIdHash          string  `json:"rpIdHash,omitempty"`
+	AuthenticatorData *string `json:"authenticatorData,omitempty"`
+	ClientDataJSON    *string `json:"clientDataJSON,omitempty"`
+
+	// WOTS-SHA-256
+	PublicSeed    string `json:"publicSeed,omitempty"`
+	PublicKeyHash string `json:"publicKeyHash,omitempty"`
+
+	// The signature types
+	Signature *string `json:"signature,omitempty"`
+}
+
+// MarshalJSON encodes the partial fulfillment as JSON.
+func (p *PartialFulfillment) MarshalJSON() ([]byte, error) {
+	tree, err := p.marshalNode(p.template, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(partialJSON{
+		Condition: p.Condition().URI(),
+		Tree:      tree,
+	})
+}
+
+func (p *PartialFulfillment) marshalNode(t Template, path NodePath) (*partialJSONNode, error) {
+	node := &partialJSONNode{
+		Type: strings.ToLower(t.ConditionType().String()),
+	}
+	leaf := derefFulfillment(p.leaves[path.String()])
+
+	switch tmpl := derefTemplate(t).(type) {
+	case PreimageSha256Template:
+		node.Hash = base64url.Encode(tmpl.Hash)
+		node.Size = &tmpl.Size
+		if ff, ok := leaf.(FfPreimageSha256); ok {
+			node.Preimage = encodeOptional(ff.Preimage)
+		}
+
+	case PrefixSha256Template:
+		node.Prefix = encodeOptional(tmpl.Prefix)
+		node.MaxMessageLength = &tmpl.MaxMessageLength
+		sub, err := p.marshalNode(tmpl.SubTemplate, path.child(0))
+		if err != nil {
+			return nil, err
+		}
+		node.SubFulfillment = sub
+
+	case ThresholdSha256Template:
+		node.Threshold = &tmpl.Threshold
+		node.SubFulfillments = make([]*partialJSONNode, len(tmpl.SubTemplates))
+		for i, subTmpl := range tmpl.SubTemplates {
+			sub, err := p.marshalNode(subTmpl, path.child(i))
+			if err != nil {
+				return nil, err
+			}
+			node.SubFulfillments[i] = sub
+		}
+
+	case RsaSha256Template:
+		node.Modulus = base64url.Encode(tmpl.Modulus)
+		if ff, ok := leaf.(FfRsaSha256); ok {
+			node.Signature = encodeOptional(ff.Signature)
+		}
+
+	case Ed25519Sha256Template:
+		node.PublicKey = base64url.Encode(tmpl.PublicKey)
+		if ff, ok := leaf.(FfEd25519Sha256); ok {
+			node.Signature = encodeOptional(ff.Signature)
+		}
+
+	case Secp256k1Sha256Template:
+		node.PublicKey = base64url.Encode(tmpl.PublicKey)
+		if ff, ok := leaf.(FfSecp256k1Sha256); ok {
+			node.Signature = encodeOptional(ff.Signature)
+		}
+
+	case EcdsaP256Sha256Template:
+		node.PublicKey = base64url.Encode(tmpl.PublicKey)
+		if ff, ok := leaf.(FfEcdsaP256Sha256); ok {
+			node.Signature = encodeOptional(ff.Signature)
+		}
+
+	case WotsSha256Template:
+		node.PublicSeed = base64url.Encode(tmpl.PublicKey.Seed)
+		node.PublicKeyHash = base64url.Encode(tmpl.PublicKey.Hash)
+		if ff, ok := leaf.(FfWotsSha256); ok {
+			node.Signature = encodeOptional(ff.Signature)
+		}
+
+	case WebAuthnSha256Template:
+		node.PublicKey = base64url.Encode(tmpl.PublicKey)
+		node.RpIdHash = base64url.Encode(tmpl.RpIdHash)
+		if ff, ok := leaf.(FfWebAuthnSha256); ok {
+			node.AuthenticatorData = encodeOptional(ff.AuthenticatorData)
+			node.ClientDataJSON = encodeOptional(ff.ClientDataJSON)
+			node.Signature = encodeOptional(ff.Signature)
+		}
+
+	case ConditionTemplate:
+		node.Condition = tmpl.Cond.URI()
+
+	default:
+		return nil, errors.Errorf(
+			"cannot encode %s templates", t.ConditionType())
+	}
+	return node, nil
+}
+
+// UnmarshalJSON decodes a partial fulfillment from JSON.
+// The tree is checked against the condition in the encoding and every
+// fulfilled leaf is checked against its template.
+func (p *PartialFulfillment) UnmarshalJSON(data []byte) error {
+	var encoded partialJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	if encoded.Tree == nil {
+		return errors.New("missing tree")
+	}
+
+	decoded := NewPartialFulfillment(nil)
+	t, err := decoded.unmarshalNode(encoded.Tree, nil)
+	if err != nil {
+		return err
+	}
+	decoded.template = t
+
+	cond, err := ParseURI(encoded.Condition)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse condition")
+	}
+	if !cond.Equals(t.Condition()) {
+		return errors.New("tree does not match the condition")
+	}
+
+	*p = *decoded
+	return nil
+}
+
+func (p *PartialFulfillment) unmarshalNode(node *partialJSONNode, path NodePath) (Template, error) {
+	if node == nil {
+		return nil, errors.Errorf("missing node at %s", path)
+	}
+	conditionType, found := conditionTypeDictionary[strings.ToUpper(node.Type)]
+	if !found {
+		return nil, errors.Errorf("unknown condition type at %s: %s", path, node.Type)
+	}
+	if node.Condition != "" {
+		t, err := unmarshalConditionNode(node, conditionType)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid node at %s", path)
+		}
+		return t, nil
+	}
+
+	var t Template
+	var leaf Fulfillment
+	var err error
+	switch conditionType {
+	case CTPreimageSha256:
+		var hash []byte
+		if hash, err = base64url.Decode(node.Hash); err != nil {
+			break
+		}
+		if node.Size == nil {
+			err = errors.New("missing size")
+			break
+		}
+		var tmpl *PreimageSha256Template
+		if tmpl, err = NewPreimageSha256Template(hash, *node.Size); err != nil {
+			break
+		}
+		t = tmpl
+		if node.Preimage != nil {
+			var preimage []byte
+			if preimage, err = base64url.Decode(*node.Preimage); err != nil {
+				break
+			}
+			leaf, err = tmpl.Fulfill(preimage)
+		}
+
+	case CTPrefixSha256:
+		if node.Prefix == nil || node.MaxMessageLength == nil {
+			err = errors.New("missing prefix or maxMessageLength")
+			break
+		}
+		var prefix []byte
+		if prefix, err = base64url.Decode(*node.Prefix); err != nil {
+			break
+		}
+		var sub Template
+		if sub, err = p.unmarshalNode(node.SubFulfillment, path.child(0)); err != nil {
+			return nil, err
+		}
+		t, err = NewPrefixSha256Template(prefix, *node.MaxMessageLength, sub)
+
+	case CTThresholdSha256:
+		if node.Threshold == nil {
+			err = errors.New("missing threshold")
+			break
+		}
+		subs := make([]Template, len(node.SubFulfillments))
+		for i, subNode := range node.SubFulfillments {
+			if subs[i], err = p.unmarshalNode(subNode, path.child(i)); err != nil {
+				return nil, err
+			}
+		}
+		t, err = NewThresholdSha256Template(*node.Threshold, subs)
+
+	case CTRsaSha256:
+		var modulus []byte
+		if modulus, err = base64url.Decode(node.Modulus); err != nil {
+			break
+		}
+		var tmpl *RsaSha256Template
+		if tmpl, err = NewRsaSha256Template(modulus); err != nil {
+			break
+		}
+		t = tmpl
+		if node.Signature != nil {
+			var signature []byte
+			if signature, err = base64url.Decode(*node.Signature); err != nil {
+				break
+			}
+			leaf, err = tmpl.Fulfill(signature)
+		}
+
+	case CTEd25519Sha256:
+		var pubkey []byte
+		if pubkey, err = base64url.Decode(node.PublicKey); err != nil {
+			break
+		}
+		var tmpl *Ed25519Sha256Template
+		if tmpl, err = NewEd25519Sha256Template(pubkey); err != nil {
+			break
+		}
+		t = tmpl
+		if node.Signature != nil {
+			var signature []byte
+			if signature, err = base64url.Decode(*node.Signature); err != nil {
+				break
+			}
+			leaf, err = tmpl.Fulfill(signature)
+		}
+
+	case CTSecp256k1Sha256:
+		var pubkey []byte
+		if pubkey, err = base64url.Decode(node.PublicKey); err != nil {
+			break
+		}
+		var tmpl *Secp256k1Sha256Template
+		if tmpl, err = NewSecp256k1Sha256Template(pubkey); err != nil {
+			break
+		}
+		t = tmpl
+		if node.Signature != nil {
+			var signature []byte
+			if signature, err = base64url.Decode(*node.Signature); err != nil {
+				break
+			}
+			leaf, err = tmpl.Fulfill(signature)
+		}
+
+	case CTEcdsaP256Sha256:
+		var pubkey []byte
+		if pubkey, err = base64url.Decode(node.PublicKey); err != nil {
+			break
+		}
+		var tmpl *EcdsaP256Sha256Template
+		if tmpl, err = NewEcdsaP256Sha256Template(pubkey); err != nil {
+			break
+		}
+		t = tmpl
+		if node.Signature != nil {
+			var signature []byte
+			if signature, err = base64url.Decode(*node.Signature); err != nil {
+				break
+			}
+			leaf, err = tmpl.Fulfill(signature)
+		}
+
+	case CTWotsSha256:
+		var pubkey WotsPublicKey
+		if pubkey.Seed, err = base64url.Decode(node.PublicSeed); err != nil {
+			break
+		}
+		if pubkey.Hash, err = base64url.Decode(node.PublicKeyHash); err != nil {
+			break
+		}
+		var tmpl *WotsSha256Template
+		if tmpl, err = NewWotsSha256Template(pubkey); err != nil {
+			break
+		}
+		t = tmpl
+		if node.Signature != nil {
+			var signature []byte
+			if signature, err = base64url.Decode(*node.Signature); err != nil {
+				break
+			}
+			leaf, err = tmpl.Fulfill(signature)
+		}
+
+	case CTWebAuthnSha256:
+		var pubkey, rpIdHash []byte
+		if pubkey, err = base64url.Decode(node.PublicKey); err != nil {
+			break
+		}
+		if rpIdHash, err = base64url.Decode(node.RpIdHash); err != nil {
+			break
+		}
+		var tmpl *WebAuthnSha256Template
+		if tmpl, err = NewWebAuthnSha256Template(pubkey, rpIdHash); err != nil {
+			break
+		}
+		t = tmpl
+		if node.Signature != nil {
+			if node.AuthenticatorData == nil || node.ClientDataJSON == nil {
+				err = errors.New("missing authenticatorData or clientDataJSON")
+				break
+			}
+			var authenticatorData, clientDataJSON, signature []byte
+			if authenticatorData, err = base64url.Decode(*node.AuthenticatorData); err != nil {
+				break
+			}
+			if clientDataJSON, err = base64url.Decode(*node.ClientDataJSON); err != nil {
+				break
+			}
+			if signature, err = base64url.Decode(*node.Signature); err != nil {
+				break
+			}
+			leaf, err = tmpl.Fulfill(authenticatorData, clientDataJSON, signature)
+		}
+
+	default:
+		err = errors.Errorf("cannot decode %s templates", conditionType)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid node at %s", path)
+	}
+
+	if leaf != nil {
+		p.leaves[path.String()] = leaf
+	}
+	return t, nil
+}
+
+// unmarshalConditionNode decodes a node of which only the condition is known.
+func unmarshalConditionNode(node *partialJSONNode, conditionType ConditionType) (Template, error) {
+	cond, err := ParseURI(node.Condition)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse condition")
+	}
+	if cond.Type() != conditionType {
+		return nil, errors.Errorf("condition of type %s in a %s node",
+			cond.Type(), conditionType)
+	}
+	return NewConditionTemplate(cond)
+}
+
+// encodeOptional base64url encodes b and returns a pointer to the result.
+func encodeOptional(b []byte) *string {
+	encoded := base64url.Encode(b)
+	return &encoded
+}
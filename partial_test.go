@@ -0,0 +1,182 @@
+package cryptoconditions
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestPartialFulfillment_combine(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, bob, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, carol, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	message := []byte("payment 42")
+
+	tmpl, err := AtLeast(2,
+		WithPrefix([]byte("alice:"), 64, Ed25519Key(alice.Public().(ed25519.PublicKey))),
+		Ed25519Key(bob.Public().(ed25519.PublicKey)),
+		Ed25519Key(carol.Public().(ed25519.PublicKey)),
+	).Template()
+	require.NoError(t, err)
+
+	// Alice and Bob sign on their own and exchange their partial
+	// fulfillments as JSON.
+	signPartial := func(signer ed25519.PrivateKey) []byte {
+		keyring, err := NewMemoryKeyring(signer)
+		require.NoError(t, err)
+		partial := NewPartialFulfillment(tmpl)
+		missing, err := partial.Sign(message, keyring, nil)
+		require.NoError(t, err)
+		assert.Len(t, missing, 2)
+		_, err = partial.Fulfillment()
+		assert.Equal(t, ErrUnsatisfied, err)
+
+		encoded, err := json.Marshal(partial)
+		require.NoError(t, err)
+		return encoded
+	}
+	fromAlice := new(PartialFulfillment)
+	require.NoError(t, json.Unmarshal(signPartial(alice), fromAlice))
+	fromBob := new(PartialFulfillment)
+	require.NoError(t, json.Unmarshal(signPartial(bob), fromBob))
+
+	assert.True(t, fromAlice.Condition().Equals(tmpl.Condition()))
+	assert.Equal(t, []NodePath{{0, 0}}, fromAlice.FulfilledLeaves())
+	assert.Equal(t, []NodePath{{1}, {2}}, fromAlice.UnfulfilledLeaves())
+	assert.Empty(t, fromAlice.ValidateLeaves(message))
+	assert.Equal(t, []NodePath{{0, 0}}, fromAlice.ValidateLeaves([]byte("other")))
+
+	combined, err := Combine(fromAlice, fromBob)
+	require.NoError(t, err)
+	assert.Equal(t, []NodePath{{0, 0}, {1}}, combined.FulfilledLeaves())
+	ff, err := combined.Fulfillment()
+	require.NoError(t, err)
+	assert.NoError(t, ff.Validate(tmpl.Condition(), message))
+
+	// Equal leaves can be combined, different ones conflict.
+	_, err = Combine(fromAlice, combined)
+	assert.NoError(t, err)
+	aliceKeyring, err := NewMemoryKeyring(alice)
+	require.NoError(t, err)
+	otherAlice := NewPartialFulfillment(tmpl)
+	_, err = otherAlice.Sign([]byte("other"), aliceKeyring, nil)
+	require.NoError(t, err)
+	_, err = Combine(combined, otherAlice)
+	assert.EqualError(t, err,
+		"partial fulfillments fulfill the leaf at /0/0 differently")
+
+	// Signing a partial fulfillment again only fills in the missing leaves.
+	keyring, err := NewMemoryKeyring(alice, carol)
+	require.NoError(t, err)
+	missing, err := combined.Sign(message, keyring, nil)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, fromAlice.Leaf(NodePath{0, 0}), combined.Leaf(NodePath{0, 0}))
+}
+
+func TestPartialFulfillment_mismatch(t *testing.T) {
+	preimage := []byte("secret")
+	hash := sha256.Sum256(preimage)
+	pubkey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tmpl, err := AnyOf(Hashlock(hash[:], len(preimage)), Ed25519Key(pubkey)).Template()
+	require.NoError(t, err)
+	reordered, err := AnyOf(Ed25519Key(pubkey), Hashlock(hash[:], len(preimage))).Template()
+	require.NoError(t, err)
+	other, err := AnyOf(Hashlock(hash[:], len(preimage))).Template()
+	require.NoError(t, err)
+
+	_, err = Combine(NewPartialFulfillment(tmpl), NewPartialFulfillment(other))
+	assert.EqualError(t, err, "partial fulfillments are for different conditions")
+	_, err = Combine(NewPartialFulfillment(tmpl), NewPartialFulfillment(reordered))
+	assert.EqualError(t, err, "partial fulfillments are ordered differently at /0")
+
+	// Leaves must match the template.
+	partial := NewPartialFulfillment(tmpl)
+	assert.Error(t, partial.SetLeaf(NodePath{0}, NewPreimageSha256([]byte("wrong"))))
+	assert.Error(t, partial.SetLeaf(nil, NewPreimageSha256(preimage)))
+	assert.NoError(t, partial.SetLeaf(NodePath{0}, NewPreimageSha256(preimage)))
+
+	// The tree of an encoded partial fulfillment must match its condition.
+	encoded, err := json.Marshal(partial)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	decoded["condition"] = other.Condition().URI()
+	encoded, err = json.Marshal(decoded)
+	require.NoError(t, err)
+	assert.EqualError(t, json.Unmarshal(encoded, new(PartialFulfillment)),
+		"tree does not match the condition")
+}
+
+func TestPartialFulfillment_conditionLeaves(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, bob, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	carol, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	message := []byte("payment 42")
+
+	// Only the condition of Carol's branch is known.
+	carolTmpl, err := WithPrefix([]byte("carol:"), 64, Ed25519Key(carol)).Template()
+	require.NoError(t, err)
+	carolCond, err := NewConditionTemplate(carolTmpl.Condition())
+	require.NoError(t, err)
+	aliceTmpl, err := TemplateForPublicKey(alice.Public())
+	require.NoError(t, err)
+	bobTmpl, err := TemplateForPublicKey(bob.Public())
+	require.NoError(t, err)
+	tmpl, err := NewThresholdSha256Template(2, []Template{aliceTmpl, bobTmpl, carolCond})
+	require.NoError(t, err)
+
+	signPartial := func(signer ed25519.PrivateKey) *PartialFulfillment {
+		keyring, err := NewMemoryKeyring(signer)
+		require.NoError(t, err)
+		partial := NewPartialFulfillment(tmpl)
+		missing, err := partial.Sign(message, keyring, nil)
+		require.NoError(t, err)
+		assert.Len(t, missing, 2)
+
+		encoded, err := json.Marshal(partial)
+		require.NoError(t, err)
+		decoded := new(PartialFulfillment)
+		require.NoError(t, json.Unmarshal(encoded, decoded))
+		return decoded
+	}
+	fromAlice := signPartial(alice)
+	fromBob := signPartial(bob)
+
+	decodedCond := fromAlice.Template().(*ThresholdSha256Template).SubTemplates[2]
+	require.IsType(t, &ConditionTemplate{}, decodedCond)
+	assert.True(t, decodedCond.Condition().Equals(carolTmpl.Condition()))
+	assert.Error(t, fromAlice.SetLeaf(NodePath{2}, NewPreimageSha256(nil)))
+
+	combined, err := Combine(fromAlice, fromBob)
+	require.NoError(t, err)
+	assert.Equal(t, []NodePath{{0}, {1}}, combined.FulfilledLeaves())
+	ff, err := combined.Fulfillment()
+	require.NoError(t, err)
+	assert.NoError(t, ff.Validate(tmpl.Condition(), message))
+	assert.True(t, ff.(*FfThresholdSha256).SubConditions[0].Equals(carolTmpl.Condition()))
+
+	// The type of the node must match the type of the condition.
+	encoded, err := json.Marshal(fromAlice)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	subs := decoded["tree"].(map[string]interface{})["subfulfillments"].([]interface{})
+	subs[2].(map[string]interface{})["type"] = "ed25519-sha-256"
+	encoded, err = json.Marshal(decoded)
+	require.NoError(t, err)
+	assert.Error(t, json.Unmarshal(encoded, new(PartialFulfillment)))
+}
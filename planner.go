@@ -0,0 +1,237 @@
+package cryptoconditions
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// LeafAvailability is the type of the function used by PlanFulfillment to
+// find out whether the leaf at the given path can be fulfilled.
+type LeafAvailability func(path NodePath, leaf Template) (bool, error)
+
+// KeyringAvailability returns a LeafAvailability that reports the leaves of
+// which the key is in the keyring or the preimage is in the preimage store as
+// available. Both the keyring and the preimage store can be nil.
+func KeyringAvailability(keyring Keyring, preimages PreimageStore) LeafAvailability {
+	return func(_ NodePath, leaf Template) (bool, error) {
+		cond := leaf.Condition()
+		if leaf.ConditionType() == CTPreimageSha256 {
+			if preimages == nil {
+				return false, nil
+			}
+			_, found, err := preimages.Preimage(cond)
+			return found, err
+		}
+		if keyring == nil {
+			return false, nil
+		}
+		signer, err := keyring.Signer(cond)
+		return signer != nil, err
+	}
+}
+
+// FulfillmentPlan describes the cheapest way to fulfill a template.
+type FulfillmentPlan struct {
+	// Leaves holds the paths of the leaves to fulfill, in depth-first order.
+	Leaves []NodePath
+	// Cost is the cost of the resulting fulfillment, which is the cost of the
+	// condition of the template.
+	Cost int
+	// Size is the size of the DER encoding of the resulting fulfillment.
+	Size int
+}
+
+// Includes returns whether the leaf at the given path is part of the plan.
+func (p *FulfillmentPlan) Includes(path NodePath) bool {
+	for _, leaf := range p.Leaves {
+		if leaf.Equals(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fulfill fulfills the template following the plan. It is like
+// FulfillTemplate, but only requests the leaves of the plan.
+func (p *FulfillmentPlan) Fulfill(t Template, fulfill LeafFulfiller) (Fulfillment, error) {
+	return FulfillTemplate(t, func(path NodePath, leaf Template) (Fulfillment, error) {
+		if !p.Includes(path) {
+			return nil, nil
+		}
+		return fulfill(path, leaf)
+	})
+}
+
+// PlanFulfillment determines which of the available leaves of the template to
+// fulfill to obtain the smallest fulfillment. Prefix length limits are not
+// taken into account since the message is not known yet.
+//
+// The cost of a fulfillment does not depend on which leaves are fulfilled,
+// since the cost of THRESHOLD-SHA-256 fulfillments includes the costs of
+// their unfulfilled sub-conditions. The plan therefore minimizes the encoded
+// size, which also keeps the number of leaves to fulfill low.
+//
+// If the template cannot be fulfilled with the available leaves,
+// ErrUnsatisfied is returned.
+func PlanFulfillment(t Template, available LeafAvailability) (*FulfillmentPlan, error) {
+	node, err := planNode(t, nil, available)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, ErrUnsatisfied
+	}
+	return &FulfillmentPlan{
+		Leaves: node.leaves,
+		Cost:   t.Condition().Cost(),
+		Size:   node.size,
+	}, nil
+}
+
+// plannedNode is the cheapest fulfillment of a node of a template.
+type plannedNode struct {
+	// ff is a fulfillment with the size of the real fulfillment, but
+	// placeholder signatures and preimages.
+	ff     Fulfillment
+	size   int
+	leaves []NodePath
+}
+
+// planNode plans the fulfillment of the node at path and returns nil if it
+// cannot be fulfilled.
+func planNode(t Template, path NodePath, available LeafAvailability) (*plannedNode, error) {
+	var ff Fulfillment
+	var leaves []NodePath
+	var err error
+
+	switch tmpl := derefTemplate(t).(type) {
+	case PrefixSha256Template:
+		sub, err := planNode(tmpl.SubTemplate, path.child(0), available)
+		if err != nil || sub == nil {
+			return nil, err
+		}
+		ff = NewPrefixSha256(tmpl.Prefix, tmpl.MaxMessageLength, sub.ff)
+		leaves = sub.leaves
+
+	case ThresholdSha256Template:
+		ff, leaves, err = planThreshold(tmpl, path, available)
+		if err != nil || ff == nil {
+			return nil, err
+		}
+
+	case ConditionTemplate:
+		// Only the condition is known, so the leaf can not be fulfilled.
+		return nil, nil
+
+	default:
+		ok, err := available(path, t)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to check leaf %s", path)
+		}
+		if !ok {
+			return nil, nil
+		}
+		if ff, err = placeholderFulfillment(t); err != nil {
+			return nil, errors.Wrapf(err, "invalid leaf %s", path)
+		}
+		leaves = []NodePath{path}
+	}
+
+	size, err := encodedSize(ff)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode %s", path)
+	}
+	return &plannedNode{ff: ff, size: size, leaves: leaves}, nil
+}
+
+// planThreshold plans the fulfillment of a threshold node by fulfilling the
+// children that add the least to the encoded size compared to including only
+// their condition.
+func planThreshold(tmpl ThresholdSha256Template, path NodePath, available LeafAvailability) (Fulfillment, []NodePath, error) {
+	type candidate struct {
+		index int
+		node  *plannedNode
+		delta int
+	}
+	var candidates []candidate
+	for i, sub := range tmpl.SubTemplates {
+		node, err := planNode(sub, path.child(i), available)
+		if err != nil {
+			return nil, nil, err
+		}
+		if node == nil {
+			continue
+		}
+		encodedCond, err := sub.Condition().Encode()
+		if err != nil {
+			return nil, nil, errors.Wrapf(err,
+				"failed to encode condition of %s", path.child(i))
+		}
+		candidates = append(candidates,
+			candidate{i, node, node.size - len(encodedCond)})
+	}
+	if len(candidates) < int(tmpl.Threshold) {
+		return nil, nil, nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].delta < candidates[j].delta
+	})
+	chosen := make(map[int]*plannedNode, tmpl.Threshold)
+	for _, c := range candidates[:tmpl.Threshold] {
+		chosen[c.index] = c.node
+	}
+
+	var subFfs []Fulfillment
+	var subConds []*Condition
+	var leaves []NodePath
+	for i, sub := range tmpl.SubTemplates {
+		if node, ok := chosen[i]; ok {
+			subFfs = append(subFfs, node.ff)
+			leaves = append(leaves, node.leaves...)
+		} else {
+			subConds = append(subConds, sub.Condition())
+		}
+	}
+	return NewThresholdSha256(tmpl.Threshold, subFfs, subConds), leaves, nil
+}
+
+// placeholderFulfillment returns a fulfillment of the leaf template with a
+// signature or preimage of the right size, but zero content.
+func placeholderFulfillment(leaf Template) (Fulfillment, error) {
+	switch tmpl := derefTemplate(leaf).(type) {
+	case PreimageSha256Template:
+		return NewPreimageSha256(make([]byte, tmpl.Size)), nil
+	case RsaSha256Template:
+		return NewRsaSha256(tmpl.Modulus, make([]byte, len(tmpl.Modulus)))
+	case Ed25519Sha256Template:
+		return NewEd25519Sha256(tmpl.PublicKey, make([]byte, ed25519.SignatureSize))
+	case Secp256k1Sha256Template:
+		// NewSecp256k1Sha256 rejects the zero signature.
+		return &FfSecp256k1Sha256{
+			PublicKey: tmpl.PublicKey,
+			Signature: make([]byte, Secp256k1SignatureSize),
+		}, nil
+	case EcdsaP256Sha256Template:
+		return &FfEcdsaP256Sha256{
+			PublicKey: tmpl.PublicKey,
+			Signature: make([]byte, EcdsaP256SignatureSize),
+		}, nil
+	case WebAuthnSha256Template:
+		// The sizes of the authenticator and client data of the actual
+		// assertion are not known; the minimal sizes are used.
+		return &FfWebAuthnSha256{
+			PublicKey:         tmpl.PublicKey,
+			RpIdHash:          tmpl.RpIdHash,
+			AuthenticatorData: make([]byte, webAuthnMinAuthenticatorDataSize),
+			ClientDataJSON:    []byte(`{"type":"webauthn.get","challenge":""}`),
+			Signature:         make([]byte, EcdsaP256SignatureSize),
+		}, nil
+	case WotsSha256Template:
+		return tmpl.Fulfill(make([]byte, WotsSignatureSize))
+	}
+	return nil, errors.Errorf(
+		"cannot plan %s leaves", leaf.ConditionType())
+}
@@ -0,0 +1,138 @@
+package cryptoconditions
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestPlanFulfillment(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, bob, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	carol, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	preimage := []byte("secret")
+	hash := sha256.Sum256(preimage)
+
+	tmpl, err := AtLeast(2,
+		RSAKey(&carol.PublicKey),
+		WithPrefix([]byte("alice:"), 64, Ed25519Key(alice.Public().(ed25519.PublicKey))),
+		AnyOf(
+			Ed25519Key(bob.Public().(ed25519.PublicKey)),
+			Hashlock(hash[:], len(preimage)),
+		),
+	).Template()
+	require.NoError(t, err)
+
+	keyring, err := NewMemoryKeyring(alice, bob, carol)
+	require.NoError(t, err)
+	preimages := NewMemoryPreimageStore(preimage)
+
+	// With everything available, the large RSA signature and the Ed25519
+	// signature in the nested threshold are avoided.
+	plan, err := PlanFulfillment(tmpl, KeyringAvailability(keyring, preimages))
+	require.NoError(t, err)
+	assert.Equal(t, []NodePath{{1, 0}, {2, 1}}, plan.Leaves)
+	assert.Equal(t, tmpl.Condition().Cost(), plan.Cost)
+	assert.True(t, plan.Includes(NodePath{2, 1}))
+	assert.False(t, plan.Includes(NodePath{0}))
+
+	// Without Alice's key, Carol has to sign.
+	planWithoutAlice, err := PlanFulfillment(tmpl,
+		func(path NodePath, leaf Template) (bool, error) {
+			return !path.Equals(NodePath{1, 0}), nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, []NodePath{{0}, {2, 1}}, planWithoutAlice.Leaves)
+	assert.True(t, planWithoutAlice.Size > plan.Size)
+
+	// The plan can be followed to fulfill only the necessary leaves.
+	message := []byte("hello")
+	var requested []NodePath
+	ff, err := plan.Fulfill(tmpl, func(path NodePath, leaf Template) (Fulfillment, error) {
+		requested = append(requested, path)
+		ff, _, err := signLeaf(leaf, message, keyring, preimages)
+		return ff, err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, plan.Leaves, requested)
+	assert.Equal(t, plan.Cost, ff.Cost())
+
+	// Only Bob is not enough.
+	bobKeyring, err := NewMemoryKeyring(bob)
+	require.NoError(t, err)
+	_, err = PlanFulfillment(tmpl, KeyringAvailability(bobKeyring, nil))
+	assert.Equal(t, ErrUnsatisfied, err)
+}
+
+func TestPlanFulfillment_size(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	fallback, err := GenerateWotsKey(rand.Reader)
+	require.NoError(t, err)
+	message := []byte("payment 42")
+
+	tmpl, err := AllOf(
+		Ed25519Key(alice.Public().(ed25519.PublicKey)),
+		WotsKey(fallback.PublicKey()),
+	).Template()
+	require.NoError(t, err)
+	keyring, err := NewMemoryKeyring(alice, fallback)
+	require.NoError(t, err)
+	plan, err := PlanFulfillment(tmpl, KeyringAvailability(keyring, nil))
+	require.NoError(t, err)
+	ff, _, err := Sign(tmpl, message, keyring, nil)
+	require.NoError(t, err)
+
+	leaves := 0
+	for _, sff := range ff.(*FfThresholdSha256).SubFulfillments {
+		encoded, err := sff.Encode()
+		require.NoError(t, err)
+		leaves += len(encoded)
+	}
+	// The leaves are wrapped in the [0] subfulfillments element, followed
+	// by the empty [1] subconditions element, in the [2] threshold element.
+	// With between 256 and 65535 bytes of content, both the [0] and the [2]
+	// element have a tag byte and three length bytes, and the [1] element
+	// has a tag byte and a length byte.
+	require.True(t, leaves >= 0x100 && leaves+6 <= 0xffff, "%d bytes of leaves", leaves)
+	assert.Equal(t, leaves+4+2+4, plan.Size)
+}
+
+func TestEncodedSize_der(t *testing.T) {
+	for contentSize, expected := range map[int]int{
+		0: 2, 0x7f: 0x81, 0x80: 0x83, 0xff: 0x102, 0x100: 0x104,
+	} {
+		assert.Equal(t, expected, derElementSize(contentSize), "%#x", contentSize)
+	}
+	for v, expected := range map[uint64]int{
+		0: 1, 0x7f: 1, 0x80: 2, 0xff: 2, 0x100: 2, 0x8000: 3,
+	} {
+		assert.Equal(t, expected, derIntegerSize(v), "%#x", v)
+	}
+}
+
+func TestPlanFulfillment_rfcVectors(t *testing.T) {
+	// The RFC vectors encode the smallest fulfillments, which is what the
+	// planner plans.
+	files, err := ioutil.ReadDir(testRfcVectorPathValid)
+	require.NoError(t, err)
+	all := func(NodePath, Template) (bool, error) { return true, nil }
+	for _, file := range files {
+		vector := testRfcVectorGet(t, true, file.Name())
+		ff := testRfcVectorConstructFulfillmentFromJSON(t, vector.JSON)
+		tmpl, err := TemplateOf(ff)
+		require.NoError(t, err)
+		plan, err := PlanFulfillment(tmpl, all)
+		require.NoError(t, err, file.Name())
+		assert.Equal(t, len(vector.FulfillmentEncoding), plan.Size, file.Name())
+	}
+}
@@ -0,0 +1,307 @@
+package cryptoconditions
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// renderFingerprintLength is the number of fingerprint bytes shown when
+	// rendering conditions.
+	renderFingerprintLength = 4
+	// renderKeyLength is the number of public key bytes shown when rendering
+	// signature fulfillments.
+	renderKeyLength = 2
+	// renderPrefixLength is the number of characters of a textual prefix
+	// shown in summaries.
+	renderPrefixLength = 14
+)
+
+// String returns a short human-readable representation of the condition,
+// showing its type, an abbreviated fingerprint, its cost and its subtypes.
+func (c *Condition) String() string {
+	s := fmt.Sprintf("%s %s cost=%d",
+		c.Type(), abbreviateHex(c.Fingerprint(), renderFingerprintLength), c.Cost())
+	if c.Type().IsCompound() {
+		s += " subtypes=" + renderSubTypes(c.SubTypes())
+	}
+	return s
+}
+
+// WriteTree writes an indented outline of the fulfillment tree to w.
+// Every line represents a node and shows its type, abbreviated fingerprint,
+// cost and subtypes together with the type-specific public data.
+// Sub-conditions of partially fulfilled branches are marked as unfulfilled.
+func WriteTree(w io.Writer, ff Fulfillment) error {
+	if _, err := conditionOf(ff); err != nil {
+		return err
+	}
+	return writeTreeNode(w, ff, 0)
+}
+
+func writeTreeNode(w io.Writer, ff Fulfillment, depth int) error {
+	indent := strings.Repeat("  ", depth)
+	fields := append([]string{ff.Condition().String()}, renderDetails(ff)...)
+	if _, err := fmt.Fprintf(w, "%s%s\n",
+		indent, strings.Join(fields, " ")); err != nil {
+		return err
+	}
+
+	subFfs, subConds := subNodes(ff)
+	for _, sff := range subFfs {
+		if err := writeTreeNode(w, sff, depth+1); err != nil {
+			return err
+		}
+	}
+	for _, sc := range subConds {
+		if err := writeTreeCondition(w, sc, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTreeCondition(w io.Writer, cond *Condition, depth int) error {
+	_, err := fmt.Fprintf(w, "%s%s (unfulfilled)\n",
+		strings.Repeat("  ", depth), cond)
+	return err
+}
+
+// renderDetails returns the type-specific public data of a fulfillment as
+// key=value fields, the way they are shown in tree outlines and graphs.
+func renderDetails(ff Fulfillment) []string {
+	switch f := derefFulfillment(ff).(type) {
+	case FfPreimageSha256:
+		return []string{fmt.Sprintf("size=%d", len(f.Preimage))}
+	case FfPrefixSha256:
+		return []string{
+			"prefix=" + renderBytes(f.Prefix, 0),
+			fmt.Sprintf("maxMessageLength=%d", f.MaxMessageLength),
+		}
+	case FfThresholdSha256:
+		return []string{fmt.Sprintf("threshold=%d/%d", f.Threshold,
+			len(f.SubFulfillments)+len(f.SubConditions))}
+	case FfRsaSha256:
+		return []string{
+			"modulus=" + abbreviateHex(f.Modulus, renderKeyLength),
+			fmt.Sprintf("bits=%d", f.PublicKey().N.BitLen()),
+		}
+	case FfEd25519Sha256:
+		return []string{"key=" + abbreviateHex(f.PublicKey, renderKeyLength)}
+	case FfSecp256k1Sha256:
+		return []string{"key=" + abbreviateHex(f.PublicKey, renderKeyLength)}
+	case FfEcdsaP256Sha256:
+		return []string{"key=" + abbreviateHex(f.PublicKey, renderKeyLength)}
+	case FfWebAuthnSha256:
+		return []string{
+			"key=" + abbreviateHex(f.PublicKey, renderKeyLength),
+			"rpIdHash=" + abbreviateHex(f.RpIdHash, renderKeyLength),
+		}
+	case FfWotsSha256:
+		return []string{"key=" + abbreviateHex(f.PublicKeyHash, renderKeyLength)}
+	}
+	return nil
+}
+
+// Describe returns a plain-English one-line summary of what is required to
+// fulfill the fulfillment's condition, for example:
+//
+//	2 of: (signature by 2e53… over prefix 'https://notary…'), preimage
+//
+// A threshold fulfillment does not keep the order in which its children were
+// given, so the fulfilled children are listed first, in order, followed by
+// the unfulfilled ones.
+func Describe(ff Fulfillment) string {
+	switch f := derefFulfillment(ff).(type) {
+	case FfPreimageSha256:
+		return "preimage"
+
+	case FfPrefixSha256:
+		var sub string
+		if f.IsFulfilled() {
+			sub = Describe(f.SubFulfillment)
+		} else {
+			sub = describeCondition(f.subCondition)
+		}
+		return fmt.Sprintf("%s over prefix %s",
+			sub, renderBytes(f.Prefix, renderPrefixLength))
+
+	case FfThresholdSha256:
+		parts := make([]string, 0,
+			len(f.SubFulfillments)+len(f.SubConditions))
+		for _, sff := range f.SubFulfillments {
+			if sff.ConditionType().IsCompound() {
+				parts = append(parts, "("+Describe(sff)+")")
+			} else {
+				parts = append(parts, Describe(sff))
+			}
+		}
+		for _, sc := range f.SubConditions {
+			parts = append(parts, describeCondition(sc))
+		}
+		return fmt.Sprintf("%d of: %s", f.Threshold, strings.Join(parts, ", "))
+
+	case FfRsaSha256:
+		return "RSA signature by " + abbreviateHex(f.Modulus, renderKeyLength)
+
+	case FfEd25519Sha256:
+		return "signature by " + abbreviateHex(f.PublicKey, renderKeyLength)
+
+	case FfSecp256k1Sha256:
+		return "secp256k1 signature by " + abbreviateHex(f.PublicKey, renderKeyLength)
+
+	case FfEcdsaP256Sha256:
+		return "P-256 signature by " + abbreviateHex(f.PublicKey, renderKeyLength)
+
+	case FfWebAuthnSha256:
+		return "passkey assertion by " + abbreviateHex(f.PublicKey, renderKeyLength)
+
+	case FfWotsSha256:
+		return "one-time signature by " + abbreviateHex(f.PublicKeyHash, renderKeyLength)
+	}
+	return ff.ConditionType().String()
+}
+
+// describeCondition describes a sub-condition of which only the condition
+// is known.
+func describeCondition(cond *Condition) string {
+	return fmt.Sprintf("unfulfilled %s %s", cond.Type(),
+		abbreviateHex(cond.Fingerprint(), renderFingerprintLength))
+}
+
+// WriteDOT writes the fulfillment tree to w in the Graphviz DOT language.
+// Nodes are labelled like the lines of WriteTree, unfulfilled sub-conditions
+// are drawn with dashed borders.
+func WriteDOT(w io.Writer, ff Fulfillment) error {
+	if _, err := conditionOf(ff); err != nil {
+		return err
+	}
+	d := &dotWriter{w: w}
+	d.printf("digraph condition {\n")
+	d.printf("  node [shape=box, fontname=\"monospace\"];\n")
+	d.node(ff)
+	d.printf("}\n")
+	return d.err
+}
+
+// dotWriter keeps the state needed to write a DOT graph and remembers the
+// first write error.
+type dotWriter struct {
+	w      io.Writer
+	nextID int
+	err    error
+}
+
+func (d *dotWriter) printf(format string, args ...interface{}) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = fmt.Fprintf(d.w, format, args...)
+}
+
+// node writes the node for the fulfillment and its subtree and returns its
+// identifier.
+func (d *dotWriter) node(ff Fulfillment) string {
+	cond := ff.Condition()
+	id := d.newID()
+	fields := append([]string{
+		cond.Type().String(),
+		abbreviateHex(cond.Fingerprint(), renderFingerprintLength),
+		fmt.Sprintf("cost=%d", cond.Cost()),
+	}, renderDetails(ff)...)
+	label := strings.Join(fields, "\n")
+	d.printf("  %s [label=%s];\n", id, dotQuote(label))
+
+	subFfs, subConds := subNodes(ff)
+	for _, sff := range subFfs {
+		d.edge(id, d.node(sff))
+	}
+	for _, sc := range subConds {
+		d.edge(id, d.conditionNode(sc))
+	}
+	return id
+}
+
+// conditionNode writes the node for an unfulfilled sub-condition and returns
+// its identifier.
+func (d *dotWriter) conditionNode(cond *Condition) string {
+	id := d.newID()
+	label := fmt.Sprintf("%s\n%s\ncost=%d", cond.Type(),
+		abbreviateHex(cond.Fingerprint(), renderFingerprintLength), cond.Cost())
+	d.printf("  %s [label=%s, style=dashed];\n", id, dotQuote(label))
+	return id
+}
+
+func (d *dotWriter) edge(from, to string) {
+	d.printf("  %s -> %s;\n", from, to)
+}
+
+func (d *dotWriter) newID() string {
+	id := fmt.Sprintf("n%d", d.nextID)
+	d.nextID++
+	return id
+}
+
+// dotQuote quotes s as a DOT string literal.
+func dotQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return `"` + s + `"`
+}
+
+// renderSubTypes renders a condition type set as a comma-separated list.
+func renderSubTypes(set ConditionTypeSet) string {
+	types := set.AllTypes()
+	names := make([]string, len(types))
+	for i, ct := range types {
+		names[i] = ct.String()
+	}
+	return strings.Join(names, ",")
+}
+
+// abbreviateHex returns the hex encoding of the first n bytes of b, followed
+// by an ellipsis if b is longer.
+func abbreviateHex(b []byte, n int) string {
+	if len(b) <= n {
+		return hex.EncodeToString(b)
+	}
+	return hex.EncodeToString(b[:n]) + "…"
+}
+
+// renderBytes renders b as a single-quoted string, with quotes and
+// backslashes escaped, if it is printable text and as hexadecimal otherwise. If limit is positive,
+// the output is abbreviated to limit characters or bytes.
+func renderBytes(b []byte, limit int) string {
+	if !isPrintable(b) {
+		if limit > 0 {
+			return "0x" + abbreviateHex(b, limit/2)
+		}
+		return "0x" + hex.EncodeToString(b)
+	}
+	s := string(b)
+	if limit > 0 && utf8.RuneCountInString(s) > limit {
+		s = string([]rune(s)[:limit]) + "…"
+	}
+	return "'" + singleQuoteReplacer.Replace(s) + "'"
+}
+
+var singleQuoteReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// isPrintable returns true if b is valid UTF-8 and only contains printable
+// characters.
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package cryptoconditions
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNotarizedReceipt constructs the fulfillment from RFC test vector 0016.
+func testNotarizedReceipt(t *testing.T) *FfThresholdSha256 {
+	prefix := []byte("https://notary.example/cases/657c12da-8dca-43b0-97ca-8ee8c38ab9f7/state/executed")
+	edFf, err := NewEd25519Sha256(
+		unbase64("LlMeiL_oxBn5Ya2ckB3ivdjnoOcUhFUFnonreZhrJSQ"),
+		unbase64("5f3bvsLo21m8tqaA5KcFb91GUAtOmaNxnyVzUDahSSg-KHzt7c2eWlDNl2q0EfhP9Wmqveb24M5qC90MSoJGCA"))
+	require.NoError(t, err)
+	return NewThresholdSha256(2, []Fulfillment{
+		NewPrefixSha256(prefix, 0, edFf),
+		NewPreimageSha256(prefix),
+	}, nil)
+}
+
+func TestDescribe(t *testing.T) {
+	ff := testNotarizedReceipt(t)
+	assert.Equal(t,
+		"2 of: (signature by 2e53… over prefix 'https://notary…'), preimage",
+		Describe(ff))
+
+	// Values and pointers are described the same way.
+	assert.Equal(t, Describe(ff), Describe(*ff))
+
+	// Binary prefixes are shown in hex and unfulfilled branches by type.
+	subCond := NewSimpleCondition(CTEd25519Sha256, unhex("0102030405"), ffEd25519Sha256Cost)
+	prefixFf := NewPrefixSha256Unfulfilled([]byte{0x00, 0xff}, 0, subCond)
+	assert.Equal(t,
+		"unfulfilled ED25519-SHA-256 01020304… over prefix 0x00ff",
+		Describe(prefixFf))
+
+	// Quotes and backslashes in prefixes are escaped.
+	prefixFf = NewPrefixSha256Unfulfilled([]byte(`a'), b\`), 0, subCond)
+	assert.Equal(t,
+		`unfulfilled ED25519-SHA-256 01020304… over prefix 'a\'), b\\'`,
+		Describe(prefixFf))
+
+	// Fulfilled children are listed before unfulfilled ones.
+	thresholdFf := NewThresholdSha256(1, []Fulfillment{NewPreimageSha256([]byte("x"))},
+		[]*Condition{subCond})
+	assert.Equal(t,
+		"1 of: preimage, unfulfilled ED25519-SHA-256 01020304…",
+		Describe(thresholdFf))
+	thresholdFf = NewThresholdSha256(1, []Fulfillment{prefixFf},
+		[]*Condition{NewPreimageSha256([]byte("x")).Condition()})
+	assert.Equal(t,
+		`1 of: (unfulfilled ED25519-SHA-256 01020304… over prefix 'a\'), b\\'), `+
+			"unfulfilled PREIMAGE-SHA-256 2d711642…",
+		Describe(thresholdFf))
+}
+
+func TestWriteTree(t *testing.T) {
+	ff := testNotarizedReceipt(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteTree(&buf, ff))
+	t.Logf("Tree:\n%s", buf.String())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "THRESHOLD-SHA-256 "))
+	assert.Contains(t, lines[0], "threshold=2/2")
+	assert.True(t, strings.HasPrefix(lines[1], "  PREFIX-SHA-256 "))
+	assert.True(t, strings.HasPrefix(lines[2], "    ED25519-SHA-256 "))
+	assert.Contains(t, lines[2], "key=2e53…")
+	assert.True(t, strings.HasPrefix(lines[3], "  PREIMAGE-SHA-256 "))
+}
+
+func TestWriteDOT(t *testing.T) {
+	ff := testNotarizedReceipt(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDOT(&buf, ff))
+	dot := buf.String()
+	t.Logf("DOT:\n%s", dot)
+
+	assert.True(t, strings.HasPrefix(dot, "digraph condition {\n"))
+	assert.True(t, strings.HasSuffix(dot, "}\n"))
+	assert.Equal(t, 3, strings.Count(dot, "->"))
+	assert.Contains(t, dot, "n0 -> n1;")
+	assert.Contains(t, dot, "n1 -> n2;")
+	assert.Contains(t, dot, "n0 -> n3;")
+}
+
+func TestCondition_String(t *testing.T) {
+	cond, err := ParseURI("ni:///sha-256;uxrFJgwBQbflSybsIzBjfFWXv4EZUawJ50StIP934oc?fpt=prefix-sha-256&cost=1024&subtypes=preimage-sha-256")
+	require.NoError(t, err)
+	assert.Equal(t,
+		"PREFIX-SHA-256 bb1ac526… cost=1024 subtypes=PREIMAGE-SHA-256",
+		cond.String())
+}
@@ -13,7 +13,9 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-//TODO threshold condition tests are skipped!
+//TODO THRESHOLD-SHA-256 fulfillments can not be DER encoded or decoded yet,
+// so vectors containing them are only checked with the fulfillment
+// constructed from the JSON.
 
 // This file implements tests for the test vectors provided by the RFC.
 // The vectors can be found here:
@@ -119,7 +121,6 @@ func testRfcVectorConstructFulfillmentFromJSON(t *testing.T, fields map[string]i
 			uint32(fields["maxMessageLength"].(float64)), subfulfillment)
 
 	case CTThresholdSha256:
-		t.SkipNow()
 		threshold := uint16(fields["threshold"].(float64))
 		subfulfillments := make([]Fulfillment,
 			len(fields["subfulfillments"].([]interface{})))
@@ -167,6 +168,15 @@ func testRfcVectorConstructFulfillmentFromJSON(t *testing.T, fields map[string]i
 //  - Create fulfillment from json, serialize fulfillment,
 //    should match fulfillment.
 func testRfcVectorValidStandard(t *testing.T, vector rfcVector) {
+	hasThreshold := testRfcVectorHasThreshold(vector)
+	// decode decodes the fulfillment, or returns the one constructed from the
+	// JSON if it can not be decoded.
+	decode := func() (Fulfillment, error) {
+		if hasThreshold {
+			return vector.fulfillment, nil
+		}
+		return DecodeFulfillment(vector.FulfillmentEncoding)
+	}
 
 	{
 		// Parse conditionBinary, serialize as a URI, should match conditionUri.
@@ -183,7 +193,7 @@ func testRfcVectorValidStandard(t *testing.T, vector rfcVector) {
 		require.NoError(t, err)
 		assert.Equal(t, vector.ConditionBinary.bytes(), encoded)
 	}
-	{
+	if !hasThreshold {
 		// Parse fulfillment, serialize fulfillment, should match fulfillment.
 		ff, err := DecodeFulfillment(vector.FulfillmentEncoding)
 		require.NoError(t, err)
@@ -193,7 +203,7 @@ func testRfcVectorValidStandard(t *testing.T, vector rfcVector) {
 	}
 	{
 		// Parse fulfillment and validate, should return true.
-		ff, err := DecodeFulfillment(vector.FulfillmentEncoding)
+		ff, err := decode()
 		require.NoError(t, err)
 		cond, err := DecodeCondition(vector.ConditionBinary.bytes())
 		require.NoError(t, err)
@@ -202,7 +212,7 @@ func testRfcVectorValidStandard(t *testing.T, vector rfcVector) {
 	}
 	{
 		// Parse fulfillment and generate the fingerprint contents
-		ff, err := DecodeFulfillment(vector.FulfillmentEncoding.bytes())
+		ff, err := decode()
 		require.NoError(t, err)
 		fpc := ff.fingerprintContents()
 		assert.Equal(t, vector.FingerprintContents.bytes(), fpc)
@@ -210,11 +220,11 @@ func testRfcVectorValidStandard(t *testing.T, vector rfcVector) {
 	{
 		// Parse fulfillment, generate the condition, serialize the
 		// condition as a URI, should match conditionUri.
-		ff, err := DecodeFulfillment(vector.FulfillmentEncoding)
+		ff, err := decode()
 		require.NoError(t, err)
 		assertEquivalentURIs(t, vector.ConditionUri, ff.Condition().URI())
 	}
-	{
+	if !hasThreshold {
 		// Create fulfillment from json, serialize fulfillment,
 		// should match fulfillment.
 		ff := testRfcVectorConstructFulfillmentFromJSON(t, vector.JSON)
@@ -224,6 +234,19 @@ func testRfcVectorValidStandard(t *testing.T, vector rfcVector) {
 	}
 }
 
+// testRfcVectorHasThreshold returns whether the fulfillment of the vector
+// contains a THRESHOLD-SHA-256 fulfillment.
+func testRfcVectorHasThreshold(vector rfcVector) bool {
+	found := false
+	Walk(vector.fulfillment, func(path NodePath, ff Fulfillment) error {
+		if ff.ConditionType() == CTThresholdSha256 {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
 func testRfcVectorValidPreimageSha256(t *testing.T, vector rfcVector) {
 	// Decode and cast the fulfillment.
 	gff, err := DecodeFulfillment(vector.FulfillmentEncoding.bytes())
@@ -332,7 +355,10 @@ func TestRfcVectors(t *testing.T) {
 			vector.fulfillment = testRfcVectorConstructFulfillmentFromJSON(t, vector.JSON)
 			// Run the standard tests.
 			testRfcVectorValidStandard(t, vector)
-			// Run the type-specific tests.
+			// Run the type-specific tests, which decode the fulfillment.
+			if testRfcVectorHasThreshold(vector) {
+				return
+			}
 			typeSpecificTester := testRfcVectorValidFulfillmentTesters[vector.fulfillment.ConditionType()]
 			if typeSpecificTester == nil {
 				t.Log("Failing because no type-specific tester function")
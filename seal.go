@@ -0,0 +1,64 @@
+package cryptoconditions
+
+import "sync"
+
+// conditionMemo holds the condition of a sealed compound fulfillment, which
+// is computed when it is first needed.
+type conditionMemo struct {
+	once      sync.Once
+	condition *Condition
+	err       error
+}
+
+// get returns the memoized condition, or the error computing it, computing
+// it with compute the first time.
+func (m *conditionMemo) get(compute func() (*Condition, error)) (*Condition, error) {
+	m.once.Do(func() {
+		m.condition, m.err = compute()
+	})
+	return m.condition, m.err
+}
+
+// Seal returns a sealed version of the fulfillment tree. The condition, and
+// with it the fingerprint, cost and subtypes, of every PREFIX-SHA-256 and
+// THRESHOLD-SHA-256 node of a sealed tree is computed only once, the first
+// time it is needed, instead of every time from the whole subtree. This
+// makes validating deep trees take linear time.
+//
+// Only the compound nodes are copied: the leaf fulfillments, sub-conditions
+// and prefixes of the sealed tree are shared with ff. Neither the sealed tree
+// nor the parts it shares with ff must be modified afterwards, since the
+// memoized conditions would not reflect the changes; use Clone to get a copy
+// that can be modified.
+// Sealing a sealed tree returns it as is. Sealed trees are safe for
+// concurrent use. The validation functions seal the fulfillments passed to
+// them, so sealing is only worthwhile for fulfillments whose conditions are
+// needed repeatedly.
+func Seal(ff Fulfillment) Fulfillment {
+	switch f := derefFulfillment(ff).(type) {
+	case FfPrefixSha256:
+		if f.memo != nil {
+			return ff
+		}
+		if f.SubFulfillment != nil {
+			f.SubFulfillment = Seal(f.SubFulfillment)
+		}
+		f.memo = new(conditionMemo)
+		return &f
+
+	case FfThresholdSha256:
+		if f.memo != nil {
+			return ff
+		}
+		if f.SubFulfillments != nil {
+			subs := make([]Fulfillment, len(f.SubFulfillments))
+			for i, sff := range f.SubFulfillments {
+				subs[i] = Seal(sff)
+			}
+			f.SubFulfillments = subs
+		}
+		f.memo = new(conditionMemo)
+		return &f
+	}
+	return ff
+}
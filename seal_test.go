@@ -0,0 +1,130 @@
+package cryptoconditions
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDeepPrefix returns a chain of depth PREFIX-SHA-256 fulfillments around
+// a preimage, with a threshold of two at every tenth level.
+func testDeepPrefix(depth int) Fulfillment {
+	var ff Fulfillment = NewPreimageSha256([]byte("secret"))
+	for i := 0; i < depth; i++ {
+		if i%10 == 9 {
+			ff = NewThresholdSha256(2, []Fulfillment{ff, NewPreimageSha256([]byte{byte(i)})}, nil)
+			continue
+		}
+		ff = NewPrefixSha256([]byte{byte(i)}, 1024, ff)
+	}
+	return ff
+}
+
+func TestSeal(t *testing.T) {
+	ff := testDeepPrefix(12)
+	sealed := Seal(ff)
+	assert.True(t, FulfillmentsEqual(ff, sealed))
+	assert.Equal(t, ff.Condition(), sealed.Condition())
+	assert.Equal(t, ff.Cost(), sealed.Cost())
+	assert.True(t, Seal(sealed) == sealed)
+
+	encoded, err := ff.Encode()
+	require.NoError(t, err)
+	sealedEncoded, err := sealed.Encode()
+	require.NoError(t, err)
+	assert.Equal(t, encoded, sealedEncoded)
+
+	// The original is not sealed and can still be modified.
+	assert.Nil(t, ff.(*FfPrefixSha256).memo)
+	ff.(*FfPrefixSha256).Prefix = []byte("other")
+	assert.NotEqual(t, ff.Condition(), sealed.Condition())
+
+	// Clones of sealed fulfillments are not sealed.
+	assert.Nil(t, Clone(sealed).(*FfPrefixSha256).memo)
+
+	// Simple fulfillments are returned as is.
+	preimage := NewPreimageSha256([]byte("secret"))
+	assert.True(t, Seal(preimage) == Fulfillment(preimage))
+	assert.Nil(t, Seal(nil))
+}
+
+func TestSeal_concurrent(t *testing.T) {
+	ff := testDeepPrefix(30)
+	expected := ff.Condition()
+	sealed := Seal(ff)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, expected, sealed.Condition())
+		}()
+	}
+	wg.Wait()
+}
+
+func TestValidate_deepTree(t *testing.T) {
+	// Without memoization, computing the condition of this tree takes
+	// exponential time in its depth.
+	ff := testDeepPrefix(200)
+	cond := ff.Condition()
+	assert.NoError(t, ff.Validate(cond, []byte("hello")))
+	assert.NoError(t, ValidateWithOptions(ff, cond, []byte("hello"), nil))
+	assert.Error(t, ff.Validate(testDeepPrefix(199).Condition(), []byte("hello")))
+}
+
+func BenchmarkValidate_deepTree(b *testing.B) {
+	for _, depth := range []int{10, 100, 1000} {
+		ff := testDeepPrefix(depth)
+		cond := ff.Condition()
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := ff.Validate(cond, []byte("hello")); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCondition_deepTree(b *testing.B) {
+	for _, depth := range []int{10, 100, 1000} {
+		ff := testDeepPrefix(depth)
+		b.Run(fmt.Sprintf("depth=%d/unsealed", depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ff.Condition()
+			}
+		})
+		sealed := Seal(ff)
+		b.Run(fmt.Sprintf("depth=%d/sealed", depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sealed.Condition()
+			}
+		})
+	}
+}
+
+func BenchmarkCost_deepTree(b *testing.B) {
+	for _, depth := range []int{10, 100, 1000} {
+		ff := testDeepPrefix(depth)
+		b.Run(fmt.Sprintf("depth=%d/unsealed", depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ff.Cost()
+			}
+		})
+		sealed := Seal(ff)
+		b.Run(fmt.Sprintf("depth=%d/sealed", depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sealed.Cost()
+			}
+		})
+	}
+}
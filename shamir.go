@@ -0,0 +1,269 @@
+package cryptoconditions
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// PreimageShare is a Shamir share of the preimage of a PREIMAGE-SHA-256
+// condition. Any Threshold shares of the same preimage reconstruct it, fewer
+// shares reveal nothing about it.
+type PreimageShare struct {
+	// Index is the x coordinate of the share, from 1 to 255.
+	Index byte
+	// Threshold is the number of shares needed to reconstruct the preimage.
+	Threshold byte
+	// Value holds one byte of share data per byte of the preimage.
+	Value []byte
+}
+
+// NewSharedPreimage generates a random preimage of the given size, splits it
+// into n shares of which threshold are needed to reconstruct it, and returns
+// the PREIMAGE-SHA-256 condition of the preimage together with the shares.
+// The preimage itself is not returned and should not be kept.
+func NewSharedPreimage(size, threshold, n int) (*Condition, []PreimageShare, error) {
+	if size < 1 {
+		return nil, nil, errors.Errorf("preimage size must be positive, not %d", size)
+	}
+	preimage := make([]byte, size)
+	if _, err := io.ReadFull(rand.Reader, preimage); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate preimage")
+	}
+	shares, err := SplitPreimage(preimage, threshold, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewPreimageSha256(preimage).Condition(), shares, nil
+}
+
+// SplitPreimage splits the preimage into n shares of which threshold are
+// needed to reconstruct it, using Shamir's secret sharing over GF(256).
+func SplitPreimage(preimage []byte, threshold, n int) ([]PreimageShare, error) {
+	switch {
+	case threshold < 1:
+		return nil, errors.Errorf("threshold must be at least 1, not %d", threshold)
+	case threshold > n:
+		return nil, errors.Errorf(
+			"threshold of %d exceeds the number of shares (%d)", threshold, n)
+	case n > 255:
+		return nil, errors.Errorf("at most 255 shares are possible, not %d", n)
+	}
+
+	shares := make([]PreimageShare, n)
+	for i := range shares {
+		shares[i] = PreimageShare{
+			Index:     byte(i + 1),
+			Threshold: byte(threshold),
+			Value:     make([]byte, len(preimage)),
+		}
+	}
+
+	// Every byte of the preimage is the constant term of its own random
+	// polynomial of degree threshold-1.
+	coefficients := make([]byte, threshold)
+	for b, secret := range preimage {
+		if _, err := io.ReadFull(rand.Reader, coefficients[1:]); err != nil {
+			return nil, errors.Wrap(err, "failed to generate coefficients")
+		}
+		coefficients[0] = secret
+		for i := range shares {
+			shares[i].Value[b] = gf256EvaluatePolynomial(coefficients, shares[i].Index)
+		}
+	}
+	for i := range coefficients {
+		coefficients[i] = 0
+	}
+	return shares, nil
+}
+
+// maxShareCombinations is the maximum number of combinations of shares that
+// CombinePreimageShares tries. The number of combinations grows
+// exponentially with the number of extra shares, so that the work must be
+// bounded.
+const maxShareCombinations = 1000
+
+// CombinePreimageShares reconstructs the preimage of a PREIMAGE-SHA-256
+// condition from shares and returns its fulfillment.
+// The reconstructed preimage is verified against the condition. If more
+// shares than needed are given, combinations of shares are tried until one
+// matches, and the indexes of the shares that do not agree with it are
+// returned as bad. At most maxShareCombinations combinations are tried; if
+// many of the shares are bad, the shares known to be bad must be left out.
+// Shares that are malformed, or that have another threshold than most of
+// the shares, are always reported as bad.
+func CombinePreimageShares(cond *Condition, shares []PreimageShare) (*FfPreimageSha256, []byte, error) {
+	if cond.Type() != CTPreimageSha256 {
+		return nil, nil, errors.Errorf(
+			"expected a PREIMAGE-SHA-256 condition, not %s", cond.Type())
+	}
+	if len(shares) == 0 {
+		return nil, nil, errors.New("no shares given")
+	}
+
+	// Discard the shares that cannot be part of the preimage at all.
+	threshold := int(majorityThreshold(shares))
+	var valid []PreimageShare
+	var bad []byte
+	seen := make(map[byte]bool)
+	for _, share := range shares {
+		if share.Index == 0 || seen[share.Index] ||
+			int(share.Threshold) != threshold || len(share.Value) != cond.Cost() {
+			bad = append(bad, share.Index)
+			continue
+		}
+		seen[share.Index] = true
+		valid = append(valid, share)
+	}
+	if threshold < 1 || len(valid) < threshold {
+		return nil, bad, errors.Errorf(
+			"need %d valid shares, got %d", threshold, len(valid))
+	}
+
+	// Try the combinations of shares until one reproduces the condition.
+	subset := make([]PreimageShare, threshold)
+	var found []byte
+	tried := 0
+	forEachCombination(len(valid), threshold, func(indexes []int) bool {
+		if tried == maxShareCombinations {
+			return false
+		}
+		tried++
+		for i, index := range indexes {
+			subset[i] = valid[index]
+		}
+		preimage := gf256Interpolate(subset, 0)
+		if NewPreimageSha256(preimage).Condition().Equals(cond) {
+			found = preimage
+			return false
+		}
+		return true
+	})
+	if found == nil && tried == maxShareCombinations {
+		return nil, bad, errors.Errorf(
+			"none of the first %d combinations of the shares reproduces "+
+				"the condition", maxShareCombinations)
+	}
+	if found == nil {
+		return nil, bad, errors.New(
+			"no combination of the shares reproduces the condition")
+	}
+
+	for _, share := range valid {
+		expected := gf256Interpolate(subset, share.Index)
+		if string(expected) != string(share.Value) {
+			bad = append(bad, share.Index)
+		}
+	}
+	return NewPreimageSha256(found), bad, nil
+}
+
+// majorityThreshold returns the threshold that most of the shares have, the
+// lowest one in case of a tie.
+func majorityThreshold(shares []PreimageShare) byte {
+	counts := make(map[byte]int)
+	var threshold byte
+	for _, share := range shares {
+		counts[share.Threshold]++
+		count, best := counts[share.Threshold], counts[threshold]
+		if count > best || (count == best && share.Threshold < threshold) {
+			threshold = share.Threshold
+		}
+	}
+	return threshold
+}
+
+// forEachCombination calls fn with every combination of k of the indexes
+// 0 to n-1, until fn returns false.
+func forEachCombination(n, k int, fn func(indexes []int) bool) {
+	indexes := make([]int, k)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	for {
+		if !fn(indexes) {
+			return
+		}
+		// Advance to the next combination in lexicographic order.
+		i := k - 1
+		for i >= 0 && indexes[i] == n-k+i {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+		indexes[i]++
+		for j := i + 1; j < k; j++ {
+			indexes[j] = indexes[j-1] + 1
+		}
+	}
+}
+
+// gf256Interpolate evaluates the polynomials through the shares at x.
+func gf256Interpolate(shares []PreimageShare, x byte) []byte {
+	result := make([]byte, len(shares[0].Value))
+	for i, share := range shares {
+		// The Lagrange basis polynomial of the share, evaluated at x.
+		basis := byte(1)
+		for j, other := range shares {
+			if i == j {
+				continue
+			}
+			basis = gf256Mul(basis,
+				gf256Div(x^other.Index, share.Index^other.Index))
+		}
+		for b, y := range share.Value {
+			result[b] ^= gf256Mul(basis, y)
+		}
+	}
+	return result
+}
+
+// gf256EvaluatePolynomial evaluates the polynomial with the given
+// coefficients, constant term first, at x.
+func gf256EvaluatePolynomial(coefficients []byte, x byte) byte {
+	var value byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		value = gf256Mul(value, x) ^ coefficients[i]
+	}
+	return value
+}
+
+// gf256Exp and gf256Log are the exponential and logarithm tables of GF(256)
+// with the reduction polynomial x^8 + x^4 + x^3 + x + 1 and generator 3.
+var gf256Exp, gf256Log = gf256Tables()
+
+func gf256Tables() (exp [510]byte, log [256]byte) {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		exp[i+255] = x
+		log[x] = byte(i)
+		// Multiply x by the generator 3 = x + 1.
+		high := x & 0x80
+		doubled := x << 1
+		if high != 0 {
+			doubled ^= 0x1b
+		}
+		x ^= doubled
+	}
+	return exp, log
+}
+
+func gf256Mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gf256Exp[int(gf256Log[a])+int(gf256Log[b])]
+}
+
+func gf256Div(a, b byte) byte {
+	if b == 0 {
+		panic("division by zero in GF(256)")
+	}
+	if a == 0 {
+		return 0
+	}
+	return gf256Exp[int(gf256Log[a])+255-int(gf256Log[b])]
+}
@@ -0,0 +1,94 @@
+package cryptoconditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGF256(t *testing.T) {
+	// Known products in the AES field.
+	assert.Equal(t, byte(0xc1), gf256Mul(0x57, 0x83))
+	assert.Equal(t, byte(0xfe), gf256Mul(0x57, 0x13))
+	for a := 1; a < 256; a++ {
+		assert.Equal(t, byte(1), gf256Mul(byte(a), gf256Div(1, byte(a))))
+	}
+}
+
+func TestSharedPreimage(t *testing.T) {
+	cond, shares, err := NewSharedPreimage(32, 3, 5)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+	assert.Equal(t, CTPreimageSha256, cond.Type())
+	assert.Equal(t, 32, cond.Cost())
+
+	// Any three shares reconstruct the preimage.
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}} {
+		var selected []PreimageShare
+		for _, i := range subset {
+			selected = append(selected, shares[i])
+		}
+		ff, bad, err := CombinePreimageShares(cond, selected)
+		require.NoError(t, err)
+		assert.Empty(t, bad)
+		assert.NoError(t, ff.Validate(cond, nil))
+	}
+
+	// Two shares are not enough.
+	_, _, err = CombinePreimageShares(cond, shares[:2])
+	assert.EqualError(t, err, "need 3 valid shares, got 2")
+
+	// Corrupted shares are identified if enough good shares remain.
+	corrupted := make([]PreimageShare, len(shares))
+	copy(corrupted, shares)
+	corrupted[1].Value = append([]byte{}, shares[1].Value...)
+	corrupted[1].Value[7] ^= 0xff
+	corrupted[3].Value = shares[3].Value[:31]
+	ff, bad, err := CombinePreimageShares(cond, corrupted)
+	require.NoError(t, err)
+	assert.NoError(t, ff.Validate(cond, nil))
+	assert.ElementsMatch(t, []byte{2, 4}, bad)
+
+	_, bad, err = CombinePreimageShares(cond, []PreimageShare{shares[0], corrupted[1], shares[2]})
+	assert.EqualError(t, err, "no combination of the shares reproduces the condition")
+	assert.Empty(t, bad)
+}
+
+func TestCombinePreimageShares_badShares(t *testing.T) {
+	cond, shares, err := NewSharedPreimage(32, 3, 5)
+	require.NoError(t, err)
+
+	// A first share with another threshold does not impose it.
+	badFirst := make([]PreimageShare, len(shares))
+	copy(badFirst, shares)
+	badFirst[0].Threshold = 4
+	ff, bad, err := CombinePreimageShares(cond, badFirst)
+	require.NoError(t, err)
+	assert.NoError(t, ff.Validate(cond, nil))
+	assert.Equal(t, []byte{1}, bad)
+
+	// The number of combinations tried is bounded.
+	cond, shares, err = NewSharedPreimage(16, 10, 40)
+	require.NoError(t, err)
+	shares[0].Value[0] ^= 0xff
+	shares[1].Value[1] ^= 0xff
+	_, _, err = CombinePreimageShares(cond, shares)
+	assert.EqualError(t, err, "none of the first 1000 combinations of the "+
+		"shares reproduces the condition")
+	_, bad, err = CombinePreimageShares(cond, shares[2:])
+	require.NoError(t, err)
+	assert.Empty(t, bad)
+}
+
+func TestSplitPreimage(t *testing.T) {
+	preimage := []byte("attack at dawn")
+	shares, err := SplitPreimage(preimage, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, preimage, shares[1].Value)
+
+	_, err = SplitPreimage(preimage, 3, 2)
+	assert.Error(t, err)
+	_, err = SplitPreimage(preimage, 2, 256)
+	assert.Error(t, err)
+}
@@ -0,0 +1,349 @@
+package cryptoconditions
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// SignEd25519 signs the message with the given signer and returns the
+// resulting ED25519-SHA-256 fulfillment.
+// The signer's public key must be an Ed25519 public key. Signers that are
+// backed by a hardware module or a key management service can be used as
+// long as they produce pure Ed25519 signatures over the full message.
+func SignEd25519(signer crypto.Signer, message []byte) (*FfEd25519Sha256, error) {
+	pubkey, err := signerEd25519PublicKey(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signer.Sign(rand.Reader, message, crypto.Hash(0))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign message")
+	}
+	if !ed25519.Verify(pubkey, message, signature) {
+		return nil, errors.New("signer produced an invalid Ed25519 signature")
+	}
+
+	return NewEd25519Sha256(pubkey, signature)
+}
+
+// SignRsaSha256 signs the message with the given signer and returns the
+// resulting RSA-SHA-256 fulfillment.
+// The signer's public key must be an RSA public key that satisfies the
+// constraints of RSA-SHA-256: a public exponent of 65537 and a modulus of
+// 128 to 512 bytes. The signature is an RSASSA-PSS signature using SHA-256
+// and a salt length of 32 bytes.
+func SignRsaSha256(signer crypto.Signer, message []byte) (*FfRsaSha256, error) {
+	pubkey, err := signerRsaPublicKey(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	hashed := sha256.Sum256(message)
+	signature, err := signer.Sign(rand.Reader, hashed[:], ffRsaSha256PssOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign message")
+	}
+	if err := rsa.VerifyPSS(pubkey, crypto.SHA256, hashed[:], signature,
+		ffRsaSha256PssOpts); err != nil {
+		return nil, errors.Wrap(err, "signer produced an invalid RSA signature")
+	}
+
+	return NewRsaSha256(pubkey.N.Bytes(), signature)
+}
+
+// SignSecp256k1 signs the message with the given signer and returns the
+// resulting experimental SECP256K1-SHA-256 fulfillment.
+// The signer's public key must be a *secp256k1.PublicKey. The signer is
+// given the SHA-256 digest of the message and must return either an r || s
+// encoded signature, like Secp256k1Signer does, or an ASN.1 DER encoded
+// ECDSA signature, as hardware modules and key management services do.
+// Signatures with a high s value are normalized.
+func SignSecp256k1(signer crypto.Signer, message []byte) (*FfSecp256k1Sha256, error) {
+	pubkey, ok := signer.Public().(*secp256k1.PublicKey)
+	if !ok {
+		return nil, errors.Errorf(
+			"signer has no secp256k1 public key, but %T", signer.Public())
+	}
+
+	hashed := sha256.Sum256(message)
+	signature, err := signer.Sign(rand.Reader, hashed[:], crypto.SHA256)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign message")
+	}
+	var sig *secp256k1ecdsa.Signature
+	if len(signature) == Secp256k1SignatureSize {
+		var r, s secp256k1.ModNScalar
+		r.SetByteSlice(signature[:32])
+		s.SetByteSlice(signature[32:])
+		sig = secp256k1ecdsa.NewSignature(&r, &s)
+	} else if sig, err = secp256k1ecdsa.ParseDERSignature(signature); err != nil {
+		return nil, errors.Wrap(err, "signer produced a malformed secp256k1 signature")
+	}
+	if !sig.Verify(hashed[:], pubkey) {
+		return nil, errors.New("signer produced an invalid secp256k1 signature")
+	}
+
+	r, s := sig.R(), sig.S()
+	if s.IsOverHalfOrder() {
+		s.Negate()
+	}
+	encoded := make([]byte, Secp256k1SignatureSize)
+	r.PutBytesUnchecked(encoded[:32])
+	s.PutBytesUnchecked(encoded[32:])
+	return NewSecp256k1Sha256(pubkey.SerializeCompressed(), encoded)
+}
+
+// SignEcdsaP256 signs the message with the given signer and returns the
+// resulting experimental ECDSA-P256-SHA-256 fulfillment.
+// The signer's public key must be an *ecdsa.PublicKey on the P-256 curve.
+// The signer is given the SHA-256 digest of the message and must return an
+// ASN.1 DER encoded ECDSA signature, as *ecdsa.PrivateKey and signers backed
+// by hardware modules do. Signatures with a high s value are normalized.
+func SignEcdsaP256(signer crypto.Signer, message []byte) (*FfEcdsaP256Sha256, error) {
+	pubkey, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf(
+			"signer has no ECDSA public key, but %T", signer.Public())
+	}
+	encodedKey, err := marshalEcdsaP256PublicKey(pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	hashed := sha256.Sum256(message)
+	der, err := signer.Sign(rand.Reader, hashed[:], crypto.SHA256)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign message")
+	}
+	r, s, err := decodeDERSignature(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "signer produced a malformed ECDSA signature")
+	}
+	if !ecdsa.Verify(pubkey, hashed[:], r, s) {
+		return nil, errors.New("signer produced an invalid ECDSA signature")
+	}
+
+	return NewEcdsaP256Sha256(encodedKey, encodeEcdsaP256Signature(r, s))
+}
+
+// SignWots signs the message with the given signer and returns the
+// resulting experimental WOTS-SHA-256 fulfillment.
+// The signer's public key must be a WotsPublicKey, like that of
+// WotsPrivateKey, and the signer must refuse to sign more than one message.
+//
+// Two signatures with the same one-time key allow forgeries. A WotsPrivateKey
+// only refuses to sign again through the same value: SignWots cannot detect
+// that a copy of the key, such as one restored with ParseWotsPrivateKey, has
+// already signed. Callers that store keys must record their use durably
+// before signing.
+func SignWots(signer crypto.Signer, message []byte) (*FfWotsSha256, error) {
+	pubkey, ok := signer.Public().(WotsPublicKey)
+	if !ok {
+		return nil, errors.Errorf(
+			"signer has no one-time public key, but %T", signer.Public())
+	}
+
+	signature, err := signer.Sign(rand.Reader, message, crypto.Hash(0))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign message")
+	}
+	if err := verifyWots(pubkey.Seed, pubkey.Hash, message, signature); err != nil {
+		return nil, errors.Wrap(err, "signer produced an invalid one-time signature")
+	}
+
+	return NewWotsSha256(pubkey.Seed, pubkey.Hash, signature)
+}
+
+// signerEd25519PublicKey returns the public key of an Ed25519 signer.
+func signerEd25519PublicKey(signer crypto.Signer) (ed25519.PublicKey, error) {
+	pubkey, ok := signer.Public().(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.Errorf(
+			"signer has no Ed25519 public key, but %T", signer.Public())
+	}
+	if len(pubkey) != ed25519.PublicKeySize {
+		return nil, errors.Errorf(
+			"wrong pubkey size (%d)", len(pubkey))
+	}
+	return pubkey, nil
+}
+
+// signerRsaPublicKey returns the public key of an RSA signer and checks that
+// it can be used for RSA-SHA-256 fulfillments.
+func signerRsaPublicKey(signer crypto.Signer) (*rsa.PublicKey, error) {
+	pubkey, ok := signer.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf(
+			"signer has no RSA public key, but %T", signer.Public())
+	}
+	if err := checkRsaPublicKey(pubkey); err != nil {
+		return nil, err
+	}
+	return pubkey, nil
+}
+
+// checkRsaPublicKey checks that the RSA public key can be used for RSA-SHA-256
+// conditions.
+func checkRsaPublicKey(pubkey *rsa.PublicKey) error {
+	if pubkey == nil || pubkey.N == nil {
+		return errors.New("missing RSA public key")
+	}
+	if pubkey.E != ffRsaSha256PublicExponent {
+		return errors.Errorf(
+			"public exponent must be %d, not %d",
+			ffRsaSha256PublicExponent, pubkey.E)
+	}
+	modulusLength := len(pubkey.N.Bytes())
+	if modulusLength < ffRsaSha256MinimumModulusLength {
+		return errors.Errorf("modulus is too small (%d bytes)", modulusLength)
+	}
+	if modulusLength > ffRsaSha256MaximumModulusLength {
+		return errors.Errorf("modulus is too large (%d bytes)", modulusLength)
+	}
+	return nil
+}
+
+// MissingLeaf describes a leaf of a template that could not be fulfilled.
+type MissingLeaf struct {
+	// Path is the path of the leaf in the template.
+	Path NodePath
+	// Template is the leaf template.
+	Template Template
+	// Reason explains why the leaf could not be fulfilled.
+	Reason string
+}
+
+// Sign fulfills the template for the given message.
+// It walks the template, prepends the prefixes of PREFIX-SHA-256 nodes to
+// the message, signs every ED25519-SHA-256 and RSA-SHA-256 leaf whose key is
+// in the keyring and fills in every PREIMAGE-SHA-256 leaf whose preimage is in
+// the preimage store. The preimage store can be nil.
+// It returns the fulfillment together with the leaves that could not be
+// fulfilled. If the template cannot be fulfilled, the error is
+// ErrUnsatisfied and the missing leaves explain why.
+func Sign(t Template, message []byte, keyring Keyring, preimages PreimageStore) (Fulfillment, []MissingLeaf, error) {
+	partial := NewPartialFulfillment(t)
+	missing, err := partial.Sign(message, keyring, preimages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ff, err := partial.Fulfillment()
+	if err != nil {
+		return nil, missing, err
+	}
+	return ff, missing, nil
+}
+
+// leafMessageFunc is the type of the function called by walkLeafMessages for
+// every leaf of a template. The message is the message the leaf has to sign.
+// If the message cannot reach the leaf because it is too long for one of the
+// prefixes above it, unreachable explains why.
+type leafMessageFunc func(path NodePath, leaf Template, message []byte, unreachable string) error
+
+// walkLeafMessages walks the leaves of the template and calls fn with the
+// message that each leaf receives when the template is validated with the
+// given message.
+func walkLeafMessages(t Template, message []byte, fn leafMessageFunc) error {
+	// The messages that each node of the template receives.
+	messages := map[string][]byte{"/": message}
+	return WalkTemplate(t, func(path NodePath, node Template) error {
+		msg := messages[path.String()]
+
+		switch tmpl := derefTemplate(node).(type) {
+		case PrefixSha256Template:
+			if len(msg) > int(tmpl.MaxMessageLength) {
+				reason := fmt.Sprintf(
+					"message length of %d exceeds limit of %d at %s",
+					len(msg), tmpl.MaxMessageLength, path)
+				err := walkTemplate(tmpl.SubTemplate, path.child(0), func(subPath NodePath, sub Template) error {
+					if len(sub.subTemplates()) == 0 {
+						return fn(subPath, sub, nil, reason)
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				return SkipChildren
+			}
+			prefixed := make([]byte, 0, len(tmpl.Prefix)+len(msg))
+			prefixed = append(prefixed, tmpl.Prefix...)
+			prefixed = append(prefixed, msg...)
+			messages[path.child(0).String()] = prefixed
+
+		case ThresholdSha256Template:
+			for i := range tmpl.SubTemplates {
+				messages[path.child(i).String()] = msg
+			}
+
+		default:
+			return fn(path, node, msg, "")
+		}
+		return nil
+	})
+}
+
+// signLeaf fulfills a single leaf template for the given message.
+// If the leaf cannot be fulfilled, the returned fulfillment is nil and the
+// reason is given.
+func signLeaf(leaf Template, message []byte, keyring Keyring, preimages PreimageStore) (Fulfillment, string, error) {
+	if _, ok := derefTemplate(leaf).(ConditionTemplate); ok {
+		return nil, "only the condition is known", nil
+	}
+	cond := leaf.Condition()
+
+	switch leaf.ConditionType() {
+	case CTPreimageSha256:
+		if preimages == nil {
+			return nil, "no preimage store", nil
+		}
+		preimage, found, err := preimages.Preimage(cond)
+		if err != nil || !found {
+			return nil, "preimage not found", err
+		}
+		ff, err := derefTemplate(leaf).(PreimageSha256Template).Fulfill(preimage)
+		if err != nil {
+			return nil, "", err
+		}
+		return ff, "", nil
+	}
+
+	if keyring == nil {
+		return nil, "no keyring", nil
+	}
+	signer, err := keyring.Signer(cond)
+	if err != nil || signer == nil {
+		return nil, "key not found", err
+	}
+
+	var ff Fulfillment
+	switch leaf.ConditionType() {
+	case CTEd25519Sha256:
+		ff, err = SignEd25519(signer, message)
+	case CTRsaSha256:
+		ff, err = SignRsaSha256(signer, message)
+	case CTSecp256k1Sha256:
+		ff, err = SignSecp256k1(signer, message)
+	case CTEcdsaP256Sha256:
+		ff, err = SignEcdsaP256(signer, message)
+	case CTWotsSha256:
+		ff, err = SignWots(signer, message)
+	default:
+		return nil, "unsupported leaf type " + leaf.ConditionType().String(), nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return ff, "", nil
+}
@@ -0,0 +1,168 @@
+package cryptoconditions
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// testOpaqueSigner hides the concrete type of a signer, like signers that are
+// backed by a key management service do.
+type testOpaqueSigner struct {
+	signer crypto.Signer
+}
+
+func (s testOpaqueSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s testOpaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.signer.Sign(rand, digest, opts)
+}
+
+// testPublicOnlySigner is a signer that only exposes a public key.
+type testPublicOnlySigner struct {
+	public crypto.PublicKey
+}
+
+func (s testPublicOnlySigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s testPublicOnlySigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, errors.New("cannot sign")
+}
+
+func TestSignEd25519(t *testing.T) {
+	_, privkey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	message := []byte("hello")
+
+	ff, err := SignEd25519(testOpaqueSigner{privkey}, message)
+	require.NoError(t, err)
+	assert.NoError(t, ff.Validate(ff.Condition(), message))
+	assert.Error(t, ff.Validate(ff.Condition(), []byte("other")))
+
+	// Signers with other key types are rejected.
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = SignEd25519(ecKey, message)
+	assert.Error(t, err)
+}
+
+func TestSignRsaSha256(t *testing.T) {
+	privkey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	message := []byte("hello")
+
+	ff, err := SignRsaSha256(testOpaqueSigner{privkey}, message)
+	require.NoError(t, err)
+	assert.Len(t, ff.Signature, len(ff.Modulus))
+	assert.NoError(t, ff.Validate(ff.Condition(), message))
+	assert.Error(t, ff.Validate(ff.Condition(), []byte("other")))
+
+	// Keys that violate the RSA-SHA-256 constraints are rejected.
+	smallKey := &rsa.PublicKey{N: new(big.Int).Rsh(privkey.N, 8), E: 65537}
+	_, err = SignRsaSha256(testPublicOnlySigner{smallKey}, message)
+	assert.EqualError(t, err, "modulus is too small (127 bytes)")
+
+	wrongExponent := &rsa.PublicKey{N: privkey.N, E: 3}
+	_, err = SignRsaSha256(testPublicOnlySigner{wrongExponent}, message)
+	assert.EqualError(t, err, "public exponent must be 65537, not 3")
+
+	_, err = SignRsaSha256(ed25519.NewKeyFromSeed(make([]byte, 32)), message)
+	assert.Error(t, err)
+}
+
+func TestSign(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, bob, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	carol, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	preimage := []byte("secret")
+	hash := sha256.Sum256(preimage)
+	message := []byte("payment 42")
+
+	tmpl, err := AtLeast(3,
+		WithPrefix([]byte("alice:"), 64, Ed25519Key(alice.Public().(ed25519.PublicKey))),
+		WithPrefix([]byte("outer:"), 64,
+			WithPrefix([]byte("inner:"), 64, Ed25519Key(bob.Public().(ed25519.PublicKey)))),
+		RSAKey(&carol.PublicKey),
+		Hashlock(hash[:], len(preimage)),
+	).Template()
+	require.NoError(t, err)
+	cond := tmpl.Condition()
+
+	keyring, err := NewMemoryKeyring(alice, bob)
+	require.NoError(t, err)
+	preimages := NewMemoryPreimageStore(preimage)
+
+	ff, missing, err := Sign(tmpl, message, keyring, preimages)
+	require.NoError(t, err)
+	assert.NoError(t, ff.Validate(cond, message))
+	assert.Error(t, ff.Validate(cond, []byte("payment 43")))
+
+	// The nested prefixes are applied in order.
+	bobFf := ff.(*FfThresholdSha256).SubFulfillments[1].(*FfPrefixSha256).
+		SubFulfillment.(*FfPrefixSha256).SubFulfillment.(*FfEd25519Sha256)
+	assert.True(t, ed25519.Verify(bobFf.Ed25519PublicKey(),
+		[]byte("inner:outer:payment 42"), bobFf.Signature))
+
+	// Carol's key is not in the keyring.
+	require.Len(t, missing, 1)
+	assert.Equal(t, "/2", missing[0].Path.String())
+	assert.Equal(t, CTRsaSha256, missing[0].Template.ConditionType())
+	assert.Equal(t, "key not found", missing[0].Reason)
+
+	// With Carol's key as well, all leaves are signed.
+	_, err = keyring.Add(carol)
+	require.NoError(t, err)
+	ff, missing, err = Sign(tmpl, message, keyring, preimages)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.NoError(t, ff.Validate(cond, message))
+}
+
+func TestSign_unsatisfied(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyring, err := NewMemoryKeyring(alice)
+	require.NoError(t, err)
+	preimage := []byte("secret")
+	hash := sha256.Sum256(preimage)
+
+	tmpl, err := AllOf(
+		WithPrefix([]byte("alice:"), 4, Ed25519Key(alice.Public().(ed25519.PublicKey))),
+		Hashlock(hash[:], len(preimage)),
+	).Template()
+	require.NoError(t, err)
+
+	// The message is too long for the prefix and there is no preimage store.
+	ff, missing, err := Sign(tmpl, []byte("too long"), keyring, nil)
+	assert.Equal(t, ErrUnsatisfied, err)
+	assert.Nil(t, ff)
+	require.Len(t, missing, 2)
+	assert.Equal(t, "/0/0", missing[0].Path.String())
+	assert.Equal(t, "message length of 8 exceeds limit of 4 at /0", missing[0].Reason)
+	assert.Equal(t, "/1", missing[1].Path.String())
+	assert.Equal(t, "no preimage store", missing[1].Reason)
+
+	// A short message and the preimage satisfy the template.
+	ff, missing, err = Sign(tmpl, []byte("ok"), keyring, NewMemoryPreimageStore(preimage))
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.NoError(t, ff.Validate(tmpl.Condition(), []byte("ok")))
+}
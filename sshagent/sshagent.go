@@ -0,0 +1,179 @@
+// Package sshagent provides Ed25519 signers for crypto-conditions that are
+// backed by an ssh-agent, so that keys kept in the agent or in a hardware
+// token that speaks the agent protocol can fulfill ED25519-SHA-256
+// conditions.
+package sshagent
+
+import (
+	"crypto"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/go-interledger/cryptoconditions"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Keyring is a cryptoconditions.Keyring that signs with the Ed25519 keys of
+// an ssh-agent. Keys of other types are ignored.
+// It is safe for concurrent use.
+type Keyring struct {
+	mu     sync.Mutex
+	client agent.Agent
+	conn   io.Closer
+}
+
+// Dial connects to the ssh-agent listening on the given Unix socket. If the
+// socket is empty, the SSH_AUTH_SOCK environment variable is used.
+func Dial(socket string) (*Keyring, error) {
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, errors.New("SSH_AUTH_SOCK is not set")
+		}
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to ssh-agent")
+	}
+	return &Keyring{
+		client: agent.NewClient(conn),
+		conn:   conn,
+	}, nil
+}
+
+// New creates a keyring for the given agent client.
+func New(client agent.Agent) *Keyring {
+	return &Keyring{client: client}
+}
+
+// Close closes the connection to the agent if it was opened by Dial.
+func (k *Keyring) Close() error {
+	if k.conn == nil {
+		return nil
+	}
+	return k.conn.Close()
+}
+
+// Signers returns a signer for every Ed25519 key of the agent.
+func (k *Keyring) Signers() ([]*Signer, error) {
+	k.mu.Lock()
+	keys, err := k.client.List()
+	k.mu.Unlock()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list ssh-agent keys")
+	}
+
+	var signers []*Signer
+	for _, key := range keys {
+		if key.Type() != ssh.KeyAlgoED25519 {
+			continue
+		}
+		pubkey, err := ssh.ParsePublicKey(key.Marshal())
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid ssh-agent key")
+		}
+		cryptoKey, ok := pubkey.(ssh.CryptoPublicKey)
+		if !ok {
+			continue
+		}
+		edKey, ok := cryptoKey.CryptoPublicKey().(ed25519.PublicKey)
+		if !ok {
+			continue
+		}
+		signers = append(signers, &Signer{
+			keyring: k,
+			key:     pubkey,
+			pubkey:  edKey,
+			comment: key.Comment,
+		})
+	}
+	return signers, nil
+}
+
+// Conditions returns the ED25519-SHA-256 conditions of the Ed25519 keys of
+// the agent.
+func (k *Keyring) Conditions() ([]*cryptoconditions.Condition, error) {
+	signers, err := k.Signers()
+	if err != nil {
+		return nil, err
+	}
+	conditions := make([]*cryptoconditions.Condition, len(signers))
+	for i, signer := range signers {
+		conditions[i] = signer.Condition()
+	}
+	return conditions, nil
+}
+
+// Signer returns the signer for the agent key that produces the given
+// condition, or nil if the agent does not hold that key.
+func (k *Keyring) Signer(cond *cryptoconditions.Condition) (crypto.Signer, error) {
+	if cond.Type() != cryptoconditions.CTEd25519Sha256 {
+		return nil, nil
+	}
+	signers, err := k.Signers()
+	if err != nil {
+		return nil, err
+	}
+	for _, signer := range signers {
+		if signer.Condition().Equals(cond) {
+			return signer, nil
+		}
+	}
+	return nil, nil
+}
+
+// Signer is a crypto.Signer for an Ed25519 key held by an ssh-agent.
+// It can be used with cryptoconditions.SignEd25519.
+type Signer struct {
+	keyring *Keyring
+	key     ssh.PublicKey
+	pubkey  ed25519.PublicKey
+	comment string
+}
+
+// Public returns the ed25519.PublicKey of the signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.pubkey
+}
+
+// Comment returns the comment of the key in the agent.
+func (s *Signer) Comment() string {
+	return s.comment
+}
+
+// Condition returns the ED25519-SHA-256 condition of the key.
+func (s *Signer) Condition() *cryptoconditions.Condition {
+	return cryptoconditions.Ed25519Sha256Template{PublicKey: s.pubkey}.Condition()
+}
+
+// Sign asks the agent to sign the message. Like ed25519.PrivateKey, it only
+// supports signing full messages, so opts.HashFunc() must be zero.
+func (s *Signer) Sign(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.Hash(0) {
+		return nil, errors.New("ed25519: cannot sign hashed message")
+	}
+
+	s.keyring.mu.Lock()
+	signature, err := s.keyring.client.Sign(s.key, message)
+	s.keyring.mu.Unlock()
+	if err != nil {
+		return nil, errors.Wrap(err, "ssh-agent failed to sign")
+	}
+	if signature.Format != ssh.KeyAlgoED25519 ||
+		len(signature.Blob) != ed25519.SignatureSize {
+		return nil, errors.Errorf(
+			"ssh-agent returned an unexpected %s signature", signature.Format)
+	}
+	return signature.Blob, nil
+}
+
+// SignFulfillment signs the message with the agent key and returns the
+// resulting ED25519-SHA-256 fulfillment.
+func (s *Signer) SignFulfillment(message []byte) (*cryptoconditions.FfEd25519Sha256, error) {
+	return cryptoconditions.SignEd25519(s, message)
+}
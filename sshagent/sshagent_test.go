@@ -0,0 +1,96 @@
+package sshagent
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-interledger/cryptoconditions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// serveTestAgent serves an in-process agent holding the given keys on a
+// temporary Unix socket and returns the path of the socket.
+func serveTestAgent(t *testing.T, keys ...interface{}) string {
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: key, Comment: "test"}))
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+	return socket
+}
+
+func TestKeyring(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, bob, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	keyring, err := Dial(serveTestAgent(t, alice, rsaKey))
+	require.NoError(t, err)
+	defer keyring.Close()
+
+	// Only the Ed25519 key is listed.
+	signers, err := keyring.Signers()
+	require.NoError(t, err)
+	require.Len(t, signers, 1)
+	assert.Equal(t, alice.Public(), signers[0].Public())
+	assert.Equal(t, "test", signers[0].Comment())
+
+	aliceTmpl, err := cryptoconditions.NewEd25519Sha256Template(alice.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	conditions, err := keyring.Conditions()
+	require.NoError(t, err)
+	require.Len(t, conditions, 1)
+	assert.True(t, conditions[0].Equals(aliceTmpl.Condition()))
+
+	message := []byte("hello")
+	ff, err := signers[0].SignFulfillment(message)
+	require.NoError(t, err)
+	assert.NoError(t, ff.Validate(aliceTmpl.Condition(), message))
+
+	// The keyring can be used to sign templates.
+	tmpl, err := cryptoconditions.AnyOf(
+		cryptoconditions.Ed25519Key(bob.Public().(ed25519.PublicKey)),
+		cryptoconditions.WithPrefix([]byte("alice:"), 16,
+			cryptoconditions.Ed25519Key(alice.Public().(ed25519.PublicKey))),
+	).Template()
+	require.NoError(t, err)
+	ffTree, missing, err := cryptoconditions.Sign(tmpl, message, keyring, nil)
+	require.NoError(t, err)
+	require.Len(t, missing, 1)
+	assert.Equal(t, "/0", missing[0].Path.String())
+	assert.NoError(t, ffTree.Validate(tmpl.Condition(), message))
+}
+
+func TestDial_noAgent(t *testing.T) {
+	_, err := Dial(filepath.Join(t.TempDir(), "missing.sock"))
+	assert.Error(t, err)
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	_, err = Dial("")
+	assert.EqualError(t, err, "SSH_AUTH_SOCK is not set")
+}
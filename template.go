@@ -0,0 +1,598 @@
+package cryptoconditions
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Template describes a condition tree by public data only: public keys,
+// moduli and preimage hashes. A template is used to derive the condition
+// before any signatures or preimages are known and is turned into a
+// fulfillment with FulfillTemplate once they are.
+type Template interface {
+	// ConditionType returns the type of the condition this template describes.
+	ConditionType() ConditionType
+
+	// Condition generates the condition this template describes.
+	Condition() *Condition
+
+	// subTemplates returns the children of compound templates.
+	subTemplates() []Template
+}
+
+// ErrUnsatisfied is returned by FulfillTemplate when not enough leaves could
+// be fulfilled to fulfill the template.
+var ErrUnsatisfied = errors.New("not enough leaves are fulfilled to fulfill the template")
+
+// Ed25519Sha256Template describes an ED25519-SHA-256 condition.
+type Ed25519Sha256Template struct {
+	PublicKey ed25519.PublicKey
+}
+
+// NewEd25519Sha256Template creates a new ED25519-SHA-256 template.
+func NewEd25519Sha256Template(pubkey ed25519.PublicKey) (*Ed25519Sha256Template, error) {
+	if len(pubkey) != ed25519.PublicKeySize {
+		return nil, errors.Errorf(
+			"wrong pubkey size (%d)", len(pubkey))
+	}
+	return &Ed25519Sha256Template{
+		PublicKey: pubkey,
+	}, nil
+}
+
+func (t Ed25519Sha256Template) ConditionType() ConditionType {
+	return CTEd25519Sha256
+}
+
+func (t Ed25519Sha256Template) Condition() *Condition {
+	return FfEd25519Sha256{PublicKey: t.PublicKey}.Condition()
+}
+
+func (t Ed25519Sha256Template) subTemplates() []Template {
+	return nil
+}
+
+// Fulfill creates the fulfillment for this template with the given signature.
+func (t Ed25519Sha256Template) Fulfill(signature []byte) (*FfEd25519Sha256, error) {
+	return NewEd25519Sha256(t.PublicKey, signature)
+}
+
+// Secp256k1Sha256Template describes an experimental SECP256K1-SHA-256
+// condition by the compressed public key.
+type Secp256k1Sha256Template struct {
+	PublicKey []byte
+}
+
+// NewSecp256k1Sha256Template creates a new SECP256K1-SHA-256 template.
+func NewSecp256k1Sha256Template(pubkey []byte) (*Secp256k1Sha256Template, error) {
+	if _, err := parseSecp256k1PublicKey(pubkey); err != nil {
+		return nil, err
+	}
+	return &Secp256k1Sha256Template{
+		PublicKey: pubkey,
+	}, nil
+}
+
+func (t Secp256k1Sha256Template) ConditionType() ConditionType {
+	return CTSecp256k1Sha256
+}
+
+func (t Secp256k1Sha256Template) Condition() *Condition {
+	return FfSecp256k1Sha256{PublicKey: t.PublicKey}.Condition()
+}
+
+func (t Secp256k1Sha256Template) subTemplates() []Template {
+	return nil
+}
+
+// Fulfill creates the fulfillment for this template with the given signature.
+func (t Secp256k1Sha256Template) Fulfill(signature []byte) (*FfSecp256k1Sha256, error) {
+	return NewSecp256k1Sha256(t.PublicKey, signature)
+}
+
+// EcdsaP256Sha256Template describes an experimental ECDSA-P256-SHA-256
+// condition by the compressed public key.
+type EcdsaP256Sha256Template struct {
+	PublicKey []byte
+}
+
+// NewEcdsaP256Sha256Template creates a new ECDSA-P256-SHA-256 template.
+func NewEcdsaP256Sha256Template(pubkey []byte) (*EcdsaP256Sha256Template, error) {
+	if _, err := parseEcdsaP256PublicKey(pubkey); err != nil {
+		return nil, err
+	}
+	return &EcdsaP256Sha256Template{
+		PublicKey: pubkey,
+	}, nil
+}
+
+func (t EcdsaP256Sha256Template) ConditionType() ConditionType {
+	return CTEcdsaP256Sha256
+}
+
+func (t EcdsaP256Sha256Template) Condition() *Condition {
+	return FfEcdsaP256Sha256{PublicKey: t.PublicKey}.Condition()
+}
+
+func (t EcdsaP256Sha256Template) subTemplates() []Template {
+	return nil
+}
+
+// Fulfill creates the fulfillment for this template with the given signature.
+func (t EcdsaP256Sha256Template) Fulfill(signature []byte) (*FfEcdsaP256Sha256, error) {
+	return NewEcdsaP256Sha256(t.PublicKey, signature)
+}
+
+// WebAuthnSha256Template describes an experimental WEBAUTHN-SHA-256
+// condition by the compressed credential public key and the hash of the
+// relying party ID.
+type WebAuthnSha256Template struct {
+	PublicKey []byte
+	RpIdHash  []byte
+}
+
+// NewWebAuthnSha256Template creates a new WEBAUTHN-SHA-256 template.
+func NewWebAuthnSha256Template(pubkey, rpIdHash []byte) (*WebAuthnSha256Template, error) {
+	if err := checkWebAuthnCondition(pubkey, rpIdHash); err != nil {
+		return nil, err
+	}
+	return &WebAuthnSha256Template{
+		PublicKey: pubkey,
+		RpIdHash:  rpIdHash,
+	}, nil
+}
+
+func (t WebAuthnSha256Template) ConditionType() ConditionType {
+	return CTWebAuthnSha256
+}
+
+func (t WebAuthnSha256Template) Condition() *Condition {
+	return FfWebAuthnSha256{PublicKey: t.PublicKey, RpIdHash: t.RpIdHash}.Condition()
+}
+
+func (t WebAuthnSha256Template) subTemplates() []Template {
+	return nil
+}
+
+// Fulfill creates the fulfillment for this template from the parts of an
+// assertion. The signature must be encoded as r || s.
+func (t WebAuthnSha256Template) Fulfill(authenticatorData, clientDataJSON, signature []byte) (*FfWebAuthnSha256, error) {
+	return NewWebAuthnSha256(t.PublicKey, t.RpIdHash,
+		authenticatorData, clientDataJSON, signature)
+}
+
+// FulfillAssertion creates the fulfillment for this template from an
+// assertion as returned by an authenticator, with an ASN.1 DER encoded
+// signature.
+func (t WebAuthnSha256Template) FulfillAssertion(authenticatorData, clientDataJSON, derSignature []byte) (*FfWebAuthnSha256, error) {
+	r, s, err := decodeDERSignature(derSignature)
+	if err != nil {
+		return nil, err
+	}
+	return t.Fulfill(authenticatorData, clientDataJSON, encodeEcdsaP256Signature(r, s))
+}
+
+// WotsSha256Template describes an experimental WOTS-SHA-256 condition by
+// the one-time public key.
+type WotsSha256Template struct {
+	PublicKey WotsPublicKey
+}
+
+// NewWotsSha256Template creates a new WOTS-SHA-256 template.
+func NewWotsSha256Template(pubkey WotsPublicKey) (*WotsSha256Template, error) {
+	if err := checkWotsPublicKey(pubkey.Seed, pubkey.Hash); err != nil {
+		return nil, err
+	}
+	return &WotsSha256Template{
+		PublicKey: pubkey,
+	}, nil
+}
+
+func (t WotsSha256Template) ConditionType() ConditionType {
+	return CTWotsSha256
+}
+
+func (t WotsSha256Template) Condition() *Condition {
+	return FfWotsSha256{
+		PublicSeed:    t.PublicKey.Seed,
+		PublicKeyHash: t.PublicKey.Hash,
+	}.Condition()
+}
+
+func (t WotsSha256Template) subTemplates() []Template {
+	return nil
+}
+
+// Fulfill creates the fulfillment for this template with the given signature.
+func (t WotsSha256Template) Fulfill(signature []byte) (*FfWotsSha256, error) {
+	return NewWotsSha256(t.PublicKey.Seed, t.PublicKey.Hash, signature)
+}
+
+// RsaSha256Template describes an RSA-SHA-256 condition.
+type RsaSha256Template struct {
+	Modulus []byte
+}
+
+// NewRsaSha256Template creates a new RSA-SHA-256 template.
+func NewRsaSha256Template(modulus []byte) (*RsaSha256Template, error) {
+	if _, err := NewRsaSha256(modulus, nil); err != nil {
+		return nil, err
+	}
+	return &RsaSha256Template{
+		Modulus: modulus,
+	}, nil
+}
+
+// PublicKey returns the RSA public key.
+func (t RsaSha256Template) PublicKey() *rsa.PublicKey {
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(t.Modulus),
+		E: ffRsaSha256PublicExponent,
+	}
+}
+
+func (t RsaSha256Template) ConditionType() ConditionType {
+	return CTRsaSha256
+}
+
+func (t RsaSha256Template) Condition() *Condition {
+	return FfRsaSha256{Modulus: t.Modulus}.Condition()
+}
+
+func (t RsaSha256Template) subTemplates() []Template {
+	return nil
+}
+
+// Fulfill creates the fulfillment for this template with the given signature.
+func (t RsaSha256Template) Fulfill(signature []byte) (*FfRsaSha256, error) {
+	return NewRsaSha256(t.Modulus, signature)
+}
+
+// PreimageSha256Template describes a PREIMAGE-SHA-256 condition by the hash
+// and the size of the preimage.
+type PreimageSha256Template struct {
+	Hash []byte
+	Size int
+}
+
+// NewPreimageSha256Template creates a new PREIMAGE-SHA-256 template.
+func NewPreimageSha256Template(hash []byte, size int) (*PreimageSha256Template, error) {
+	if len(hash) != sha256.Size {
+		return nil, errors.Errorf("wrong hash size (%d)", len(hash))
+	}
+	if size < 0 {
+		return nil, errors.Errorf("negative preimage size (%d)", size)
+	}
+	return &PreimageSha256Template{
+		Hash: hash,
+		Size: size,
+	}, nil
+}
+
+func (t PreimageSha256Template) ConditionType() ConditionType {
+	return CTPreimageSha256
+}
+
+func (t PreimageSha256Template) Condition() *Condition {
+	return NewSimpleCondition(t.ConditionType(), t.Hash, t.Size)
+}
+
+func (t PreimageSha256Template) subTemplates() []Template {
+	return nil
+}
+
+// Fulfill creates the fulfillment for this template with the given preimage.
+// It fails if the preimage does not match the hash and size of the template.
+func (t PreimageSha256Template) Fulfill(preimage []byte) (*FfPreimageSha256, error) {
+	hash := sha256.Sum256(preimage)
+	if len(preimage) != t.Size || !bytes.Equal(hash[:], t.Hash) {
+		return nil, errors.New("preimage does not match the template")
+	}
+	return NewPreimageSha256(preimage), nil
+}
+
+// ConditionTemplate describes a condition of any type by the condition
+// alone, without the public data needed to fulfill it. It stands for the
+// unfulfilled sub-conditions of a fulfillment and is left unfulfilled by
+// FulfillTemplate.
+type ConditionTemplate struct {
+	Cond *Condition
+}
+
+// NewConditionTemplate creates a new template for the condition. Conditions
+// of unknown types are rejected.
+func NewConditionTemplate(cond *Condition) (*ConditionTemplate, error) {
+	if _, err := castToEncodableCondition(cond); err != nil {
+		return nil, err
+	}
+	return &ConditionTemplate{
+		Cond: cond,
+	}, nil
+}
+
+func (t ConditionTemplate) ConditionType() ConditionType {
+	return t.Cond.Type()
+}
+
+func (t ConditionTemplate) Condition() *Condition {
+	return t.Cond
+}
+
+func (t ConditionTemplate) subTemplates() []Template {
+	return nil
+}
+
+// PrefixSha256Template describes a PREFIX-SHA-256 condition.
+type PrefixSha256Template struct {
+	Prefix           []byte
+	MaxMessageLength uint32
+	SubTemplate      Template
+}
+
+// NewPrefixSha256Template creates a new PREFIX-SHA-256 template.
+func NewPrefixSha256Template(prefix []byte, maxMessageLength uint32, sub Template) (*PrefixSha256Template, error) {
+	if sub == nil {
+		return nil, errors.New("missing sub-template")
+	}
+	return &PrefixSha256Template{
+		Prefix:           prefix,
+		MaxMessageLength: maxMessageLength,
+		SubTemplate:      sub,
+	}, nil
+}
+
+func (t PrefixSha256Template) ConditionType() ConditionType {
+	return CTPrefixSha256
+}
+
+func (t PrefixSha256Template) Condition() *Condition {
+	return NewPrefixSha256Unfulfilled(
+		t.Prefix, t.MaxMessageLength, t.SubTemplate.Condition()).Condition()
+}
+
+func (t PrefixSha256Template) subTemplates() []Template {
+	return []Template{t.SubTemplate}
+}
+
+// ThresholdSha256Template describes a THRESHOLD-SHA-256 condition.
+type ThresholdSha256Template struct {
+	Threshold    uint16
+	SubTemplates []Template
+}
+
+// NewThresholdSha256Template creates a new THRESHOLD-SHA-256 template.
+func NewThresholdSha256Template(threshold uint16, subs []Template) (*ThresholdSha256Template, error) {
+	if threshold == 0 {
+		return nil, errors.New("threshold must be at least 1")
+	}
+	if int(threshold) > len(subs) {
+		return nil, errors.Errorf(
+			"threshold of %d exceeds the number of sub-templates (%d)",
+			threshold, len(subs))
+	}
+	for _, sub := range subs {
+		if sub == nil {
+			return nil, errors.New("missing sub-template")
+		}
+	}
+	return &ThresholdSha256Template{
+		Threshold:    threshold,
+		SubTemplates: subs,
+	}, nil
+}
+
+func (t ThresholdSha256Template) ConditionType() ConditionType {
+	return CTThresholdSha256
+}
+
+func (t ThresholdSha256Template) Condition() *Condition {
+	subConditions := make([]*Condition, len(t.SubTemplates))
+	for i, sub := range t.SubTemplates {
+		subConditions[i] = sub.Condition()
+	}
+	return NewThresholdSha256(t.Threshold, nil, subConditions).Condition()
+}
+
+func (t ThresholdSha256Template) subTemplates() []Template {
+	return t.SubTemplates
+}
+
+// TemplateWalkFunc is the type of the function called by WalkTemplate for
+// every node in the template.
+type TemplateWalkFunc func(path NodePath, t Template) error
+
+// WalkTemplate walks the template tree in depth-first order, calling fn for
+// every node. Paths are formed by the positions of the children in their
+// parent templates.
+// If fn returns SkipChildren, the children of the node are not visited. If fn
+// returns any other error, walking stops and the error is returned.
+func WalkTemplate(t Template, fn TemplateWalkFunc) error {
+	return walkTemplate(t, nil, fn)
+}
+
+func walkTemplate(t Template, path NodePath, fn TemplateWalkFunc) error {
+	if err := fn(path, t); err == SkipChildren {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for i, sub := range t.subTemplates() {
+		if err := walkTemplate(sub, path.child(i), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LeafFulfiller is the type of the function called by FulfillTemplate for the
+// leaves of a template. It returns the fulfillment for the leaf at the given
+// path, or nil if it cannot be fulfilled.
+type LeafFulfiller func(path NodePath, leaf Template) (Fulfillment, error)
+
+// FulfillTemplate builds a fulfillment for the template, using fulfill to
+// obtain the fulfillments of the leaves.
+// Leaves of threshold templates are only requested until the threshold is
+// met; the remaining children are included as sub-conditions. Leaves that
+// are a ConditionTemplate are never requested and always included as
+// sub-conditions.
+// If the template cannot be fulfilled with the available leaves, ErrUnsatisfied
+// is returned.
+func FulfillTemplate(t Template, fulfill LeafFulfiller) (Fulfillment, error) {
+	ff, err := fulfillTemplate(t, nil, fulfill)
+	if err != nil {
+		return nil, err
+	}
+	if ff == nil {
+		return nil, ErrUnsatisfied
+	}
+	return ff, nil
+}
+
+// fulfillTemplate fulfills the template at path and returns nil if it cannot
+// be fulfilled.
+func fulfillTemplate(t Template, path NodePath, fulfill LeafFulfiller) (Fulfillment, error) {
+	switch tmpl := derefTemplate(t).(type) {
+	case PrefixSha256Template:
+		subFf, err := fulfillTemplate(tmpl.SubTemplate, path.child(0), fulfill)
+		if err != nil || subFf == nil {
+			return nil, err
+		}
+		return NewPrefixSha256(tmpl.Prefix, tmpl.MaxMessageLength, subFf), nil
+
+	case ThresholdSha256Template:
+		var subFfs []Fulfillment
+		var subConds []*Condition
+		for i, sub := range tmpl.SubTemplates {
+			if len(subFfs) < int(tmpl.Threshold) {
+				subFf, err := fulfillTemplate(sub, path.child(i), fulfill)
+				if err != nil {
+					return nil, err
+				}
+				if subFf != nil {
+					subFfs = append(subFfs, subFf)
+					continue
+				}
+			}
+			subConds = append(subConds, sub.Condition())
+		}
+		if len(subFfs) < int(tmpl.Threshold) {
+			return nil, nil
+		}
+		return NewThresholdSha256(tmpl.Threshold, subFfs, subConds), nil
+
+	case ConditionTemplate:
+		return nil, nil
+	}
+
+	// Leaf templates.
+	ff, err := fulfill(path, t)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fulfill leaf %s", path)
+	}
+	if ff == nil {
+		return nil, nil
+	}
+	if !ff.Condition().Equals(t.Condition()) {
+		return nil, errors.Errorf(
+			"fulfillment for leaf %s does not match the template", path)
+	}
+	return ff, nil
+}
+
+// TemplateOf returns the template describing the condition of the given
+// fulfillment. Unfulfilled sub-conditions are described by a
+// ConditionTemplate, except for PREIMAGE-SHA-256 conditions, which are
+// described by a PreimageSha256Template that can be fulfilled with the
+// preimage.
+func TemplateOf(ff Fulfillment) (Template, error) {
+	switch f := derefFulfillment(ff).(type) {
+	case FfPreimageSha256:
+		hash := sha256.Sum256(f.Preimage)
+		return &PreimageSha256Template{Hash: hash[:], Size: len(f.Preimage)}, nil
+
+	case FfPrefixSha256:
+		var sub Template
+		var err error
+		if f.IsFulfilled() {
+			sub, err = TemplateOf(f.SubFulfillment)
+		} else {
+			sub, err = templateOfCondition(f.subCondition)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &PrefixSha256Template{
+			Prefix:           f.Prefix,
+			MaxMessageLength: f.MaxMessageLength,
+			SubTemplate:      sub,
+		}, nil
+
+	case FfThresholdSha256:
+		subs := make([]Template, 0, len(f.SubFulfillments)+len(f.SubConditions))
+		for _, sff := range f.SubFulfillments {
+			sub, err := TemplateOf(sff)
+			if err != nil {
+				return nil, err
+			}
+			subs = append(subs, sub)
+		}
+		for _, sc := range f.SubConditions {
+			sub, err := templateOfCondition(sc)
+			if err != nil {
+				return nil, err
+			}
+			subs = append(subs, sub)
+		}
+		return &ThresholdSha256Template{Threshold: f.Threshold, SubTemplates: subs}, nil
+
+	case FfRsaSha256:
+		return &RsaSha256Template{Modulus: f.Modulus}, nil
+
+	case FfEd25519Sha256:
+		return &Ed25519Sha256Template{PublicKey: f.Ed25519PublicKey()}, nil
+
+	case FfSecp256k1Sha256:
+		return &Secp256k1Sha256Template{PublicKey: f.PublicKey}, nil
+
+	case FfEcdsaP256Sha256:
+		return &EcdsaP256Sha256Template{PublicKey: f.PublicKey}, nil
+
+	case FfWebAuthnSha256:
+		return &WebAuthnSha256Template{PublicKey: f.PublicKey, RpIdHash: f.RpIdHash}, nil
+
+	case FfWotsSha256:
+		return &WotsSha256Template{PublicKey: f.WotsPublicKey()}, nil
+	}
+	return nil, errors.Errorf("unknown fulfillment type %T", ff)
+}
+
+// templateOfCondition returns the template for an unfulfilled sub-condition.
+func templateOfCondition(cond *Condition) (Template, error) {
+	if cond != nil && cond.Type() == CTPreimageSha256 {
+		return &PreimageSha256Template{Hash: cond.Fingerprint(), Size: cond.Cost()}, nil
+	}
+	tmpl, err := NewConditionTemplate(cond)
+	if err != nil {
+		return nil, errors.Wrap(err,
+			"cannot derive a template from an unfulfilled sub-condition")
+	}
+	return tmpl, nil
+}
+
+// derefTemplate returns the value form of a template that is passed as a
+// pointer, like derefFulfillment does for fulfillments.
+func derefTemplate(t Template) Template {
+	v := reflect.ValueOf(t)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		if deref, ok := v.Elem().Interface().(Template); ok {
+			return deref
+		}
+	}
+	return t
+}
@@ -0,0 +1,188 @@
+package cryptoconditions
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// testNotarizedReceiptTemplate returns the template of RFC test vector 0016.
+func testNotarizedReceiptTemplate(t *testing.T) Template {
+	ff := testNotarizedReceipt(t)
+	tmpl, err := TemplateOf(ff)
+	require.NoError(t, err)
+	return tmpl
+}
+
+func TestTemplate_Condition(t *testing.T) {
+	ff := testNotarizedReceipt(t)
+	tmpl := testNotarizedReceiptTemplate(t)
+
+	assert.True(t, ff.Condition().Equals(tmpl.Condition()))
+	assert.Equal(t, 134304, tmpl.Condition().Cost())
+
+	// The builder produces the same template.
+	prefixFf := ff.SubFulfillments[0].(*FfPrefixSha256)
+	preimage := ff.SubFulfillments[1].(*FfPreimageSha256).Preimage
+	hash := sha256.Sum256(preimage)
+	built, err := AllOf(
+		WithPrefix(prefixFf.Prefix, 0, Ed25519Key(
+			prefixFf.SubFulfillment.(*FfEd25519Sha256).Ed25519PublicKey())),
+		Hashlock(hash[:], len(preimage)),
+	).Template()
+	require.NoError(t, err)
+	assert.Equal(t, tmpl, built)
+}
+
+func TestFulfillTemplate(t *testing.T) {
+	ff := testNotarizedReceipt(t)
+	edFf := ff.SubFulfillments[0].(*FfPrefixSha256).SubFulfillment.(*FfEd25519Sha256)
+	preimage := ff.SubFulfillments[1].(*FfPreimageSha256).Preimage
+	tmpl := testNotarizedReceiptTemplate(t)
+
+	var requested []string
+	fulfilled, err := FulfillTemplate(tmpl, func(path NodePath, leaf Template) (Fulfillment, error) {
+		requested = append(requested, path.String())
+		switch l := leaf.(type) {
+		case *Ed25519Sha256Template:
+			return l.Fulfill(edFf.Signature)
+		case *PreimageSha256Template:
+			return l.Fulfill(preimage)
+		}
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/0/0", "/1"}, requested)
+	assert.True(t, FulfillmentsEqual(ff, fulfilled))
+	assert.NoError(t, fulfilled.Validate(tmpl.Condition(), nil))
+}
+
+func TestFulfillTemplate_threshold(t *testing.T) {
+	preimages := [][]byte{[]byte("zero"), []byte("one"), []byte("two")}
+	subs := make([]Template, len(preimages))
+	for i, p := range preimages {
+		var err error
+		subs[i], err = TemplateOf(NewPreimageSha256(p))
+		require.NoError(t, err)
+	}
+	tmpl, err := NewThresholdSha256Template(1, subs)
+	require.NoError(t, err)
+
+	// Only the second leaf can be fulfilled, the third is not requested.
+	var requested []string
+	ff, err := FulfillTemplate(tmpl, func(path NodePath, leaf Template) (Fulfillment, error) {
+		requested = append(requested, path.String())
+		if path[0] == 1 {
+			return leaf.(*PreimageSha256Template).Fulfill(preimages[1])
+		}
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/0", "/1"}, requested)
+	threshold := ff.(*FfThresholdSha256)
+	require.Len(t, threshold.SubFulfillments, 1)
+	require.Len(t, threshold.SubConditions, 2)
+	assert.True(t, ff.Condition().Equals(tmpl.Condition()))
+
+	// Nothing can be fulfilled.
+	_, err = FulfillTemplate(tmpl, func(NodePath, Template) (Fulfillment, error) {
+		return nil, nil
+	})
+	assert.Equal(t, ErrUnsatisfied, err)
+
+	// Errors are passed on.
+	_, err = FulfillTemplate(tmpl, func(NodePath, Template) (Fulfillment, error) {
+		return nil, errors.New("boom")
+	})
+	assert.EqualError(t, err, "failed to fulfill leaf /0: boom")
+
+	// Fulfillments for other conditions are rejected.
+	_, err = FulfillTemplate(tmpl, func(NodePath, Template) (Fulfillment, error) {
+		return NewPreimageSha256([]byte("other")), nil
+	})
+	assert.Error(t, err)
+}
+
+func TestTemplateOf_unfulfilledSubConditions(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	bob, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	message := []byte("message")
+
+	aliceFf, err := SignEd25519(alice, message)
+	require.NoError(t, err)
+	bobCond := Ed25519Sha256Template{PublicKey: bob}.Condition()
+	prefixCond := NewPrefixSha256([]byte("prefix"), 16,
+		NewPreimageSha256([]byte("secret"))).Condition()
+	ff := NewThresholdSha256(1, []Fulfillment{aliceFf},
+		[]*Condition{bobCond, prefixCond})
+
+	tmpl, err := TemplateOf(ff)
+	require.NoError(t, err)
+	assert.True(t, tmpl.Condition().Equals(ff.Condition()))
+	subs := tmpl.(*ThresholdSha256Template).SubTemplates
+	require.Len(t, subs, 3)
+	assert.Equal(t, &ConditionTemplate{Cond: bobCond}, subs[1])
+	assert.Equal(t, &ConditionTemplate{Cond: prefixCond}, subs[2])
+
+	// The condition leaves are never requested.
+	var requested []string
+	fulfilled, err := FulfillTemplate(tmpl, func(path NodePath, leaf Template) (Fulfillment, error) {
+		requested = append(requested, path.String())
+		return aliceFf, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/0"}, requested)
+	assert.True(t, FulfillmentsEqual(ff, fulfilled))
+	assert.NoError(t, fulfilled.Validate(tmpl.Condition(), message))
+
+	onlyConditions, err := NewThresholdSha256Template(1, subs[1:])
+	require.NoError(t, err)
+	_, err = FulfillTemplate(onlyConditions, func(NodePath, Template) (Fulfillment, error) {
+		t.Fatal("condition leaf requested")
+		return nil, nil
+	})
+	assert.Equal(t, ErrUnsatisfied, err)
+	_, err = PlanFulfillment(onlyConditions, func(NodePath, Template) (bool, error) {
+		return true, nil
+	})
+	assert.Equal(t, ErrUnsatisfied, err)
+	_, missing, err := Sign(onlyConditions, message, nil, nil)
+	assert.Equal(t, ErrUnsatisfied, err)
+	require.Len(t, missing, 2)
+	assert.Equal(t, "only the condition is known", missing[0].Reason)
+
+	_, err = NewConditionTemplate(nil)
+	assert.Error(t, err)
+	_, err = NewConditionTemplate(NewSimpleCondition(ConditionType(9), make([]byte, 32), 0))
+	assert.Error(t, err)
+}
+
+func TestNewThresholdSha256Template(t *testing.T) {
+	sub, err := NewPreimageSha256Template(make([]byte, 32), 0)
+	require.NoError(t, err)
+
+	_, err = NewThresholdSha256Template(0, []Template{sub})
+	assert.Error(t, err)
+	_, err = NewThresholdSha256Template(2, []Template{sub})
+	assert.Error(t, err)
+	_, err = NewThresholdSha256Template(1, []Template{nil})
+	assert.Error(t, err)
+}
+
+func TestPreimageSha256Template_Fulfill(t *testing.T) {
+	hash := sha256.Sum256([]byte("secret"))
+	tmpl, err := NewPreimageSha256Template(hash[:], 6)
+	require.NoError(t, err)
+
+	_, err = tmpl.Fulfill([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = tmpl.Fulfill([]byte("wrong!"))
+	assert.Error(t, err)
+}
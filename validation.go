@@ -0,0 +1,112 @@
+package cryptoconditions
+
+import (
+	"context"
+	"runtime"
+)
+
+// ValidationOptions control how fulfillments are validated. The zero value
+// and a nil pointer select the behavior of Fulfillment.Validate.
+type ValidationOptions struct {
+	// Ed25519Mode selects the rules for ED25519-SHA-256 signatures.
+	// Validators that have to agree on which fulfillments are valid must use
+	// the same mode.
+	Ed25519Mode Ed25519VerificationMode
+
+	// Workers is the maximum number of goroutines, including the calling
+	// one, that ValidateContext validates with, and so the maximum number of
+	// signatures and preimages that it verifies concurrently. Zero selects
+	// runtime.GOMAXPROCS(0). It is ignored by ValidateWithOptions.
+	Workers int
+
+	// Cache, if not nil, holds the fulfillments that were found valid, so
+	// that validating them again with the same condition and message
+	// succeeds without verifying their signatures. It is only consulted for
+	// the whole fulfillment passed to the validation functions, not for its
+	// sub-fulfillments.
+	Cache *ValidationCache
+
+	// ctx is the context of ValidateContext, nil otherwise.
+	ctx context.Context
+	// workers holds a token for every goroutine that validates a
+	// sub-fulfillment, besides the one that called ValidateContext.
+	workers chan struct{}
+	// verifiedEd25519 holds the Ed25519 signatures that a BatchValidator
+	// has verified, nil otherwise.
+	verifiedEd25519 map[ed25519Key]struct{}
+}
+
+// ed25519Mode returns the Ed25519 verification mode of the options.
+func (o *ValidationOptions) ed25519Mode() Ed25519VerificationMode {
+	if o == nil {
+		return Ed25519Default
+	}
+	return o.Ed25519Mode
+}
+
+// concurrent returns whether sub-fulfillments are validated concurrently.
+func (o *ValidationOptions) concurrent() bool {
+	return o != nil && o.ctx != nil
+}
+
+// context returns the context of the validation.
+func (o *ValidationOptions) context() context.Context {
+	if o == nil || o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+// ValidateWithOptions checks whether the fulfillment validates the given
+// condition using the specified message, like Fulfillment.Validate, but
+// with the given validation options. The options apply to all
+// sub-fulfillments as well.
+func ValidateWithOptions(ff Fulfillment, condition *Condition, message []byte, opts *ValidationOptions) error {
+	return validateCached(ff, condition, message, opts, func() error {
+		return Seal(ff).validate(condition, message, opts)
+	})
+}
+
+// ValidateContext is like ValidateWithOptions, but validates the
+// sub-fulfillments of THRESHOLD-SHA-256 fulfillments concurrently and stops
+// when the context is done, in which case the error of the context is
+// returned. Sub-fulfillments are validated cheapest first and no more of
+// them than needed to reach the threshold, so that the time spent on
+// fulfillments with many expensive invalid branches can be bounded with a
+// deadline.
+func ValidateContext(ctx context.Context, ff Fulfillment, condition *Condition, message []byte, opts *ValidationOptions) error {
+	var o ValidationOptions
+	if opts != nil {
+		o = *opts
+	}
+	workers := o.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	o.ctx = ctx
+	o.workers = make(chan struct{}, workers-1)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return validateCached(ff, condition, message, &o, func() error {
+		if err := Seal(ff).validate(condition, message, &o); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// validateChild validates a sub-fulfillment of a compound fulfillment.
+// When validating concurrently, it fails once the context is done.
+func validateChild(ff Fulfillment, message []byte, opts *ValidationOptions) error {
+	if opts.concurrent() {
+		if err := opts.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return ff.validate(nil, message, opts)
+}
@@ -0,0 +1,154 @@
+package cryptoconditions
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// testHostileThreshold returns a threshold fulfillment in which all but the
+// last of many expensive RSA branches are invalid.
+func testHostileThreshold(t *testing.T, message []byte) Fulfillment {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	valid, err := SignRsaSha256(key, message)
+	require.NoError(t, err)
+	invalid, err := SignRsaSha256(key, []byte("other"))
+	require.NoError(t, err)
+
+	subs := make([]Fulfillment, 0, 65)
+	for i := 0; i < 64; i++ {
+		subs = append(subs, NewPrefixSha256([]byte{byte(i)}, 64, invalid))
+	}
+	subs = append(subs, NewPrefixSha256(nil, 64, valid))
+	return NewThresholdSha256(1, subs, nil)
+}
+
+func TestValidateContext(t *testing.T) {
+	_, alice, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, bob, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	carol, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	message := []byte("payment 42")
+
+	tmpl, err := AtLeast(2,
+		Ed25519Key(alice.Public().(ed25519.PublicKey)),
+		AtLeast(1,
+			Ed25519Key(bob.Public().(ed25519.PublicKey)),
+			WithPrefix([]byte("carol:"), 64, RSAKey(&carol.PublicKey)),
+		),
+		Hashlock(make([]byte, 32), 32),
+	).Template()
+	require.NoError(t, err)
+	cond := tmpl.Condition()
+	keyring, err := NewMemoryKeyring(alice, bob, carol)
+	require.NoError(t, err)
+	ff, _, err := Sign(tmpl, message, keyring, nil)
+	require.NoError(t, err)
+
+	// A single worker must not dead-lock on nested thresholds.
+	for _, workers := range []int{0, 1, 4} {
+		opts := &ValidationOptions{Workers: workers}
+		assert.NoError(t, ValidateContext(context.Background(), ff, cond, message, opts))
+		assert.Error(t, ValidateContext(context.Background(), ff, cond, []byte("other"), opts))
+	}
+	assert.NoError(t, ValidateContext(context.Background(), ff, cond, message, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, ValidateContext(ctx, ff, cond, message, nil))
+}
+
+func TestValidateContext_threshold(t *testing.T) {
+	message := []byte("payment 42")
+	ff := testHostileThreshold(t, message)
+	cond := ff.Condition()
+
+	assert.NoError(t, ff.Validate(cond, message))
+	assert.NoError(t, ValidateContext(context.Background(), ff, cond, message, nil))
+	assert.EqualError(t, ff.Validate(cond, []byte("payment 43")),
+		"Could only verify 0 of 1 fulfillments")
+	assert.EqualError(t,
+		ValidateContext(context.Background(), ff, cond, []byte("payment 43"), nil),
+		"Could only verify 0 of 1 fulfillments")
+
+	// The deadline bounds the time spent on the invalid branches. On a fast
+	// machine, all of them may be verified before it expires.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := ValidateContext(ctx, ff, cond, []byte("payment 43"), &ValidationOptions{Workers: 1})
+	assert.Error(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	// Once the context is done, no further branches are verified.
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	opts := &ValidationOptions{ctx: ctx, workers: make(chan struct{}, 1)}
+	assert.Equal(t, 0, validateConcurrently(
+		ff.(*FfThresholdSha256).SubFulfillments, 1, message, opts))
+}
+
+func TestValidateContext_boundedGoroutines(t *testing.T) {
+	message := []byte("payment 42")
+
+	// A wide threshold of wide thresholds, all of whose leaves are needed.
+	subs := make([]Fulfillment, 16)
+	for i := range subs {
+		leaves := make([]Fulfillment, 64)
+		for j := range leaves {
+			leaves[j] = NewPreimageSha256([]byte{byte(i), byte(j)})
+		}
+		subs[i] = NewThresholdSha256(uint16(len(leaves)), leaves, nil)
+	}
+	ff := NewThresholdSha256(uint16(len(subs)), subs, nil)
+	cond := ff.Condition()
+
+	const workers = 4
+	var peak int
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	base := runtime.NumGoroutine()
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if n := runtime.NumGoroutine() - base; n > peak {
+				peak = n
+			}
+			runtime.Gosched()
+		}
+	}()
+	err := ValidateContext(context.Background(), ff, cond, message, &ValidationOptions{Workers: workers})
+	close(done)
+	<-stopped
+	assert.NoError(t, err)
+
+	// Besides the sampling goroutine, at most workers-1 goroutines are
+	// started.
+	assert.True(t, peak <= workers, "%d goroutines", peak)
+}
+
+func TestValidateSequentially_unreachable(t *testing.T) {
+	preimage := NewPreimageSha256([]byte("secret"))
+	wrong := NewPrefixSha256([]byte("p"), 0, preimage)
+	subs := []Fulfillment{wrong, wrong, preimage}
+
+	// After the first two fail, the threshold of 2 can not be reached and
+	// the last one is not validated.
+	assert.Equal(t, 0, validateSequentially(subs, 2, []byte("too long"), nil))
+	assert.Equal(t, 1, validateSequentially(subs[2:], 1, []byte("too long"), nil))
+}
@@ -0,0 +1,214 @@
+package cryptoconditions
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// NodePath identifies a node in a fulfillment tree by the indices of the
+// children that lead to it from the root. The root has an empty path.
+// The child of a PREFIX-SHA-256 node has index 0. The children of a
+// THRESHOLD-SHA-256 node are numbered with the sub-fulfillments first,
+// followed by the sub-conditions.
+type NodePath []int
+
+// Depth returns the depth of the node, the root having depth 0.
+func (p NodePath) Depth() int {
+	return len(p)
+}
+
+// String returns the path in the form "/0/1", the root being "/".
+func (p NodePath) String() string {
+	if len(p) == 0 {
+		return "/"
+	}
+	parts := make([]string, len(p))
+	for i, index := range p {
+		parts[i] = strconv.Itoa(index)
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// Equals returns true if both paths point to the same node.
+func (p NodePath) Equals(other NodePath) bool {
+	if len(p) != len(other) {
+		return false
+	}
+	for i := range p {
+		if p[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// child returns the path of the index'th child of this node.
+// A new slice is allocated so that paths handed out to callers are never
+// modified afterwards.
+func (p NodePath) child(index int) NodePath {
+	child := make(NodePath, len(p)+1)
+	copy(child, p)
+	child[len(p)] = index
+	return child
+}
+
+// WalkFunc is the type of the function called by Walk for every fulfillment
+// in the tree.
+type WalkFunc func(path NodePath, ff Fulfillment) error
+
+// ConditionWalkFunc is the type of the function called by WalkConditions for
+// every sub-condition of which only the condition is revealed.
+type ConditionWalkFunc func(path NodePath, cond *Condition) error
+
+// SkipChildren can be returned by a WalkFunc to indicate that the children of
+// the fulfillment that was passed should not be visited. It is not returned
+// as an error by any function.
+var SkipChildren = errors.New("skip children")
+
+// Walk walks the fulfillment tree in depth-first order, calling fn for the
+// root and for every sub-fulfillment. Unfulfilled sub-conditions are not
+// visited, use WalkConditions for those.
+// If fn returns an error other than SkipChildren, walking stops and the
+// error is returned.
+func Walk(ff Fulfillment, fn WalkFunc) error {
+	return walk(ff, nil, fn, nil)
+}
+
+// WalkConditions walks the fulfillment tree in depth-first order and calls fn
+// for every sub-condition of which only the condition is revealed, such as
+// the sub-conditions of a threshold fulfillment that were not fulfilled.
+// If fn returns an error, walking stops and the error is returned.
+func WalkConditions(ff Fulfillment, fn ConditionWalkFunc) error {
+	return walk(ff, nil, nil, fn)
+}
+
+// walk visits the subtree of ff. Either of the functions can be nil.
+func walk(ff Fulfillment, path NodePath, ffFn WalkFunc, condFn ConditionWalkFunc) error {
+	if ffFn != nil {
+		if err := ffFn(path, ff); err == SkipChildren {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	subFfs, subConds := subNodes(ff)
+	for i, sff := range subFfs {
+		if err := walk(sff, path.child(i), ffFn, condFn); err != nil {
+			return err
+		}
+	}
+	if condFn != nil {
+		for i, sc := range subConds {
+			if err := condFn(path.child(len(subFfs)+i), sc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// subNodes returns the sub-fulfillments and sub-conditions of a compound
+// fulfillment. Both are empty for simple fulfillments.
+func subNodes(ff Fulfillment) ([]Fulfillment, []*Condition) {
+	switch f := derefFulfillment(ff).(type) {
+	case FfPrefixSha256:
+		if f.IsFulfilled() {
+			return []Fulfillment{f.SubFulfillment}, nil
+		}
+		if f.subCondition != nil {
+			return nil, []*Condition{f.subCondition}
+		}
+	case FfThresholdSha256:
+		return f.SubFulfillments, f.SubConditions
+	}
+	return nil, nil
+}
+
+// Ed25519PublicKeys returns each distinct Ed25519 public key that is used in
+// the fulfillment tree.
+func Ed25519PublicKeys(ff Fulfillment) []ed25519.PublicKey {
+	var seen [][]byte
+	var keys []ed25519.PublicKey
+	Walk(ff, func(_ NodePath, sff Fulfillment) error {
+		if f, ok := derefFulfillment(sff).(FfEd25519Sha256); ok {
+			if !containsBytes(seen, f.PublicKey) {
+				seen = append(seen, f.PublicKey)
+				keys = append(keys, f.Ed25519PublicKey())
+			}
+		}
+		return nil
+	})
+	return keys
+}
+
+// RsaPublicKeys returns each distinct RSA public key that is used in the
+// fulfillment tree.
+func RsaPublicKeys(ff Fulfillment) []*rsa.PublicKey {
+	var moduli [][]byte
+	var keys []*rsa.PublicKey
+	Walk(ff, func(_ NodePath, sff Fulfillment) error {
+		if f, ok := derefFulfillment(sff).(FfRsaSha256); ok {
+			if !containsBytes(moduli, f.Modulus) {
+				moduli = append(moduli, f.Modulus)
+				keys = append(keys, f.PublicKey())
+			}
+		}
+		return nil
+	})
+	return keys
+}
+
+// Prefixes returns each distinct prefix of the PREFIX-SHA-256 fulfillments in
+// the tree.
+func Prefixes(ff Fulfillment) [][]byte {
+	var prefixes [][]byte
+	Walk(ff, func(_ NodePath, sff Fulfillment) error {
+		if f, ok := derefFulfillment(sff).(FfPrefixSha256); ok {
+			if !containsBytes(prefixes, f.Prefix) {
+				prefixes = append(prefixes, f.Prefix)
+			}
+		}
+		return nil
+	})
+	return prefixes
+}
+
+// PreimageHashes returns each distinct SHA-256 hash of a preimage in the tree.
+// This includes the preimages of PREIMAGE-SHA-256 fulfillments as well as the
+// fingerprints of unfulfilled PREIMAGE-SHA-256 sub-conditions.
+func PreimageHashes(ff Fulfillment) [][]byte {
+	var hashes [][]byte
+	add := func(hash []byte) {
+		if !containsBytes(hashes, hash) {
+			hashes = append(hashes, hash)
+		}
+	}
+	walk(ff, nil, func(_ NodePath, sff Fulfillment) error {
+		if sff.ConditionType() == CTPreimageSha256 {
+			add(sff.fingerprint())
+		}
+		return nil
+	}, func(_ NodePath, cond *Condition) error {
+		if cond.Type() == CTPreimageSha256 {
+			add(cond.Fingerprint())
+		}
+		return nil
+	})
+	return hashes
+}
+
+// containsBytes returns true if list contains a slice equal to b.
+func containsBytes(list [][]byte, b []byte) bool {
+	for _, item := range list {
+		if bytes.Equal(item, b) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package cryptoconditions
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodePath_String(t *testing.T) {
+	assert.Equal(t, "/", NodePath(nil).String())
+	assert.Equal(t, "/0/2", NodePath{0, 2}.String())
+	assert.Equal(t, 2, NodePath{0, 2}.Depth())
+	assert.True(t, NodePath{0, 2}.Equals(NodePath{0, 2}))
+	assert.False(t, NodePath{0, 2}.Equals(NodePath{0}))
+}
+
+func TestWalk(t *testing.T) {
+	ff := testNotarizedReceipt(t)
+	subCond := NewPreimageSha256([]byte("hidden")).Condition()
+	ff.SubConditions = []*Condition{subCond}
+
+	var paths []string
+	var types []ConditionType
+	require.NoError(t, Walk(ff, func(path NodePath, sff Fulfillment) error {
+		paths = append(paths, path.String())
+		types = append(types, sff.ConditionType())
+		return nil
+	}))
+	assert.Equal(t, []string{"/", "/0", "/0/0", "/1"}, paths)
+	assert.Equal(t, []ConditionType{
+		CTThresholdSha256, CTPrefixSha256, CTEd25519Sha256, CTPreimageSha256,
+	}, types)
+
+	var condPaths []string
+	require.NoError(t, WalkConditions(ff, func(path NodePath, cond *Condition) error {
+		condPaths = append(condPaths, path.String())
+		assert.True(t, cond.Equals(subCond))
+		return nil
+	}))
+	assert.Equal(t, []string{"/2"}, condPaths)
+}
+
+func TestWalk_stop(t *testing.T) {
+	ff := testNotarizedReceipt(t)
+
+	// Skipping the children of the prefix fulfillment.
+	var paths []string
+	require.NoError(t, Walk(ff, func(path NodePath, sff Fulfillment) error {
+		paths = append(paths, path.String())
+		if sff.ConditionType() == CTPrefixSha256 {
+			return SkipChildren
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"/", "/0", "/1"}, paths)
+
+	// Returning early with an error.
+	errStop := errors.New("stop")
+	paths = nil
+	err := Walk(ff, func(path NodePath, sff Fulfillment) error {
+		paths = append(paths, path.String())
+		if path.Depth() == 2 {
+			return errStop
+		}
+		return nil
+	})
+	assert.Equal(t, errStop, err)
+	assert.Equal(t, []string{"/", "/0", "/0/0"}, paths)
+}
+
+func TestWalk_extract(t *testing.T) {
+	ff := testNotarizedReceipt(t)
+	hiddenPreimage := []byte("hidden")
+	ff.SubConditions = []*Condition{NewPreimageSha256(hiddenPreimage).Condition()}
+
+	keys := Ed25519PublicKeys(ff)
+	require.Len(t, keys, 1)
+	assert.Equal(t, unbase64("LlMeiL_oxBn5Ya2ckB3ivdjnoOcUhFUFnonreZhrJSQ"), []byte(keys[0]))
+
+	assert.Empty(t, RsaPublicKeys(ff))
+
+	prefixes := Prefixes(ff)
+	require.Len(t, prefixes, 1)
+	assert.Equal(t, "https://notary.example/cases/657c12da-8dca-43b0-97ca-8ee8c38ab9f7/state/executed", string(prefixes[0]))
+
+	// The revealed preimage and the hidden one; the revealed preimage equals
+	// the prefix in this vector.
+	hashes := PreimageHashes(ff)
+	require.Len(t, hashes, 2)
+	revealed := sha256.Sum256(prefixes[0])
+	hidden := sha256.Sum256(hiddenPreimage)
+	assert.Equal(t, revealed[:], hashes[0])
+	assert.Equal(t, hidden[:], hashes[1])
+}
@@ -0,0 +1,144 @@
+package cryptoconditions
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrWotsKeyUsed is returned when a WOTS-SHA-256 key that has already signed
+// a message is used again.
+var ErrWotsKeyUsed = errors.New("one-time key has already been used")
+
+// WotsPublicKey is a WOTS-SHA-256 public key. It is the crypto.PublicKey of
+// WotsPrivateKey.
+type WotsPublicKey struct {
+	// Seed is the random public seed that the chains are keyed with.
+	Seed []byte
+	// Hash is the hash of the ends of the chains.
+	Hash []byte
+}
+
+// WotsPrivateKey is a WOTS-SHA-256 one-time private key.
+// It implements crypto.Signer and signs full messages, like Ed25519 keys do.
+// After the first signature, the secret is erased and further signatures are
+// refused with ErrWotsKeyUsed.
+//
+// The use of the key is only recorded in this value, not durably. A copy of
+// the key that was stored with MarshalBinary, or that lives in another
+// process, knows nothing of the signature and will sign again, which reveals
+// enough of the secret to forge signatures. The caller must destroy stored
+// copies, or record their use durably, before signing.
+// It is safe for concurrent use.
+type WotsPrivateKey struct {
+	mu         sync.Mutex
+	secretSeed []byte
+	publicKey  WotsPublicKey
+}
+
+// GenerateWotsKey generates a new one-time key using the given source of
+// randomness.
+func GenerateWotsKey(rand io.Reader) (*WotsPrivateKey, error) {
+	seeds := make([]byte, 2*wotsN)
+	if _, err := io.ReadFull(rand, seeds); err != nil {
+		return nil, errors.Wrap(err, "failed to generate seeds")
+	}
+	return newWotsPrivateKey(seeds[:wotsN], seeds[wotsN:]), nil
+}
+
+// ParseWotsPrivateKey parses a key that was encoded with MarshalBinary.
+// The parsed key is unused, whether or not the key was used since it was
+// encoded; see WotsPrivateKey.
+func ParseWotsPrivateKey(data []byte) (*WotsPrivateKey, error) {
+	if len(data) != 2*wotsN {
+		return nil, errors.Errorf(
+			"wrong one-time key size (%d)", len(data))
+	}
+	secretSeed := append([]byte{}, data[:wotsN]...)
+	publicSeed := append([]byte{}, data[wotsN:]...)
+	return newWotsPrivateKey(secretSeed, publicSeed), nil
+}
+
+func newWotsPrivateKey(secretSeed, publicSeed []byte) *WotsPrivateKey {
+	ends := make([]byte, 0, WotsSignatureSize)
+	for i := 0; i < wotsLen; i++ {
+		start := wotsChainStart(secretSeed, publicSeed, i)
+		ends = append(ends, wotsChain(publicSeed, i, 0, wotsW-1, start)...)
+	}
+	return &WotsPrivateKey{
+		secretSeed: secretSeed,
+		publicKey: WotsPublicKey{
+			Seed: publicSeed,
+			Hash: wotsPublicKeyHash(publicSeed, ends),
+		},
+	}
+}
+
+// wotsChainStart derives the secret start of chain i.
+func wotsChainStart(secretSeed, publicSeed []byte, i int) []byte {
+	h := sha256.New()
+	h.Write([]byte{wotsDomainSecret})
+	h.Write(secretSeed)
+	h.Write(publicSeed)
+	h.Write([]byte{byte(i)})
+	return h.Sum(nil)
+}
+
+// Public returns the WotsPublicKey of the key.
+func (k *WotsPrivateKey) Public() crypto.PublicKey {
+	return k.publicKey
+}
+
+// PublicKey returns the public key.
+func (k *WotsPrivateKey) PublicKey() WotsPublicKey {
+	return k.publicKey
+}
+
+// Used returns whether the key has signed a message.
+func (k *WotsPrivateKey) Used() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.secretSeed == nil
+}
+
+// MarshalBinary encodes the key for storage. It fails once the key has been
+// used. The encoding does not track later use of the key, so it must be
+// destroyed before the key signs; see WotsPrivateKey.
+func (k *WotsPrivateKey) MarshalBinary() ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.secretSeed == nil {
+		return nil, ErrWotsKeyUsed
+	}
+	return append(append([]byte{}, k.secretSeed...), k.publicKey.Seed...), nil
+}
+
+// Sign signs the full message, which must not be hashed; opts must be
+// crypto.Hash(0). The key can only be used once.
+func (k *WotsPrivateKey) Sign(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.Hash(0) {
+		return nil, errors.New("one-time keys sign unhashed messages")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.secretSeed == nil {
+		return nil, ErrWotsKeyUsed
+	}
+
+	publicSeed := k.publicKey.Seed
+	signature := make([]byte, 0, WotsSignatureSize)
+	for i, d := range wotsDigits(publicSeed, message) {
+		start := wotsChainStart(k.secretSeed, publicSeed, i)
+		signature = append(signature, wotsChain(publicSeed, i, 0, int(d), start)...)
+	}
+
+	for i := range k.secretSeed {
+		k.secretSeed[i] = 0
+	}
+	k.secretSeed = nil
+	return signature, nil
+}